@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"vision_api/config"
+	"vision_api/internal/manifest"
+	"vision_api/internal/processor"
+	"vision_api/pkg/vision"
+)
+
+// skipUnchanged is the skip reason of files the manifest marks as processed
+const skipUnchanged = "unchanged"
+
+// runDryRun applies the batch filters to every file in the input directory
+// and prints the billable units and estimated cost per feature, followed by
+// the files that would be skipped. No API calls are made.
+func runDryRun(ctx context.Context, cfg *config.Config, proc processor.ImageProcessor, w io.Writer) error {
+	planner, ok := proc.(processor.BatchPlanner)
+	if !ok {
+		return fmt.Errorf("processor does not support dry runs")
+	}
+
+	features, err := parseFeatures(cfg.Vision.Features)
+	if err != nil {
+		return err
+	}
+
+	prices, err := priceTable(cfg)
+	if err != nil {
+		return err
+	}
+
+	usedUnits, err := featureUnits(cfg.Pricing.UsedUnits)
+	if err != nil {
+		return err
+	}
+
+	// Walk every file so unsupported formats show up as skipped
+	files, err := findFiles(cfg.Storage.InputDir, func(string) bool { return true })
+	if err != nil {
+		return fmt.Errorf("finding images: %w", err)
+	}
+
+	// Files processed by an earlier run are skipped like in a real run
+	m, err := manifest.Load(manifestPath(cfg))
+	if err != nil {
+		return fmt.Errorf("loading manifest: %w", err)
+	}
+	pending := files
+	if !force {
+		pending = selectChanged(m, files, configFingerprint(cfg), nil)
+	}
+
+	plan, err := planner.Plan(ctx, createProcessInputs(pending))
+	if err != nil {
+		return fmt.Errorf("planning batch: %w", err)
+	}
+	plan.Total = len(files)
+	plan.Skipped = append(plan.Skipped, unchangedInputs(files, pending)...)
+
+	// Every requested feature is one billable unit per annotation request
+	units := make(map[vision.FeatureType]int64, len(features))
+	for _, feature := range features {
+		units[feature] = int64(plan.Requests)
+	}
+
+	costs, total, err := prices.Estimate(units, usedUnits)
+	if err != nil {
+		return fmt.Errorf("estimating cost: %w", err)
+	}
+
+	printPlan(w, plan, costs, total, cfg.Pricing.Currency)
+	return nil
+}
+
+// unchangedInputs lists the files left out of pending as skipped
+func unchangedInputs(files, pending []string) []processor.SkippedInput {
+	selected := make(map[string]bool, len(pending))
+	for _, path := range pending {
+		selected[path] = true
+	}
+
+	var skipped []processor.SkippedInput
+	for _, path := range files {
+		if !selected[path] {
+			skipped = append(skipped, processor.SkippedInput{
+				ID:       path,
+				Filename: filepath.Base(path),
+				Reason:   skipUnchanged,
+				Detail:   "processed by an earlier run",
+			})
+		}
+	}
+	return skipped
+}
+
+// priceTable returns the built-in prices overridden by the configured tiers
+func priceTable(cfg *config.Config) (vision.PriceTable, error) {
+	prices := vision.DefaultPriceTable()
+	for name, tiers := range cfg.Pricing.Features {
+		feature, err := vision.ParseFeature(name)
+		if err != nil {
+			return nil, fmt.Errorf("pricing: %w", err)
+		}
+
+		prices[feature] = make([]vision.PriceTier, len(tiers))
+		for i, tier := range tiers {
+			prices[feature][i] = vision.PriceTier{UpTo: tier.UpTo, PricePer1000: tier.PricePer1000}
+		}
+	}
+
+	if err := prices.Validate(); err != nil {
+		return nil, fmt.Errorf("pricing: %w", err)
+	}
+	return prices, nil
+}
+
+// featureUnits parses a units-per-feature map from the config
+func featureUnits(names map[string]int64) (map[vision.FeatureType]int64, error) {
+	units := make(map[vision.FeatureType]int64, len(names))
+	for name, count := range names {
+		feature, err := vision.ParseFeature(name)
+		if err != nil {
+			return nil, fmt.Errorf("pricing: %w", err)
+		}
+		units[feature] = count
+	}
+	return units, nil
+}
+
+// printPlan writes the dry-run report
+func printPlan(w io.Writer, plan *processor.Plan, costs []vision.FeatureCost, total float64, currency string) {
+	reasons := make(map[string]int)
+	for _, skipped := range plan.Skipped {
+		reasons[skipped.Reason]++
+	}
+	names := make([]string, 0, len(reasons))
+	for reason := range reasons {
+		names = append(names, reason)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Dry run: no API calls were made")
+	fmt.Fprintln(tw)
+	fmt.Fprintf(tw, "Files found:\t%d\n", plan.Total)
+	fmt.Fprintf(tw, "Would annotate:\t%d\n", len(plan.Annotate))
+	fmt.Fprintf(tw, "Would skip:\t%d\n", len(plan.Skipped))
+	for _, reason := range names {
+		fmt.Fprintf(tw, "  %s\t%d\n", reason, reasons[reason])
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "Feature\tUnits\tCost")
+	for _, cost := range costs {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f %s\n", cost.Feature, cost.Units, cost.Cost, currency)
+		for _, tier := range cost.Tiers {
+			fmt.Fprintf(tw, "  %s @ %.2f/1000\t%d\t%.2f %s\n",
+				tierRange(tier.Tier), tier.Tier.PricePer1000, tier.Units, tier.Cost, currency)
+		}
+	}
+	fmt.Fprintf(tw, "Total\t\t%.2f %s\n", total, currency)
+	tw.Flush()
+
+	if len(plan.Skipped) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Skipped files:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, skipped := range plan.Skipped {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", skipped.ID, skipped.Reason, skipped.Detail)
+	}
+	tw.Flush()
+}
+
+// tierRange describes the upper bound of a price tier
+func tierRange(tier vision.PriceTier) string {
+	if tier.UpTo == 0 {
+		return "unbounded"
+	}
+	return fmt.Sprintf("up to %d", tier.UpTo)
+}
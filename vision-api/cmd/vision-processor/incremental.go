@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+
+	"vision_api/config"
+	"vision_api/internal/manifest"
+	"vision_api/internal/metrics"
+	"vision_api/internal/processor"
+	"vision_api/internal/utils"
+	"vision_api/pkg/dataset"
+)
+
+// manifestPath returns the manifest file of this shard in the output directory
+func manifestPath(cfg *config.Config) string {
+	return filepath.Join(cfg.Storage.OutputDir, shardName("manifest")+".json")
+}
+
+// configFingerprint hashes the settings that change annotation results, so
+// files are reprocessed when the features or resize limits change
+func configFingerprint(cfg *config.Config) string {
+	values := []string{
+		"max_width=" + strconv.Itoa(cfg.Image.MaxWidth),
+		"max_height=" + strconv.Itoa(cfg.Image.MaxHeight),
+		"quality=" + strconv.Itoa(cfg.Image.Quality),
+	}
+	for _, feature := range cfg.Vision.Features {
+		values = append(values, "feature="+feature)
+	}
+	// Only set formats are hashed so existing manifests stay valid
+	if cfg.Image.OutputFormat != "" {
+		values = append(values, "output_format="+cfg.Image.OutputFormat)
+	}
+	if cfg.Image.TargetSizeKB != 0 {
+		values = append(values, "target_size_kb="+strconv.Itoa(cfg.Image.TargetSizeKB))
+	}
+	if cfg.Image.TileSize != 0 {
+		values = append(values,
+			"tile_size="+strconv.Itoa(cfg.Image.TileSize),
+			"tile_overlap="+strconv.Itoa(cfg.Image.TileOverlap),
+			"tile_nms_threshold="+strconv.FormatFloat(cfg.Image.TileNMSThreshold, 'g', -1, 64),
+			"tile_label_scoring="+cfg.Image.TileLabelScoring,
+		)
+	}
+	if cfg.Image.FrameSampling != "" && cfg.Image.FrameSampling != "first" {
+		values = append(values,
+			"frame_sampling="+cfg.Image.FrameSampling,
+			"frame_count="+strconv.Itoa(cfg.Image.FrameCount),
+		)
+	}
+	if screening := cfg.Image.Screening; screening.Enabled {
+		values = append(values, fmt.Sprintf("screening=%g/%g/%g/%g/%t",
+			screening.MinSharpness, screening.MaxDarkLuminance, screening.MinBrightLuminance,
+			screening.MinEntropy, screening.SkipSolidColor))
+	}
+	if cfg.Vision.LocationContext {
+		values = append(values, "location_context=true")
+	}
+	return manifest.Fingerprint(values...)
+}
+
+// selectChanged returns the images that are new or changed since they were
+// last processed. Images that can't be checked are processed again. The
+// unchanged images are counted as cache hits if collector is not nil.
+func selectChanged(m *manifest.Manifest, images []string, fingerprint string, collector *metrics.Collector) []string {
+	var changed []string
+	for _, path := range images {
+		status, err := m.Check(path, fingerprint)
+		if err != nil {
+			slog.Warn("failed to check manifest entry", "path", path, "error", err)
+			changed = append(changed, path)
+			continue
+		}
+		if status.NeedsProcessing() {
+			slog.Debug("image needs processing", "path", path, "status", status)
+			changed = append(changed, path)
+		}
+	}
+	if collector != nil {
+		collector.ObserveUnchanged(len(images) - len(changed))
+	}
+	return changed
+}
+
+// updateManifest records the successful and skipped results in the
+// manifest and returns the dataset records of the other results
+func updateManifest(m *manifest.Manifest, fingerprint string, results []processor.ProcessOutput) []dataset.Record {
+	var unfinished []dataset.Record
+	pages := make(map[string]int)
+	for _, result := range results {
+		record := newRecord(result)
+
+		// Skipped images are finished until the screening config changes
+		if result.Error != nil && !errors.Is(result.Error, utils.ErrSkipped) {
+			unfinished = append(unfinished, record)
+			continue
+		}
+
+		var err error
+		if path := processor.PagePath(result.ID); path != result.ID {
+			if _, ok := pages[path]; !ok {
+				pages[path] = pageCount(path)
+			}
+			err = m.UpdatePage(path, fingerprint, pages[path], record)
+		} else {
+			err = m.Update(result.ID, fingerprint, record)
+		}
+		if err != nil {
+			slog.Warn("failed to update manifest entry", "path", result.ID, "error", err)
+		}
+	}
+	return unfinished
+}
+
+// recordInManifest adds the successful results of a run outside the main
+// pipeline, such as a retry, to the manifest under the given fingerprint
+func recordInManifest(cfg *config.Config, fingerprint string, results []processor.ProcessOutput) error {
+	m, err := manifest.Load(manifestPath(cfg))
+	if err != nil {
+		return err
+	}
+	updateManifest(m, fingerprint, results)
+	if err := m.Save(); err != nil {
+		return fmt.Errorf("saving manifest: %w", err)
+	}
+	return nil
+}
@@ -2,21 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
-	"../../config"
-	"../../internal/image"
-	"../../internal/processor"
-	"../../internal/progress"
-	"../../pkg/dataset"
-	"../../pkg/vision"
+	"vision_api/config"
+	"vision_api/internal/admin"
+	"vision_api/internal/budget"
+	"vision_api/internal/image"
+	"vision_api/internal/logging"
+	"vision_api/internal/manifest"
+	"vision_api/internal/metrics"
+	"vision_api/internal/processor"
+	"vision_api/internal/progress"
+	"vision_api/internal/tracing"
+	"vision_api/internal/utils"
+	"vision_api/pkg/dataset"
+	"vision_api/pkg/vision"
 )
 
 var (
@@ -25,6 +36,10 @@ var (
 	outputDir   string
 	concurrency int
 	debug       bool
+	dryRun      bool
+	force       bool
+	shardIndex  int
+	shardCount  int
 )
 
 func init() {
@@ -33,17 +48,50 @@ func init() {
 	flag.StringVar(&outputDir, "output", "", "Directory for processed outputs")
 	flag.IntVar(&concurrency, "concurrency", 0, "Number of concurrent processors")
 	flag.BoolVar(&debug, "debug", false, "Enable debug logging")
+	flag.BoolVar(&dryRun, "dry-run", false, "Report the images that would be annotated and the estimated cost without calling the API")
+	flag.IntVar(&shardIndex, "shard-index", 0, "Index of the shard of the input tree this machine processes, starting at 0")
+	flag.IntVar(&shardCount, "shard-count", 1, "Number of machines splitting the input tree")
+	flag.BoolVar(&force, "force", false, "Reprocess every image, ignoring the manifest of earlier runs")
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "retry-failed" {
+		if err := runRetryFailed(os.Args[2:]); err != nil {
+			slog.Error("retry failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		if err := runMerge(os.Args[2:]); err != nil {
+			slog.Error("merge failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "watch" {
+		if err := runWatch(os.Args[2:]); err != nil {
+			slog.Error("watch failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	flag.Parse()
 
 	if err := run(); err != nil {
-		log.Fatalf("Error: %v", err)
+		slog.Error("run failed", "error", err)
+		os.Exit(1)
 	}
 }
 
 func run() error {
+	if err := validateShard(); err != nil {
+		return err
+	}
+
 	// Load configuration
 	cfg, err := config.Load(configFile)
 	if err != nil {
@@ -61,26 +109,39 @@ func run() error {
 		cfg.Vision.PoolSize = concurrency
 	}
 
+	if err := initializeLogging(cfg); err != nil {
+		return fmt.Errorf("initializing logging: %w", err)
+	}
+
 	// Validate directories
 	if err := validateDirectories(cfg); err != nil {
 		return err
 	}
 
-	// Setup context with cancellation
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Handle graceful shutdown
-	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-shutdown
-		log.Println("Shutting down gracefully...")
-		cancel()
-	}()
+	// Setup two-phase shutdown: drain on the first signal, exit on the second
+	sd := newShutdown(time.Duration(cfg.Server.ShutdownTimeout) * time.Second)
+	defer sd.Stop()
+	ctx := sd.Context()
 
 	// Initialize components
-	visionClient, err := initializeVisionClient(cfg)
+	shutdownTracing, err := initializeTracing(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("initializing tracing: %w", err)
+	}
+	defer shutdownTracing()
+
+	collector, err := initializeMetrics(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("initializing metrics: %w", err)
+	}
+
+	spend, err := initializeBudget(cfg)
+	if err != nil {
+		return fmt.Errorf("initializing budget: %w", err)
+	}
+	defer saveBudget(spend)
+
+	visionClient, err := initializeVisionClient(cfg, collector, spend)
 	if err != nil {
 		return fmt.Errorf("initializing vision client: %w", err)
 	}
@@ -90,45 +151,121 @@ func run() error {
 		return fmt.Errorf("initializing image handler: %w", err)
 	}
 
-	processor, err := initializeProcessor(cfg, visionClient, imageHandler)
+	proc, err := initializeProcessor(cfg, visionClient, imageHandler, collector, spend)
 	if err != nil {
 		return fmt.Errorf("initializing processor: %w", err)
 	}
 
+	if dryRun {
+		return runDryRun(ctx, cfg, proc, os.Stdout)
+	}
+
+	if err := initializeControl(ctx, cfg, proc, visionClient); err != nil {
+		return fmt.Errorf("initializing runtime control: %w", err)
+	}
+	sd.OnDrainProcessor(proc)
+
 	// Find images to process
 	images, err := findImages(cfg.Storage.InputDir)
 	if err != nil {
 		return fmt.Errorf("finding images: %w", err)
 	}
 
-	if len(images) == 0 {
-		log.Println("No images found to process")
-		return nil
+	// Only process images that are new or changed since the last run
+	m, err := manifest.Load(manifestPath(cfg))
+	if err != nil {
+		return fmt.Errorf("loading manifest: %w", err)
+	}
+	for _, path := range m.Prune(images) {
+		slog.Info("dropping deleted image from dataset", "path", path)
 	}
 
-	// Initialize progress tracker
-	tracker := progress.NewTracker(int64(len(images)), os.Stdout)
-	processor.SetProgressTracker(tracker)
-	tracker.Start()
-	defer tracker.Finish()
+	fingerprint := configFingerprint(cfg)
+	pending := images
+	if !force {
+		pending = selectChanged(m, images, fingerprint, collector)
+	}
 
-	// Process images
-	log.Printf("Processing %d images...", len(images))
+	var results []processor.ProcessOutput
 	startTime := time.Now()
+	if len(pending) == 0 {
+		slog.Info("no new or changed images to process", "dir", cfg.Storage.InputDir, "unchanged", len(images))
+	} else {
+		// Initialize progress tracker; each page of a multi-page image is
+		// its own input
+		inputs := createProcessInputs(pending)
+		tracker := progress.NewTracker(int64(len(inputs)), os.Stdout)
+		proc.SetProgressTracker(tracker)
+		tracker.Start()
+
+		// Process images
+		slog.Info("processing images", "count", len(pending), "unchanged", len(images)-len(pending))
+
+		results, err = proc.ProcessBatch(ctx, inputs)
+		tracker.Finish()
+		if err != nil {
+			return fmt.Errorf("processing images: %w", err)
+		}
+	}
 
-	results, err := processor.ProcessBatch(ctx, createProcessInputs(images))
-	if err != nil {
-		return fmt.Errorf("processing images: %w", err)
+	unfinished := updateManifest(m, fingerprint, results)
+	if err := m.Save(); err != nil {
+		return fmt.Errorf("saving manifest: %w", err)
 	}
 
-	// Generate dataset
-	if err := generateDataset(cfg, results); err != nil {
+	// Generate dataset from every processed image, plus this run's failures
+	if err := generateDataset(cfg, shardName("dataset"), append(m.Records(), unfinished...)); err != nil {
 		return fmt.Errorf("generating dataset: %w", err)
 	}
 
-	duration := time.Since(startTime)
-	log.Printf("Processing completed in %v", duration)
+	// Save what wasn't processed and report exactly what was
+	report := newRunReport(results)
+	if err := report.save(unprocessedPath(cfg)); err != nil {
+		return fmt.Errorf("saving unprocessed list: %w", err)
+	}
+	slog.Info("processing completed", "duration", time.Since(startTime))
+	report.print(os.Stdout, unprocessedPath(cfg))
+
+	return checkBudget(cfg, spend, results)
+}
+
+// initializeControl lets the processor be paused with SIGUSR1 and resumed
+// with SIGUSR2, and serves the admin endpoint when configured
+func initializeControl(ctx context.Context, cfg *config.Config, proc processor.ImageProcessor, client *vision.Client) error {
+	controller, ok := proc.(processor.Controller)
+	if !ok {
+		return nil
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				if sig == syscall.SIGUSR1 {
+					controller.Pause()
+				} else {
+					controller.Resume()
+				}
+			}
+		}
+	}()
+
+	if cfg.Server.AdminAddr == "" {
+		return nil
+	}
+
+	server := admin.NewServer(controller, client, cfg.Server.AdminToken, slog.Default())
+	timeout := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+	if err := server.Start(ctx, cfg.Server.AdminAddr, timeout); err != nil {
+		return err
+	}
 
+	slog.Info("serving admin endpoint", "url", "http://"+cfg.Server.AdminAddr)
 	return nil
 }
 
@@ -149,14 +286,167 @@ func validateDirectories(cfg *config.Config) error {
 	return nil
 }
 
-func initializeVisionClient(cfg *config.Config) (*vision.Client, error) {
-	return vision.NewClient(
+// initializeMetrics starts the metrics server if enabled. The returned
+// collector is nil when metrics are disabled.
+func initializeMetrics(ctx context.Context, cfg *config.Config) (*metrics.Collector, error) {
+	if !cfg.Server.MetricsEnabled {
+		return nil, nil
+	}
+
+	collector := metrics.NewCollector()
+	addr := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
+	timeout := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+	if err := collector.Start(ctx, addr, timeout); err != nil {
+		return nil, err
+	}
+
+	slog.Info("serving metrics", "url", "http://"+addr+"/metrics")
+	return collector, nil
+}
+
+// initializeLogging installs the configured logger as the default logger,
+// which the processor, vision client and dataset generator log through.
+// The debug flag lowers every component to debug level.
+func initializeLogging(cfg *config.Config) error {
+	level, err := logging.ParseLevel(cfg.Logging.Level)
+	if err != nil {
+		return err
+	}
+	if debug {
+		level = slog.LevelDebug
+	}
+
+	opts := []logging.OptionFunc{
+		logging.WithFormat(cfg.Logging.Format),
+		logging.WithLevel(level),
+	}
+	if !debug {
+		for component, name := range cfg.Logging.Levels {
+			componentLevel, err := logging.ParseLevel(name)
+			if err != nil {
+				return fmt.Errorf("component %s: %w", component, err)
+			}
+			opts = append(opts, logging.WithComponentLevel(component, componentLevel))
+		}
+	}
+
+	logger, err := logging.New(opts...)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// initializeTracing installs the global tracer provider if tracing is
+// enabled. The returned function flushes pending spans.
+func initializeTracing(ctx context.Context, cfg *config.Config) (func(), error) {
+	if !cfg.Tracing.Enabled {
+		return func() {}, nil
+	}
+
+	shutdown, err := tracing.Setup(ctx,
+		tracing.WithServiceName(cfg.Tracing.ServiceName),
+		tracing.WithOTLPEndpoint(cfg.Tracing.OTLPEndpoint, cfg.Tracing.Insecure),
+		tracing.WithFile(cfg.Tracing.FilePath),
+		tracing.WithSampleRatio(cfg.Tracing.SampleRatio),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		// Flush with a fresh context since ctx is usually canceled by now
+		timeout := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+		flushCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := shutdown(flushCtx); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}, nil
+}
+
+// initializeBudget creates the spend tracker, resuming the totals of
+// earlier runs
+func initializeBudget(cfg *config.Config) (*budget.Budget, error) {
+	prices, err := priceTable(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	caps := cfg.Vision.Budget
+	return budget.New(
+		budget.WithPrices(prices),
+		budget.WithDailyCaps(caps.DailySoftCap, caps.DailyHardCap),
+		budget.WithMonthlyCaps(caps.MonthlySoftCap, caps.MonthlyHardCap),
+		budget.WithStatePath(caps.StateFile),
+	)
+}
+
+// saveBudget persists the spend totals, logging failures since the results
+// of the batch are already written
+func saveBudget(spend *budget.Budget) {
+	if err := spend.Save(); err != nil {
+		slog.Error("failed to save budget state", "error", err)
+	}
+}
+
+// checkBudget saves and logs the current spend and fails if a hard cap
+// stopped the batch, so the run exits non-zero with the unstarted images
+// left in the dead-letter directory for retry-failed
+func checkBudget(cfg *config.Config, spend *budget.Budget, results []processor.ProcessOutput) error {
+	saveBudget(spend)
+	day, month := spend.Spend()
+	slog.Info("spend", "day", day, "month", month, "currency", cfg.Pricing.Currency)
+
+	stopped := 0
+	for _, result := range results {
+		if errors.Is(result.Error, utils.ErrBudgetExceeded) {
+			stopped++
+		}
+	}
+	if stopped > 0 {
+		return fmt.Errorf("hard budget cap reached with %d images not started; resume them with retry-failed from %s",
+			stopped, deadLetterDir(cfg))
+	}
+	return nil
+}
+
+func initializeVisionClient(cfg *config.Config, collector *metrics.Collector, spend *budget.Budget) (*vision.Client, error) {
+	features, err := parseFeatures(cfg.Vision.Features)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []vision.OptionFunc{
 		vision.WithRateLimit(cfg.Vision.RateLimit),
 		vision.WithMaxRetries(cfg.Vision.MaxRetries),
-		vision.WithTimeout(time.Duration(cfg.Vision.TimeoutSeconds)*time.Second),
-		vision.WithMaxConcurrent(cfg.Vision.PoolSize),
+		vision.WithTimeout(time.Duration(cfg.Vision.TimeoutSeconds) * time.Second),
+		vision.WithMaxConcurrent(cfg.Vision.MaxPoolSize),
 		vision.WithDebug(debug),
-	)
+		vision.WithFeatures(features...),
+	}
+
+	// Successful calls are counted by the budget and, if enabled, metrics
+	observers := []vision.Observer{spend}
+	if collector != nil {
+		observers = append(observers, collector)
+	}
+	opts = append(opts, vision.WithObserver(vision.MultiObserver(observers...)))
+
+	return vision.NewClient(opts...)
+}
+
+func parseFeatures(names []string) ([]vision.FeatureType, error) {
+	features := make([]vision.FeatureType, 0, len(names))
+	for _, name := range names {
+		feature, err := vision.ParseFeature(name)
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, feature)
+	}
+	return features, nil
 }
 
 func initializeImageHandler(cfg *config.Config) (image.Handler, error) {
@@ -164,81 +454,224 @@ func initializeImageHandler(cfg *config.Config) (image.Handler, error) {
 		image.WithMaxImageSize(int64(cfg.Image.MaxSizeMB)*1024*1024),
 		image.WithMaxDimensions(cfg.Image.MaxWidth, cfg.Image.MaxHeight),
 		image.WithDefaultQuality(cfg.Image.Quality),
+		image.WithOutputFormat(image.Format(cfg.Image.OutputFormat)),
+		image.WithTargetSize(int64(cfg.Image.TargetSizeKB)*1024),
+		image.WithDownscaleSteps(cfg.Image.DownscaleSteps),
+		image.WithMaxPixels(int64(cfg.Image.MaxMegapixels)*1000*1000),
+		image.WithMaxFrames(cfg.Image.MaxFrames),
+		image.WithDecodeTimeout(time.Duration(cfg.Image.DecodeTimeoutSeconds)*time.Second),
 	)
 }
 
-func initializeProcessor(cfg *config.Config, client *vision.Client, handler image.Handler) (processor.ImageProcessor, error) {
-	return processor.NewProcessor(
+func initializeProcessor(cfg *config.Config, client *vision.Client, handler image.Handler, collector *metrics.Collector, spend *budget.Budget) (processor.ImageProcessor, error) {
+	opts := []processor.OptionFunc{
 		processor.WithPoolSize(cfg.Vision.PoolSize),
 		processor.WithBatchSize(cfg.Vision.BatchSize),
+		processor.WithPrepareWorkers(cfg.Vision.PrepareWorkers),
+		processor.WithAnnotateWorkers(cfg.Vision.PoolSize),
+		processor.WithPersistWorkers(cfg.Vision.PersistWorkers),
+		processor.WithQueueSize(cfg.Vision.QueueSize),
+		processor.WithMaxPoolSize(cfg.Vision.MaxPoolSize),
 		processor.WithImageHandler(handler),
 		processor.WithVisionClient(client),
-	)
+		processor.WithDeduplication(cfg.Image.Deduplicate),
+		processor.WithTiling(cfg.Image.TileSize, cfg.Image.TileOverlap),
+		processor.WithTileNMSThreshold(cfg.Image.TileNMSThreshold),
+		processor.WithTileLabelScoring(processor.LabelScoring(cfg.Image.TileLabelScoring)),
+		processor.WithFrameSampling(image.FrameStrategy(cfg.Image.FrameSampling), cfg.Image.FrameCount),
+		processor.WithExtensionMismatch(processor.ExtensionPolicy(cfg.Image.ExtensionMismatch)),
+		processor.WithLocationContext(cfg.Vision.LocationContext),
+		processor.WithNearDuplicates(cfg.Image.NearDuplicates),
+		processor.WithDedupThreshold(cfg.Image.DedupThreshold),
+		processor.WithDeadLetterDir(deadLetterDir(cfg)),
+		processor.WithMaxInflightBytes(int64(cfg.Image.MaxInflightMB) * 1024 * 1024),
+		processor.WithMaxInflightPixels(int64(cfg.Image.MaxInflightMegapixels) * 1000 * 1000),
+		processor.WithPreserveOrder(true),
+		processor.WithMaxFileSize(int64(cfg.Image.MaxSizeMB) * 1024 * 1024),
+		processor.WithAllowedFormats(cfg.Image.AllowedFormats),
+		processor.WithSpendGuard(spend),
+	}
+	if collector != nil {
+		opts = append(opts, processor.WithMetrics(collector))
+	}
+	if screening := cfg.Image.Screening; screening.Enabled {
+		opts = append(opts, processor.WithQualityScreening(&image.QualityThresholds{
+			MinSharpness:       screening.MinSharpness,
+			MaxDarkLuminance:   screening.MaxDarkLuminance,
+			MinBrightLuminance: screening.MinBrightLuminance,
+			MinEntropy:         screening.MinEntropy,
+			SkipSolidColor:     screening.SkipSolidColor,
+		}))
+	}
+
+	return processor.NewProcessor(opts...)
+}
+
+// deadLetterDir returns the configured dead-letter directory, defaulting
+// to a subdirectory of the output directory
+func deadLetterDir(cfg *config.Config) string {
+	if cfg.Storage.DeadLetterDir != "" {
+		return cfg.Storage.DeadLetterDir
+	}
+	return filepath.Join(cfg.Storage.OutputDir, "dead-letter")
 }
 
 func findImages(dir string) ([]string, error) {
-	var images []string
+	return findFiles(dir, isImageFile)
+}
+
+// findFiles returns the regular files below dir accepted by match that
+// belong to this machine's shard
+func findFiles(dir string, match func(path string) bool) ([]string, error) {
+	var files []string
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() && isImageFile(path) {
-			images = append(images, path)
+		if !info.IsDir() && match(path) && inShard(dir, path) {
+			files = append(files, path)
 		}
 		return nil
 	})
-	return images, err
+	return files, err
 }
 
+// isImageFile reports whether a file has an image extension, ignoring
+// case. The content is checked when the image is processed.
 func isImageFile(path string) bool {
-	ext := filepath.Ext(path)
-	switch ext {
-	case ".jpg", ".jpeg", ".png", ".gif", ".bmp":
+	switch image.FormatFromExtension(path) {
+	case image.JPEG, image.PNG, image.GIF, image.BMP, image.WEBP, image.TIFF:
 		return true
 	default:
 		return false
 	}
 }
 
+// createProcessInputs creates an input per image, and one per page of
+// multi-page images
 func createProcessInputs(images []string) []processor.ProcessInput {
-	inputs := make([]processor.ProcessInput, len(images))
-	for i, path := range images {
-		inputs[i] = processor.ProcessInput{
-			Filename: filepath.Base(path),
-			Metadata: map[string]interface{}{
-				"path": path,
-			},
+	inputs := make([]processor.ProcessInput, 0, len(images))
+	for _, path := range images {
+		pages := pageCount(path)
+		if pages == 1 {
+			inputs = append(inputs, processor.ProcessInput{
+				ID:       path,
+				Filename: filepath.Base(path),
+				Metadata: map[string]interface{}{
+					"path": path,
+				},
+			})
+			continue
+		}
+
+		name := filepath.Base(path)
+		ext := filepath.Ext(name)
+		for page := 0; page < pages; page++ {
+			inputs = append(inputs, processor.ProcessInput{
+				ID:       processor.PageID(path, page),
+				Filename: fmt.Sprintf("%s-page%d%s", strings.TrimSuffix(name, ext), page+1, ext),
+				Metadata: map[string]interface{}{
+					"path":            path,
+					processor.PageKey: page,
+				},
+			})
 		}
 	}
 	return inputs
 }
 
-func generateDataset(cfg *config.Config, results []processor.ProcessOutput) error {
+// pageCount returns the number of pages of an image file, found from its
+// content so renamed TIFFs are split too. Files that can't be read count
+// as one page and fail when they are processed.
+func pageCount(path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		return 1
+	}
+	defer file.Close()
+
+	pages, err := image.PageCount(file)
+	if err != nil {
+		slog.Warn("failed to count pages", "path", path, "error", err)
+		return 1
+	}
+	return pages
+}
+
+func generateDataset(cfg *config.Config, name string, records []dataset.Record) error {
 	generator, err := dataset.NewGenerator(
 		dataset.WithOutputDir(cfg.Storage.OutputDir),
 		dataset.WithFormat(dataset.FormatJSONL),
+		dataset.WithName(name),
 	)
 	if err != nil {
 		return err
 	}
 
+	return generator.GenerateDataset(context.Background(), records)
+}
+
+// newRecords converts processing results into dataset records
+func newRecords(results []processor.ProcessOutput) []dataset.Record {
 	records := make([]dataset.Record, len(results))
 	for i, result := range results {
-		records[i] = dataset.Record{
-			ID:        result.Filename,
-			ImagePath: result.Metadata["path"].(string),
-			Labels:    extractLabels(result.Labels),
-			Status:    string(getStatus(result.Error)),
+		records[i] = newRecord(result)
+	}
+	return records
+}
+
+// newRecord converts a processing result into a dataset record
+func newRecord(result processor.ProcessOutput) dataset.Record {
+	record := dataset.Record{
+		ID:            result.Filename,
+		CorrelationID: result.CorrelationID,
+		Labels:        extractLabels(result.Labels),
+		Status:        string(getStatus(result.Error)),
+	}
+	record.ImagePath, record.Page = processor.SplitPageID(result.ID)
+	record.ClusterID, _ = result.Metadata["cluster_id"].(string)
+	record.DuplicateOf, _ = result.Metadata["duplicate_of"].(string)
+	record.FrameCount, _ = result.Metadata["frames"].(int)
+	record.SkipReason, _ = result.Metadata["skip_reason"].(string)
+	record.Warning, _ = result.Metadata["format_warning"].(string)
+	if q := result.Quality; q != nil {
+		record.Quality = &dataset.Quality{
+			Sharpness:       q.Sharpness,
+			MeanLuminance:   q.MeanLuminance,
+			DarkLuminance:   q.DarkLuminance,
+			BrightLuminance: q.BrightLuminance,
+			Entropy:         q.Entropy,
+			SolidColor:      q.SolidColor,
 		}
-		if result.Error != nil {
-			records[i].ErrorMessage = result.Error.Error()
+	}
+	for _, frame := range result.Frames {
+		record.FrameLabels = append(record.FrameLabels, dataset.FrameLabels{
+			Frame:  frame.Index,
+			Labels: extractLabels(frame.Labels),
+		})
+	}
+	if exif := result.EXIF; exif != nil {
+		record.CapturedAt = exif.CapturedAt
+		record.CameraMake = exif.Make
+		record.CameraModel = exif.Model
+		record.ExposureTime = exif.ExposureTime
+		record.FNumber = exif.FNumber
+		record.ISO = exif.ISO
+		record.FocalLength = exif.FocalLength
+		record.Orientation = exif.Orientation
+		if exif.GPS != nil {
+			record.Latitude = &exif.GPS.Latitude
+			record.Longitude = &exif.GPS.Longitude
 		}
 	}
-
-	return generator.GenerateDataset(context.Background(), records)
+	if result.Error != nil {
+		record.ErrorMessage = result.Error.Error()
+	}
+	return record
 }
 
-func extractLabels(labels []vision.Label) []string {
+// extractLabels returns the descriptions of labels
+func extractLabels(labels []processor.Label) []string {
 	result := make([]string, len(labels))
 	for i, label := range labels {
 		result[i] = label.Description
@@ -247,8 +680,14 @@ func extractLabels(labels []vision.Label) []string {
 }
 
 func getStatus(err error) dataset.ProcessingStatus {
-	if err == nil {
+	switch {
+	case err == nil:
 		return dataset.StatusSuccess
+	case errors.Is(err, utils.ErrNotProcessed):
+		return dataset.StatusPending
+	case errors.Is(err, utils.ErrSkipped):
+		return dataset.StatusSkipped
+	default:
+		return dataset.StatusFailed
 	}
-	return dataset.StatusFailed
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"vision_api/config"
+	"vision_api/internal/processor"
+	"vision_api/internal/progress"
+	"vision_api/internal/utils"
+)
+
+// runRetryFailed reprocesses the images recorded in the dead-letter
+// directory, optionally with different image or feature settings. Images
+// the API rejected as invalid are left out unless --all is given or any
+// image or feature setting is overridden, since the rejection may have
+// been caused by those settings.
+func runRetryFailed(args []string) error {
+	fs := flag.NewFlagSet("retry-failed", flag.ExitOnError)
+	fs.StringVar(&configFile, "config", "config.yaml", "Path to configuration file")
+	fs.StringVar(&outputDir, "output", "", "Directory for processed outputs")
+	fs.IntVar(&concurrency, "concurrency", 0, "Number of concurrent processors")
+	fs.BoolVar(&debug, "debug", false, "Enable debug logging")
+	deadLetters := fs.String("dead-letter", "", "Dead-letter directory to reprocess (default <output>/dead-letter)")
+	maxWidth := fs.Int("max-width", 0, "Override the maximum image width")
+	maxHeight := fs.Int("max-height", 0, "Override the maximum image height")
+	quality := fs.Int("quality", 0, "Override the JPEG quality")
+	features := fs.String("features", "", "Comma-separated vision features to request instead of the configured ones")
+	all := fs.Bool("all", false, "Also retry images the API rejected as invalid")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	// Recovered images are recorded under the configured settings, so later
+	// runs don't reprocess them just because this retry overrode some
+	fingerprint := configFingerprint(cfg)
+
+	// Override config with command line flags if provided
+	if outputDir != "" {
+		cfg.Storage.OutputDir = outputDir
+	}
+	if concurrency > 0 {
+		cfg.Vision.PoolSize = concurrency
+	}
+	if *deadLetters != "" {
+		cfg.Storage.DeadLetterDir = *deadLetters
+	}
+	if *maxWidth > 0 {
+		cfg.Image.MaxWidth = *maxWidth
+	}
+	if *maxHeight > 0 {
+		cfg.Image.MaxHeight = *maxHeight
+	}
+	if *quality > 0 {
+		cfg.Image.Quality = *quality
+	}
+	if *features != "" {
+		cfg.Vision.Features = strings.Split(*features, ",")
+	}
+	overridden := *maxWidth > 0 || *maxHeight > 0 || *quality > 0 || *features != ""
+
+	if err := initializeLogging(cfg); err != nil {
+		return fmt.Errorf("initializing logging: %w", err)
+	}
+
+	if err := os.MkdirAll(cfg.Storage.OutputDir, 0755); err != nil {
+		return fmt.Errorf("creating directory %s: %w", cfg.Storage.OutputDir, err)
+	}
+
+	letters, err := processor.LoadDeadLetters(deadLetterDir(cfg))
+	if err != nil {
+		return fmt.Errorf("loading dead letters: %w", err)
+	}
+
+	if !*all && !overridden {
+		letters = retryableLetters(letters)
+	}
+
+	if len(letters) == 0 {
+		slog.Info("no failed images to retry", "dir", deadLetterDir(cfg))
+		return nil
+	}
+
+	sd := newShutdown(time.Duration(cfg.Server.ShutdownTimeout) * time.Second)
+	defer sd.Stop()
+	ctx := sd.Context()
+
+	// Initialize components
+	shutdownTracing, err := initializeTracing(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("initializing tracing: %w", err)
+	}
+	defer shutdownTracing()
+
+	collector, err := initializeMetrics(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("initializing metrics: %w", err)
+	}
+
+	spend, err := initializeBudget(cfg)
+	if err != nil {
+		return fmt.Errorf("initializing budget: %w", err)
+	}
+	defer saveBudget(spend)
+
+	visionClient, err := initializeVisionClient(cfg, collector, spend)
+	if err != nil {
+		return fmt.Errorf("initializing vision client: %w", err)
+	}
+
+	imageHandler, err := initializeImageHandler(cfg)
+	if err != nil {
+		return fmt.Errorf("initializing image handler: %w", err)
+	}
+
+	proc, err := initializeProcessor(cfg, visionClient, imageHandler, collector, spend)
+	if err != nil {
+		return fmt.Errorf("initializing processor: %w", err)
+	}
+
+	if err := initializeControl(ctx, cfg, proc, visionClient); err != nil {
+		return fmt.Errorf("initializing runtime control: %w", err)
+	}
+	sd.OnDrainProcessor(proc)
+
+	inputs := make([]processor.ProcessInput, len(letters))
+	for i, letter := range letters {
+		inputs[i] = letter.Input()
+	}
+
+	tracker := progress.NewTracker(int64(len(inputs)), os.Stdout)
+	proc.SetProgressTracker(tracker)
+	tracker.Start()
+	defer tracker.Finish()
+
+	slog.Info("retrying failed images", "count", len(inputs))
+	startTime := time.Now()
+
+	results, err := proc.ProcessBatch(ctx, inputs)
+	if err != nil {
+		return fmt.Errorf("processing images: %w", err)
+	}
+
+	if err := recordInManifest(cfg, fingerprint, results); err != nil {
+		return fmt.Errorf("updating manifest: %w", err)
+	}
+
+	if err := generateDataset(cfg, "dataset-retry", newRecords(results)); err != nil {
+		return fmt.Errorf("generating dataset: %w", err)
+	}
+
+	var failed, pending int
+	for _, result := range results {
+		switch {
+		case result.Error == nil:
+		case errors.Is(result.Error, utils.ErrNotProcessed):
+			pending++
+		default:
+			failed++
+		}
+	}
+	slog.Info("retry completed",
+		"duration", time.Since(startTime),
+		"recovered", len(results)-failed-pending,
+		"failed", failed,
+		"pending", pending,
+	)
+
+	return checkBudget(cfg, spend, results)
+}
+
+// retryableLetters drops the dead letters whose error can't be fixed by
+// resubmitting the image
+func retryableLetters(letters []processor.DeadLetter) []processor.DeadLetter {
+	retryable := letters[:0]
+	for _, letter := range letters {
+		if utils.ErrorClass(letter.ErrorClass).IsPermanent() {
+			continue
+		}
+		retryable = append(retryable, letter)
+	}
+	if skipped := len(letters) - len(retryable); skipped > 0 {
+		slog.Info("skipping images the API rejected", "count", skipped)
+	}
+	return retryable
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"vision_api/internal/processor"
+	"vision_api/internal/utils"
+)
+
+func TestRetryableLetters(t *testing.T) {
+	letters := []processor.DeadLetter{
+		{Filename: "rejected.jpg", ErrorClass: string(utils.ClassRejected)},
+		{Filename: "timeout.jpg", ErrorClass: string(utils.ClassTimeout)},
+		{Filename: "too-large.jpg", ErrorClass: string(utils.ClassUnknown)},
+		{Filename: "also-rejected.jpg", ErrorClass: string(utils.ClassRejected)},
+		{Filename: "unavailable.jpg", ErrorClass: string(utils.ClassUnavailable)},
+	}
+
+	got := retryableLetters(letters)
+
+	var names []string
+	for _, letter := range got {
+		names = append(names, letter.Filename)
+	}
+	want := []string{"timeout.jpg", "too-large.jpg", "unavailable.jpg"}
+	if len(names) != len(want) {
+		t.Fatalf("retryableLetters() = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("retryableLetters()[%d] = %s, want %s", i, names[i], want[i])
+		}
+	}
+}
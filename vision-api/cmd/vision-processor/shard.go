@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"path/filepath"
+
+	"vision_api/config"
+	"vision_api/pkg/dataset"
+)
+
+// validateShard checks the --shard-index and --shard-count flags
+func validateShard() error {
+	if shardCount < 1 {
+		return fmt.Errorf("shard count must be at least 1")
+	}
+	if shardIndex < 0 || shardIndex >= shardCount {
+		return fmt.Errorf("shard index must be between 0 and %d", shardCount-1)
+	}
+	return nil
+}
+
+// inShard reports whether path belongs to this machine's shard. Paths are
+// hashed relative to root, so machines mounting the input tree at
+// different locations still agree on the partition.
+func inShard(root, path string) bool {
+	if shardCount <= 1 {
+		return true
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	return shardOf(filepath.ToSlash(rel), shardCount) == shardIndex
+}
+
+// shardOf returns the shard of a relative path
+func shardOf(rel string, count int) int {
+	h := fnv.New64a()
+	h.Write([]byte(rel))
+	return int(h.Sum64() % uint64(count))
+}
+
+// shardName suffixes an output name with the shard when sharding, so
+// machines sharing an output directory don't overwrite each other
+func shardName(name string) string {
+	if shardCount <= 1 {
+		return name
+	}
+	return fmt.Sprintf("%s-part-%d-of-%d", name, shardIndex, shardCount)
+}
+
+// runMerge combines the dataset parts written by the shards of a run into
+// one dataset with recomputed stats
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	fs.StringVar(&configFile, "config", "config.yaml", "Path to configuration file")
+	fs.StringVar(&outputDir, "output", "", "Directory containing the parts and receiving the merged dataset")
+	name := fs.String("name", "dataset", "Name of the merged dataset; parts are found as <name>-part-*")
+	format := fs.String("format", string(dataset.FormatJSONL), "Format of the merged dataset: json, jsonl or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if outputDir != "" {
+		cfg.Storage.OutputDir = outputDir
+	}
+
+	if err := initializeLogging(cfg); err != nil {
+		return fmt.Errorf("initializing logging: %w", err)
+	}
+
+	// Parts may be given explicitly; otherwise every part of name is merged
+	parts := fs.Args()
+	if len(parts) == 0 {
+		parts, err = filepath.Glob(filepath.Join(cfg.Storage.OutputDir, *name+"-part-*.jsonl"))
+		if err != nil {
+			return err
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("no dataset parts found in %s", cfg.Storage.OutputDir)
+	}
+
+	generator, err := dataset.NewGenerator(
+		dataset.WithOutputDir(cfg.Storage.OutputDir),
+		dataset.WithFormat(dataset.Format(*format)),
+		dataset.WithName(*name),
+	)
+	if err != nil {
+		return err
+	}
+
+	stats, err := generator.Merge(context.Background(), parts)
+	if err != nil {
+		return fmt.Errorf("merging dataset: %w", err)
+	}
+
+	slog.Info("dataset merged",
+		"parts", len(parts),
+		"records", stats.TotalRecords,
+		"successful", stats.SuccessfulCount,
+		"failed", stats.FailedCount,
+		"skipped", stats.SkippedCount,
+		"unique_labels", stats.UniqueLabels,
+		"average_labels", stats.AverageLabels,
+	)
+	return nil
+}
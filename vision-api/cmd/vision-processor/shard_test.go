@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardOfIsStable(t *testing.T) {
+	// Pinned so a change of the hash, which would reshuffle every shard of
+	// a running job, fails here first
+	pinned := []struct {
+		rel   string
+		count int
+		want  int
+	}{
+		{"a.jpg", 4, 1},
+		{"album/b.jpg", 4, 0},
+		{"album/2024/c.png", 4, 2},
+		{"a.jpg", 3, 2},
+		{"album/b.jpg", 3, 0},
+	}
+	for _, p := range pinned {
+		if got := shardOf(p.rel, p.count); got != p.want {
+			t.Errorf("shardOf(%q, %d) = %d, want %d", p.rel, p.count, got, p.want)
+		}
+	}
+}
+
+func TestInShardPartitions(t *testing.T) {
+	defer func(index, count int) { shardIndex, shardCount = index, count }(shardIndex, shardCount)
+
+	// Every file belongs to exactly one shard, whatever the mount point
+	shardCount = 3
+	for i := 0; i < 100; i++ {
+		rel := fmt.Sprintf("dir%d/image%d.jpg", i%7, i)
+		owners := 0
+		for shardIndex = 0; shardIndex < shardCount; shardIndex++ {
+			local := inShard("/mnt/photos", "/mnt/photos/"+rel)
+			remote := inShard("/data/in", "/data/in/"+rel)
+			if local != remote {
+				t.Fatalf("%s: shard %d differs between mount points", rel, shardIndex)
+			}
+			if local {
+				owners++
+			}
+		}
+		if owners != 1 {
+			t.Fatalf("%s belongs to %d shards, want 1", rel, owners)
+		}
+	}
+
+	shardIndex = 1
+	if got, want := shardName("manifest"), "manifest-part-1-of-3"; got != want {
+		t.Errorf("shardName() = %q, want %q", got, want)
+	}
+	shardCount = 1
+	if got := shardName("manifest"); got != "manifest" {
+		t.Errorf("shardName() without sharding = %q, want manifest", got)
+	}
+}
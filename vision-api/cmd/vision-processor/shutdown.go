@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"vision_api/config"
+	"vision_api/internal/processor"
+	"vision_api/internal/utils"
+)
+
+// shutdown implements a two-phase shutdown on SIGINT and SIGTERM. The first
+// signal drains: no new work is started and work in flight gets the drain
+// timeout to finish before the context is canceled. A second signal exits
+// immediately.
+type shutdown struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	drains   []func()
+	draining chan struct{}
+}
+
+// newShutdown installs the signal handler. Work in flight gets timeout to
+// finish after the first signal.
+func newShutdown(timeout time.Duration) *shutdown {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &shutdown{
+		ctx:      ctx,
+		cancel:   cancel,
+		draining: make(chan struct{}),
+	}
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+
+		select {
+		case <-signals:
+			slog.Info("shutting down gracefully; signal again to exit immediately", "timeout", timeout)
+			s.drain()
+		case <-ctx.Done():
+			return
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-signals:
+			slog.Warn("exiting immediately; work in flight is lost")
+			os.Exit(1)
+		case <-timer.C:
+			slog.Warn("drain timeout reached; canceling work in flight")
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+
+		// A second signal still forces the exit while cleaning up
+		select {
+		case <-signals:
+			slog.Warn("exiting immediately")
+			os.Exit(1)
+		case <-ctx.Done():
+		}
+	}()
+
+	return s
+}
+
+// Context returns the context that is canceled once the drain times out
+func (s *shutdown) Context() context.Context {
+	return s.ctx
+}
+
+// Draining returns a channel that is closed on the first signal
+func (s *shutdown) Draining() <-chan struct{} {
+	return s.draining
+}
+
+// OnDrain registers fn to be called on the first signal. It is called
+// right away if the drain already started.
+func (s *shutdown) OnDrain(fn func()) {
+	s.mu.Lock()
+	select {
+	case <-s.draining:
+		s.mu.Unlock()
+		fn()
+		return
+	default:
+	}
+	s.drains = append(s.drains, fn)
+	s.mu.Unlock()
+}
+
+// OnDrainProcessor drains proc on the first signal if it supports it
+func (s *shutdown) OnDrainProcessor(proc processor.ImageProcessor) {
+	if drainer, ok := proc.(processor.Drainer); ok {
+		s.OnDrain(drainer.Drain)
+	}
+}
+
+// Stop cancels the context and releases the signal handler
+func (s *shutdown) Stop() {
+	s.cancel()
+}
+
+// drain starts the first phase
+func (s *shutdown) drain() {
+	s.mu.Lock()
+	close(s.draining)
+	drains := s.drains
+	s.drains = nil
+	s.mu.Unlock()
+
+	for _, fn := range drains {
+		fn()
+	}
+}
+
+// unprocessedInput is an entry of the unprocessed list
+type unprocessedInput struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
+	Error    string `json:"error"`
+}
+
+// runReport summarizes what a run did and didn't process
+type runReport struct {
+	Total       int
+	Succeeded   int
+	Failed      int
+	Interrupted int
+	NotStarted  int
+	Unprocessed []unprocessedInput
+}
+
+// newRunReport sorts the results of a run into the report categories.
+// Inputs that never started or were canceled mid-call are unprocessed;
+// the others were processed, successfully or not.
+func newRunReport(results []processor.ProcessOutput) *runReport {
+	report := &runReport{Total: len(results)}
+	for _, result := range results {
+		switch {
+		case result.Error == nil:
+			report.Succeeded++
+			continue
+		case errors.Is(result.Error, utils.ErrNotProcessed):
+			report.NotStarted++
+		case errors.Is(result.Error, context.Canceled), errors.Is(result.Error, context.DeadlineExceeded):
+			report.Interrupted++
+		default:
+			report.Failed++
+			continue
+		}
+		report.Unprocessed = append(report.Unprocessed, unprocessedInput{
+			ID:       result.ID,
+			Filename: result.Filename,
+			Reason:   string(utils.ClassifyError(result.Error)),
+			Error:    result.Error.Error(),
+		})
+	}
+	return report
+}
+
+// unprocessedPath returns the file the unprocessed list of this shard is
+// saved to
+func unprocessedPath(cfg *config.Config) string {
+	return filepath.Join(cfg.Storage.OutputDir, shardName("unprocessed")+".json")
+}
+
+// save writes the unprocessed list, or removes a stale one when
+// everything was processed
+func (r *runReport) save(path string) error {
+	if len(r.Unprocessed) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return utils.SaveJSON(path, r.Unprocessed)
+}
+
+// print writes the final report
+func (r *runReport) print(w io.Writer, unprocessedPath string) {
+	fmt.Fprintf(w, "Run report:\n")
+	fmt.Fprintf(w, "  Inputs:        %d\n", r.Total)
+	fmt.Fprintf(w, "  Succeeded:     %d\n", r.Succeeded)
+	fmt.Fprintf(w, "  Failed:        %d\n", r.Failed)
+	fmt.Fprintf(w, "  Interrupted:   %d\n", r.Interrupted)
+	fmt.Fprintf(w, "  Not started:   %d\n", r.NotStarted)
+	if len(r.Unprocessed) > 0 {
+		fmt.Fprintf(w, "  Unprocessed inputs are listed in %s and are picked up by the next run\n", unprocessedPath)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vision_api/internal/processor"
+	"vision_api/internal/utils"
+)
+
+func TestOnDrain(t *testing.T) {
+	s := &shutdown{draining: make(chan struct{})}
+
+	var calls []string
+	s.OnDrain(func() { calls = append(calls, "early") })
+	s.drain()
+	if len(calls) != 1 {
+		t.Fatalf("drain ran %d callbacks, want 1", len(calls))
+	}
+	select {
+	case <-s.Draining():
+	default:
+		t.Error("Draining() not closed after drain")
+	}
+
+	// Registering after the drain started runs the callback right away
+	s.OnDrain(func() { calls = append(calls, "late") })
+	if len(calls) != 2 || calls[1] != "late" {
+		t.Errorf("callbacks = %v, want [early late]", calls)
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	results := []processor.ProcessOutput{
+		{ID: "1", Filename: "a.jpg"},
+		{ID: "2", Filename: "b.jpg", Error: errors.New("bad image")},
+		{ID: "3", Filename: "c.jpg", Error: fmt.Errorf("annotating: %w", context.Canceled)},
+		{ID: "4", Filename: "d.jpg", Error: fmt.Errorf("%w: %w", utils.ErrNotProcessed, context.Canceled)},
+	}
+	report := newRunReport(results)
+	if report.Total != 4 || report.Succeeded != 1 || report.Failed != 1 || report.Interrupted != 1 || report.NotStarted != 1 {
+		t.Errorf("report = %+v, want one input of each kind", report)
+	}
+	if len(report.Unprocessed) != 2 || report.Unprocessed[0].ID != "3" || report.Unprocessed[1].ID != "4" {
+		t.Fatalf("Unprocessed = %+v, want inputs 3 and 4", report.Unprocessed)
+	}
+
+	path := filepath.Join(t.TempDir(), "unprocessed.json")
+	if err := report.save(path); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	var saved []unprocessedInput
+	if err := utils.LoadJSON(path, &saved); err != nil || len(saved) != 2 {
+		t.Fatalf("saved list = %+v, %v; want 2 inputs", saved, err)
+	}
+
+	// A clean run removes the stale list
+	if err := newRunReport(results[:1]).save(path); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("stale unprocessed list kept: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"vision_api/config"
+	"vision_api/internal/budget"
+	"vision_api/internal/manifest"
+	"vision_api/internal/metrics"
+	"vision_api/internal/processor"
+	"vision_api/internal/watch"
+	"vision_api/pkg/dataset"
+)
+
+// runWatch processes the images already waiting in the input directory,
+// then keeps processing images as they arrive until interrupted. Results
+// are appended to a dataset file that rolls over daily.
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	fs.StringVar(&configFile, "config", "config.yaml", "Path to configuration file")
+	fs.StringVar(&imageDir, "input", "", "Directory to watch for images")
+	fs.StringVar(&outputDir, "output", "", "Directory for processed outputs")
+	fs.IntVar(&concurrency, "concurrency", 0, "Number of concurrent processors")
+	fs.BoolVar(&debug, "debug", false, "Enable debug logging")
+	fs.IntVar(&shardIndex, "shard-index", 0, "Index of the shard of the input tree this machine processes, starting at 0")
+	fs.IntVar(&shardCount, "shard-count", 1, "Number of machines splitting the input tree")
+	debounce := fs.Duration("debounce", 2*time.Second, "How long a file must be unchanged before it is processed")
+	window := fs.Duration("batch-window", time.Second, "How long to collect arrived images into one batch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateShard(); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	// Override config with command line flags if provided
+	if imageDir != "" {
+		cfg.Storage.InputDir = imageDir
+	}
+	if outputDir != "" {
+		cfg.Storage.OutputDir = outputDir
+	}
+	if concurrency > 0 {
+		cfg.Vision.PoolSize = concurrency
+	}
+
+	if err := initializeLogging(cfg); err != nil {
+		return fmt.Errorf("initializing logging: %w", err)
+	}
+
+	if err := validateDirectories(cfg); err != nil {
+		return fmt.Errorf("validating directories: %w", err)
+	}
+
+	sd := newShutdown(time.Duration(cfg.Server.ShutdownTimeout) * time.Second)
+	defer sd.Stop()
+	ctx := sd.Context()
+
+	// Initialize components
+	shutdownTracing, err := initializeTracing(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("initializing tracing: %w", err)
+	}
+	defer shutdownTracing()
+
+	collector, err := initializeMetrics(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("initializing metrics: %w", err)
+	}
+
+	spend, err := initializeBudget(cfg)
+	if err != nil {
+		return fmt.Errorf("initializing budget: %w", err)
+	}
+	defer saveBudget(spend)
+
+	visionClient, err := initializeVisionClient(cfg, collector, spend)
+	if err != nil {
+		return fmt.Errorf("initializing vision client: %w", err)
+	}
+
+	imageHandler, err := initializeImageHandler(cfg)
+	if err != nil {
+		return fmt.Errorf("initializing image handler: %w", err)
+	}
+
+	proc, err := initializeProcessor(cfg, visionClient, imageHandler, collector, spend)
+	if err != nil {
+		return fmt.Errorf("initializing processor: %w", err)
+	}
+
+	if err := initializeControl(ctx, cfg, proc, visionClient); err != nil {
+		return fmt.Errorf("initializing runtime control: %w", err)
+	}
+	sd.OnDrainProcessor(proc)
+
+	m, err := manifest.Load(manifestPath(cfg))
+	if err != nil {
+		return fmt.Errorf("loading manifest: %w", err)
+	}
+
+	// Watch before scanning so images arriving during the scan aren't missed
+	watcher, err := watch.New(cfg.Storage.InputDir,
+		watch.WithDebounce(*debounce),
+		watch.WithMatch(func(path string) bool {
+			return isImageFile(path) && inShard(cfg.Storage.InputDir, path)
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", cfg.Storage.InputDir, err)
+	}
+	go watcher.Run(ctx)
+
+	w := &watchRun{
+		cfg:         cfg,
+		proc:        proc,
+		manifest:    m,
+		fingerprint: configFingerprint(cfg),
+		collector:   collector,
+	}
+
+	images, err := findImages(cfg.Storage.InputDir)
+	if err != nil {
+		return fmt.Errorf("finding images: %w", err)
+	}
+	if err := w.processAndCheck(ctx, images, spend); err != nil {
+		return err
+	}
+
+	slog.Info("watching for new images", "dir", cfg.Storage.InputDir)
+	for {
+		batch, ok := collectBatch(sd.Draining(), watcher.Ready(), *window, cfg.Vision.BatchSize)
+		if !ok {
+			slog.Info("watch stopped")
+			return nil
+		}
+		if err := w.processAndCheck(ctx, batch, spend); err != nil {
+			return err
+		}
+	}
+}
+
+// watchRun holds the state shared by the batches of a watch
+type watchRun struct {
+	cfg         *config.Config
+	proc        processor.ImageProcessor
+	manifest    *manifest.Manifest
+	fingerprint string
+	collector   *metrics.Collector
+}
+
+// processAndCheck processes a batch and checks the spend caps. A failed
+// batch is logged and the watch continues; only cancellation and a hard
+// cap stop it. Images of a failed batch are picked up by the next run.
+func (w *watchRun) processAndCheck(ctx context.Context, images []string, spend *budget.Budget) error {
+	results, err := w.process(ctx, images)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		slog.Error("failed to process images", "count", len(images), "error", err)
+	}
+	return checkBudget(w.cfg, spend, results)
+}
+
+// process annotates the images that changed since they were last processed
+// and appends their records to the rolling dataset
+func (w *watchRun) process(ctx context.Context, images []string) ([]processor.ProcessOutput, error) {
+	pending := selectChanged(w.manifest, images, w.fingerprint, w.collector)
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	slog.Info("processing images", "count", len(pending))
+	results, err := w.proc.ProcessBatch(ctx, createProcessInputs(pending))
+	if err != nil {
+		return nil, fmt.Errorf("processing images: %w", err)
+	}
+
+	updateManifest(w.manifest, w.fingerprint, results)
+	if err := w.manifest.Save(); err != nil {
+		return results, fmt.Errorf("saving manifest: %w", err)
+	}
+
+	if err := appendDataset(w.cfg, shardName(rollingName(time.Now())), newRecords(results)); err != nil {
+		return results, fmt.Errorf("appending to dataset: %w", err)
+	}
+	return results, nil
+}
+
+// collectBatch waits for an image, then gathers the images arriving within
+// window, up to max. It reports false once stop is closed.
+func collectBatch(stop <-chan struct{}, ready <-chan string, window time.Duration, max int) ([]string, bool) {
+	var batch []string
+	select {
+	case <-stop:
+		return nil, false
+	case path := <-ready:
+		batch = append(batch, path)
+	}
+
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+
+	for len(batch) < max {
+		select {
+		case <-stop:
+			return batch, true
+		case <-timer.C:
+			return batch, true
+		case path := <-ready:
+			batch = append(batch, path)
+		}
+	}
+	return batch, true
+}
+
+// rollingName returns the name of the watch dataset for the day of t
+func rollingName(t time.Time) string {
+	return "dataset-watch-" + t.Format("2006-01-02")
+}
+
+// appendDataset appends records to the named JSONL dataset
+func appendDataset(cfg *config.Config, name string, records []dataset.Record) error {
+	generator, err := dataset.NewGenerator(
+		dataset.WithOutputDir(cfg.Storage.OutputDir),
+		dataset.WithFormat(dataset.FormatJSONL),
+		dataset.WithName(name),
+	)
+	if err != nil {
+		return err
+	}
+
+	return generator.AppendRecords(context.Background(), records)
+}
@@ -2,16 +2,20 @@ package config
 
 import (
 	"fmt"
+	"runtime"
 	"strings"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Vision   VisionConfig   `mapstructure:"vision"`
-	Image    ImageConfig    `mapstructure:"image"`
-	Storage  StorageConfig  `mapstructure:"storage"`
+	Server  ServerConfig  `mapstructure:"server"`
+	Vision  VisionConfig  `mapstructure:"vision"`
+	Image   ImageConfig   `mapstructure:"image"`
+	Storage StorageConfig `mapstructure:"storage"`
+	Tracing TracingConfig `mapstructure:"tracing"`
+	Logging LoggingConfig `mapstructure:"logging"`
+	Pricing PricingConfig `mapstructure:"pricing"`
 }
 
 type ServerConfig struct {
@@ -19,27 +23,154 @@ type ServerConfig struct {
 	Host            string `mapstructure:"host"`
 	Mode            string `mapstructure:"mode"`
 	ShutdownTimeout int    `mapstructure:"shutdown_timeout"`
+	MetricsEnabled  bool   `mapstructure:"metrics_enabled"`
+
+	// AdminAddr is the address of the runtime control endpoint; empty
+	// disables it
+	AdminAddr string `mapstructure:"admin_addr"`
+
+	// AdminToken is the bearer token the admin endpoint requires. Without
+	// it the endpoint only listens on loopback addresses.
+	AdminToken string `mapstructure:"admin_token"`
 }
 
 type VisionConfig struct {
-	MaxRetries      int `mapstructure:"max_retries"`
-	BatchSize       int `mapstructure:"batch_size"`
-	PoolSize        int `mapstructure:"pool_size"`
-	RateLimit       int `mapstructure:"rate_limit"`
-	TimeoutSeconds  int `mapstructure:"timeout_seconds"`
+	MaxRetries     int      `mapstructure:"max_retries"`
+	BatchSize      int      `mapstructure:"batch_size"`
+	PoolSize       int      `mapstructure:"pool_size"`
+	RateLimit      int      `mapstructure:"rate_limit"`
+	TimeoutSeconds int      `mapstructure:"timeout_seconds"`
+	Features       []string `mapstructure:"features"`
+
+	// Stage concurrency; pool_size sets the number of concurrent API calls
+	PrepareWorkers int `mapstructure:"prepare_workers"`
+	PersistWorkers int `mapstructure:"persist_workers"`
+	QueueSize      int `mapstructure:"queue_size"`
+
+	// MaxPoolSize is the largest pool size accepted at runtime
+	MaxPoolSize int `mapstructure:"max_pool_size"`
+
+	// LocationContext sends the EXIF GPS location of images with requests
+	LocationContext bool `mapstructure:"location_context"`
+
+	// Budget caps the spend per day and month
+	Budget BudgetConfig `mapstructure:"budget"`
+}
+
+// BudgetConfig holds the spend caps in the pricing currency; zero disables
+// a cap. Running totals are kept in StateFile across runs.
+type BudgetConfig struct {
+	DailySoftCap   float64 `mapstructure:"daily_soft_cap"`
+	DailyHardCap   float64 `mapstructure:"daily_hard_cap"`
+	MonthlySoftCap float64 `mapstructure:"monthly_soft_cap"`
+	MonthlyHardCap float64 `mapstructure:"monthly_hard_cap"`
+	StateFile      string  `mapstructure:"state_file"`
 }
 
 type ImageConfig struct {
-	MaxSizeMB      int   `mapstructure:"max_size_mb"`
-	MaxWidth       int   `mapstructure:"max_width"`
-	MaxHeight      int   `mapstructure:"max_height"`
-	Quality        int   `mapstructure:"quality"`
+	MaxSizeMB      int      `mapstructure:"max_size_mb"`
+	MaxWidth       int      `mapstructure:"max_width"`
+	MaxHeight      int      `mapstructure:"max_height"`
+	Quality        int      `mapstructure:"quality"`
 	AllowedFormats []string `mapstructure:"allowed_formats"`
+	Deduplicate    bool     `mapstructure:"deduplicate"`
+	NearDuplicates bool     `mapstructure:"near_duplicates"`
+	DedupThreshold int      `mapstructure:"dedup_threshold"`
+
+	// OutputFormat is the encoding of prepared images; empty keeps the
+	// source format when the API accepts it
+	OutputFormat string `mapstructure:"output_format"`
+
+	// TargetSizeKB is the byte budget of prepared images; 0 uses MaxSizeMB.
+	// Images that don't fit at the lowest JPEG quality are downscaled up to
+	// DownscaleSteps times.
+	TargetSizeKB   int `mapstructure:"target_size_kb"`
+	DownscaleSteps int `mapstructure:"downscale_steps"`
+
+	// TileSize splits larger images into tiles annotated in addition to
+	// the whole image; 0 disables tiling
+	TileSize         int     `mapstructure:"tile_size"`
+	TileOverlap      int     `mapstructure:"tile_overlap"`
+	TileNMSThreshold float64 `mapstructure:"tile_nms_threshold"`
+	TileLabelScoring string  `mapstructure:"tile_label_scoring"`
+
+	// FrameSampling selects the frames of animated GIFs that are annotated:
+	// "first", every FrameCount-th frame with "every", or FrameCount evenly
+	// spaced frames with "spaced"
+	FrameSampling string `mapstructure:"frame_sampling"`
+	FrameCount    int    `mapstructure:"frame_count"`
+
+	// ExtensionMismatch is "override" to process images whose content
+	// doesn't match their extension by their content, with a warning, or
+	// "reject" to fail them
+	ExtensionMismatch string `mapstructure:"extension_mismatch"`
+
+	// Screening skips images failing quality checks before annotation
+	Screening ScreeningConfig `mapstructure:"screening"`
+
+	// MaxMegapixels, MaxFrames and DecodeTimeoutSeconds bound decoding a
+	// single image, so decompression bombs are rejected; 0 disables a limit
+	MaxMegapixels        int `mapstructure:"max_megapixels"`
+	MaxFrames            int `mapstructure:"max_frames"`
+	DecodeTimeoutSeconds int `mapstructure:"decode_timeout_seconds"`
+
+	// MaxInflightMB and MaxInflightMegapixels bound memory across all workers
+	MaxInflightMB         int `mapstructure:"max_inflight_mb"`
+	MaxInflightMegapixels int `mapstructure:"max_inflight_megapixels"`
+}
+
+// ScreeningConfig holds the quality screening thresholds; zero disables a
+// check. Luminance is on a 0-255 scale.
+type ScreeningConfig struct {
+	Enabled            bool    `mapstructure:"enabled"`
+	MinSharpness       float64 `mapstructure:"min_sharpness"`
+	MaxDarkLuminance   float64 `mapstructure:"max_dark_luminance"`
+	MinBrightLuminance float64 `mapstructure:"min_bright_luminance"`
+	MinEntropy         float64 `mapstructure:"min_entropy"`
+	SkipSolidColor     bool    `mapstructure:"skip_solid_color"`
 }
 
 type StorageConfig struct {
-	OutputDir string `mapstructure:"output_dir"`
-	TempDir   string `mapstructure:"temp_dir"`
+	InputDir      string `mapstructure:"input_dir"`
+	OutputDir     string `mapstructure:"output_dir"`
+	TempDir       string `mapstructure:"temp_dir"`
+	DeadLetterDir string `mapstructure:"dead_letter_dir"`
+}
+
+type TracingConfig struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	ServiceName string  `mapstructure:"service_name"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+
+	// OTLPEndpoint is the host:port of an OTLP/HTTP collector; empty disables OTLP export
+	OTLPEndpoint string `mapstructure:"otlp_endpoint"`
+	Insecure     bool   `mapstructure:"insecure"`
+
+	// FilePath receives spans as JSON for offline analysis; empty disables it
+	FilePath string `mapstructure:"file_path"`
+}
+
+type LoggingConfig struct {
+	Format string `mapstructure:"format"`
+	Level  string `mapstructure:"level"`
+
+	// Levels overrides the level per component: cmd, processor, vision, dataset
+	Levels map[string]string `mapstructure:"levels"`
+}
+
+// PricingConfig is the price table used by dry runs. Features without
+// tiers fall back to the built-in list prices.
+type PricingConfig struct {
+	Currency string                       `mapstructure:"currency"`
+	Features map[string][]PriceTierConfig `mapstructure:"features"`
+
+	// UsedUnits are units already consumed this billing month per feature
+	UsedUnits map[string]int64 `mapstructure:"used_units"`
+}
+
+type PriceTierConfig struct {
+	UpTo         int64   `mapstructure:"up_to"`
+	PricePer1000 float64 `mapstructure:"price_per_1000"`
 }
 
 // Load reads the configuration from file and environment variables
@@ -75,6 +206,9 @@ func setDefaults() {
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("server.mode", "release")
 	viper.SetDefault("server.shutdown_timeout", 5)
+	viper.SetDefault("server.metrics_enabled", false)
+	viper.SetDefault("server.admin_addr", "")
+	viper.SetDefault("server.admin_token", "")
 
 	// Vision API defaults
 	viper.SetDefault("vision.max_retries", 3)
@@ -82,17 +216,63 @@ func setDefaults() {
 	viper.SetDefault("vision.pool_size", 8)
 	viper.SetDefault("vision.rate_limit", 1800)
 	viper.SetDefault("vision.timeout_seconds", 30)
+	viper.SetDefault("vision.features", []string{"LABEL_DETECTION"})
+	viper.SetDefault("vision.prepare_workers", runtime.NumCPU())
+	viper.SetDefault("vision.persist_workers", 2)
+	viper.SetDefault("vision.queue_size", 16)
+	viper.SetDefault("vision.max_pool_size", 64)
+	viper.SetDefault("vision.location_context", false)
+	viper.SetDefault("vision.budget.state_file", "./vision-budget.json")
 
 	// Image processing defaults
 	viper.SetDefault("image.max_size_mb", 40)
 	viper.SetDefault("image.max_width", 4096)
 	viper.SetDefault("image.max_height", 4096)
 	viper.SetDefault("image.quality", 85)
-	viper.SetDefault("image.allowed_formats", []string{"jpeg", "jpg", "png", "gif", "bmp"})
+	viper.SetDefault("image.allowed_formats", []string{"jpeg", "jpg", "png", "gif", "bmp", "webp", "tif", "tiff"})
+	viper.SetDefault("image.output_format", "")
+	viper.SetDefault("image.extension_mismatch", "override")
+	viper.SetDefault("image.target_size_kb", 0)
+	viper.SetDefault("image.downscale_steps", 2)
+	viper.SetDefault("image.tile_size", 0)
+	viper.SetDefault("image.tile_overlap", 256)
+	viper.SetDefault("image.tile_nms_threshold", 0.5)
+	viper.SetDefault("image.tile_label_scoring", "max")
+	viper.SetDefault("image.frame_sampling", "first")
+	viper.SetDefault("image.frame_count", 4)
+	viper.SetDefault("image.screening.enabled", false)
+	viper.SetDefault("image.screening.min_sharpness", 50)
+	viper.SetDefault("image.screening.max_dark_luminance", 20)
+	viper.SetDefault("image.screening.min_bright_luminance", 240)
+	viper.SetDefault("image.screening.min_entropy", 1.5)
+	viper.SetDefault("image.screening.skip_solid_color", true)
+	viper.SetDefault("image.deduplicate", false)
+	viper.SetDefault("image.near_duplicates", true)
+	viper.SetDefault("image.dedup_threshold", 6)
+	viper.SetDefault("image.max_megapixels", 200)
+	viper.SetDefault("image.max_frames", 1000)
+	viper.SetDefault("image.decode_timeout_seconds", 30)
+	viper.SetDefault("image.max_inflight_mb", 512)
+	viper.SetDefault("image.max_inflight_megapixels", 400)
 
 	// Storage defaults
 	viper.SetDefault("storage.output_dir", "./output")
 	viper.SetDefault("storage.temp_dir", "./tmp")
+
+	// Tracing defaults
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.service_name", "vision-processor")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
+	viper.SetDefault("tracing.otlp_endpoint", "localhost:4318")
+	viper.SetDefault("tracing.insecure", true)
+	viper.SetDefault("tracing.file_path", "")
+
+	// Logging defaults
+	viper.SetDefault("logging.format", "text")
+	viper.SetDefault("logging.level", "info")
+
+	// Pricing defaults
+	viper.SetDefault("pricing.currency", "USD")
 }
 
 func validateConfig(config *Config) error {
@@ -108,10 +288,31 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("batch size must be at least 1")
 	}
 
+	if config.Vision.PrepareWorkers < 1 || config.Vision.PersistWorkers < 1 {
+		return fmt.Errorf("prepare and persist workers must be at least 1")
+	}
+
+	if config.Vision.QueueSize < 1 {
+		return fmt.Errorf("queue size must be at least 1")
+	}
+
+	if config.Vision.MaxPoolSize < config.Vision.PoolSize {
+		return fmt.Errorf("max pool size must be at least the pool size")
+	}
+
 	if config.Vision.RateLimit < 1 {
 		return fmt.Errorf("rate limit must be at least 1")
 	}
 
+	budget := config.Vision.Budget
+	if budget.DailySoftCap < 0 || budget.DailyHardCap < 0 || budget.MonthlySoftCap < 0 || budget.MonthlyHardCap < 0 {
+		return fmt.Errorf("budget caps cannot be negative")
+	}
+
+	if len(config.Vision.Features) == 0 {
+		return fmt.Errorf("at least one vision feature must be enabled")
+	}
+
 	if config.Image.MaxSizeMB < 1 {
 		return fmt.Errorf("max image size must be at least 1MB")
 	}
@@ -124,5 +325,76 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("at least one image format must be allowed")
 	}
 
+	switch config.Image.OutputFormat {
+	case "", "jpeg", "png", "gif", "bmp":
+	default:
+		return fmt.Errorf("image output format must be jpeg, png, gif or bmp")
+	}
+
+	if config.Image.ExtensionMismatch != "override" && config.Image.ExtensionMismatch != "reject" {
+		return fmt.Errorf("image extension mismatch must be override or reject")
+	}
+
+	if config.Image.TargetSizeKB < 0 || config.Image.DownscaleSteps < 0 {
+		return fmt.Errorf("image target size and downscale steps cannot be negative")
+	}
+
+	if config.Image.MaxMegapixels < 0 || config.Image.MaxFrames < 0 || config.Image.DecodeTimeoutSeconds < 0 {
+		return fmt.Errorf("image decode limits cannot be negative")
+	}
+
+	if config.Image.TileSize < 0 || config.Image.TileOverlap < 0 ||
+		(config.Image.TileSize > 0 && config.Image.TileOverlap >= config.Image.TileSize) {
+		return fmt.Errorf("tile overlap must be less than the tile size")
+	}
+
+	if config.Image.TileNMSThreshold <= 0 || config.Image.TileNMSThreshold > 1 {
+		return fmt.Errorf("tile NMS threshold must be between 0 and 1")
+	}
+
+	if config.Image.TileLabelScoring != "max" && config.Image.TileLabelScoring != "mean" {
+		return fmt.Errorf("tile label scoring must be max or mean")
+	}
+
+	switch config.Image.FrameSampling {
+	case "first":
+	case "every", "spaced":
+		if config.Image.FrameCount < 1 {
+			return fmt.Errorf("frame count must be at least 1")
+		}
+	default:
+		return fmt.Errorf("frame sampling must be first, every or spaced")
+	}
+
+	screening := config.Image.Screening
+	if screening.MinSharpness < 0 || screening.MinEntropy < 0 || screening.MinEntropy > 8 {
+		return fmt.Errorf("screening sharpness cannot be negative and entropy must be between 0 and 8")
+	}
+	if screening.MaxDarkLuminance < 0 || screening.MaxDarkLuminance > 255 ||
+		screening.MinBrightLuminance < 0 || screening.MinBrightLuminance > 255 {
+		return fmt.Errorf("screening luminance thresholds must be between 0 and 255")
+	}
+
+	if config.Image.MaxInflightMB < 0 || config.Image.MaxInflightMegapixels < 0 {
+		return fmt.Errorf("in-flight memory limits cannot be negative")
+	}
+
+	if config.Image.DedupThreshold < 0 || config.Image.DedupThreshold > 64 {
+		return fmt.Errorf("dedup threshold must be between 0 and 64")
+	}
+
+	if config.Logging.Format != "text" && config.Logging.Format != "json" {
+		return fmt.Errorf("log format must be text or json")
+	}
+
+	if config.Tracing.Enabled {
+		if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+			return fmt.Errorf("trace sample ratio must be between 0 and 1")
+		}
+		if config.Tracing.OTLPEndpoint == "" && config.Tracing.FilePath == "" {
+			return fmt.Errorf("tracing requires an OTLP endpoint or a file path")
+		}
+	}
+
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,216 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"vision_api/internal/logging"
+	"vision_api/internal/processor"
+)
+
+// RateController changes the API rate limit of a running client
+type RateController interface {
+	// SetRateLimit changes the maximum number of requests per minute
+	SetRateLimit(limit int) error
+
+	// RateLimit returns the maximum number of requests per minute
+	RateLimit() int
+}
+
+// Status is the runtime state reported by the admin endpoint
+type Status struct {
+	Paused    bool `json:"paused"`
+	PoolSize  int  `json:"pool_size"`
+	RateLimit int  `json:"rate_limit"`
+}
+
+// Server exposes runtime control of a processor over HTTP:
+//
+//	GET  /status                    current state
+//	POST /pause                     stop starting new work
+//	POST /resume                    continue after a pause
+//	POST /pool-size?size=N          change the number of concurrent API calls
+//	POST /rate-limit?limit=N        change the requests per minute
+//
+// Every endpoint responds with the resulting Status. With a token, every
+// request must carry it as "Authorization: Bearer <token>"; without one
+// the server only listens on loopback addresses.
+type Server struct {
+	controller processor.Controller
+	rates      RateController
+	token      string
+	logger     *slog.Logger
+}
+
+// NewServer creates an admin server for the given processor and client.
+// An empty token leaves the endpoints unauthenticated.
+func NewServer(controller processor.Controller, rates RateController, token string, logger *slog.Logger) *Server {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Server{
+		controller: controller,
+		rates:      rates,
+		token:      token,
+		logger:     logger.With(logging.ComponentKey, "admin"),
+	}
+}
+
+// Handler returns the HTTP handler of the admin endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/pause", s.post(func(*http.Request) error {
+		s.controller.Pause()
+		return nil
+	}))
+	mux.HandleFunc("/resume", s.post(func(*http.Request) error {
+		s.controller.Resume()
+		return nil
+	}))
+	mux.HandleFunc("/pool-size", s.post(func(r *http.Request) error {
+		size, err := intParam(r, "size")
+		if err != nil {
+			return err
+		}
+		return s.controller.SetPoolSize(size)
+	}))
+	mux.HandleFunc("/rate-limit", s.post(func(r *http.Request) error {
+		limit, err := intParam(r, "limit")
+		if err != nil {
+			return err
+		}
+		return s.rates.SetRateLimit(limit)
+	}))
+	if s.token == "" {
+		return mux
+	}
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests that don't carry the bearer token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	want := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			s.logger.Warn("unauthorized admin request", "path", r.URL.Path, "remote", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Start serves the admin endpoints on addr until ctx is canceled. It
+// returns once the listener is bound so address errors surface early.
+// Without a token, addr must be a loopback address.
+func (s *Server) Start(ctx context.Context, addr string, shutdownTimeout time.Duration) error {
+	if s.token == "" && !isLoopback(addr) {
+		return fmt.Errorf("admin address %s is not a loopback address; set an admin token to serve it", addr)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("admin server error", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	return nil
+}
+
+// Status returns the current runtime state
+func (s *Server) Status() Status {
+	return Status{
+		Paused:    s.controller.Paused(),
+		PoolSize:  s.controller.PoolSize(),
+		RateLimit: s.rates.RateLimit(),
+	}
+}
+
+// handleStatus reports the current state
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.writeStatus(w)
+}
+
+// post wraps a state change into a POST handler that responds with the
+// resulting state
+func (s *Server) post(change func(*http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := change(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Info("runtime settings changed", "path", r.URL.Path, "remote", r.RemoteAddr)
+		s.writeStatus(w)
+	}
+}
+
+// writeStatus writes the current state as JSON
+func (s *Server) writeStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Status()); err != nil {
+		s.logger.Warn("failed to write status", "error", err)
+	}
+}
+
+// intParam parses a positive integer request parameter
+func intParam(r *http.Request, name string) (int, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return 0, fmt.Errorf("missing parameter %q", name)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return n, nil
+}
+
+// isLoopback reports whether addr only listens on loopback interfaces. An
+// empty host listens on all interfaces.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
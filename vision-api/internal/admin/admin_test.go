@@ -0,0 +1,124 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeControls records the runtime settings changed through the server
+type fakeControls struct {
+	paused    bool
+	poolSize  int
+	rateLimit int
+}
+
+func (f *fakeControls) Pause()         { f.paused = true }
+func (f *fakeControls) Resume()        { f.paused = false }
+func (f *fakeControls) Paused() bool   { return f.paused }
+func (f *fakeControls) PoolSize() int  { return f.poolSize }
+func (f *fakeControls) RateLimit() int { return f.rateLimit }
+
+func (f *fakeControls) SetPoolSize(size int) error {
+	if size < 1 {
+		return fmt.Errorf("pool size must be at least 1")
+	}
+	f.poolSize = size
+	return nil
+}
+
+func (f *fakeControls) SetRateLimit(limit int) error {
+	f.rateLimit = limit
+	return nil
+}
+
+func TestHandlerChangesSettings(t *testing.T) {
+	controls := &fakeControls{poolSize: 4, rateLimit: 600}
+	handler := NewServer(controls, controls, "", nil).Handler()
+
+	for _, target := range []string{"/pause", "/pool-size?size=2", "/rate-limit?limit=300"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST %s = %d, want %d", target, rec.Code, http.StatusOK)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var status Status
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("decoding status: %v", err)
+	}
+	if want := (Status{Paused: true, PoolSize: 2, RateLimit: 300}); status != want {
+		t.Errorf("status = %+v, want %+v", status, want)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/pool-size?size=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /pool-size?size=0 = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerRequiresToken(t *testing.T) {
+	controls := &fakeControls{}
+	handler := NewServer(controls, controls, "secret", nil).Handler()
+
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/pause", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Authorization %q = %d, want %d", header, rec.Code, want)
+		}
+	}
+	if !controls.paused {
+		t.Error("authorized pause was not applied")
+	}
+}
+
+func TestStartRequiresLoopbackWithoutToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	controls := &fakeControls{}
+	server := NewServer(controls, controls, "", nil)
+	for _, addr := range []string{":0", "0.0.0.0:0", "192.0.2.1:0"} {
+		if err := server.Start(ctx, addr, time.Second); err == nil {
+			t.Errorf("Start(%q) without a token succeeded", addr)
+		}
+	}
+	if err := server.Start(ctx, "127.0.0.1:0", time.Second); err != nil {
+		t.Errorf("Start(127.0.0.1:0) = %v", err)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1:8081": true,
+		"[::1]:8081":     true,
+		"localhost:8081": true,
+		":8081":          false,
+		"0.0.0.0:8081":   false,
+		"10.0.0.5:8081":  false,
+		"127.0.0.1":      false,
+	}
+	for addr, want := range cases {
+		if got := isLoopback(addr); got != want {
+			t.Errorf("isLoopback(%q) = %t, want %t", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package budget
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"vision_api/internal/utils"
+	"vision_api/pkg/vision"
+)
+
+// Options contains configuration for spend tracking. A cap of zero is
+// disabled.
+type Options struct {
+	// Prices converts billable units into spend
+	Prices vision.PriceTable
+
+	// DailySoftCap and MonthlySoftCap log a warning once reached
+	DailySoftCap   float64
+	MonthlySoftCap float64
+
+	// DailyHardCap and MonthlyHardCap stop new images once reached
+	DailyHardCap   float64
+	MonthlyHardCap float64
+
+	// StatePath is the file the running totals are persisted to; empty
+	// keeps them in memory only
+	StatePath string
+
+	// Logger receives cap warnings
+	Logger *slog.Logger
+}
+
+// OptionFunc is a function that configures Options
+type OptionFunc func(*Options)
+
+// defaultOptions returns the default budget options
+func defaultOptions() *Options {
+	return &Options{
+		Prices: vision.DefaultPriceTable(),
+		Logger: slog.Default(),
+	}
+}
+
+// WithPrices sets the price table used to compute spend
+func WithPrices(prices vision.PriceTable) OptionFunc {
+	return func(o *Options) {
+		if prices != nil {
+			o.Prices = prices
+		}
+	}
+}
+
+// WithDailyCaps sets the soft and hard caps per calendar day
+func WithDailyCaps(soft, hard float64) OptionFunc {
+	return func(o *Options) {
+		o.DailySoftCap = soft
+		o.DailyHardCap = hard
+	}
+}
+
+// WithMonthlyCaps sets the soft and hard caps per calendar month
+func WithMonthlyCaps(soft, hard float64) OptionFunc {
+	return func(o *Options) {
+		o.MonthlySoftCap = soft
+		o.MonthlyHardCap = hard
+	}
+}
+
+// WithStatePath sets the file the running totals are persisted to
+func WithStatePath(path string) OptionFunc {
+	return func(o *Options) {
+		o.StatePath = path
+	}
+}
+
+// WithLogger sets the logger for cap warnings
+func WithLogger(logger *slog.Logger) OptionFunc {
+	return func(o *Options) {
+		if logger != nil {
+			o.Logger = logger
+		}
+	}
+}
+
+// validate checks if the options are valid
+func (o *Options) validate() error {
+	caps := []float64{o.DailySoftCap, o.DailyHardCap, o.MonthlySoftCap, o.MonthlyHardCap}
+	for _, c := range caps {
+		if c < 0 {
+			return fmt.Errorf("budget caps cannot be negative")
+		}
+	}
+	if o.DailySoftCap > 0 && o.DailyHardCap > 0 && o.DailySoftCap > o.DailyHardCap {
+		return fmt.Errorf("daily soft cap cannot exceed the hard cap")
+	}
+	if o.MonthlySoftCap > 0 && o.MonthlyHardCap > 0 && o.MonthlySoftCap > o.MonthlyHardCap {
+		return fmt.Errorf("monthly soft cap cannot exceed the hard cap")
+	}
+	return o.Prices.Validate()
+}
+
+// State is the persisted running total of billable units
+type State struct {
+	Day        string                       `json:"day"`
+	Month      string                       `json:"month"`
+	DayUnits   map[vision.FeatureType]int64 `json:"day_units"`
+	MonthUnits map[vision.FeatureType]int64 `json:"month_units"`
+	UpdatedAt  time.Time                    `json:"updated_at"`
+}
+
+// clone returns a copy of the state that shares no maps with it
+func (s State) clone() State {
+	clone := s
+	clone.DayUnits = make(map[vision.FeatureType]int64, len(s.DayUnits))
+	for feature, units := range s.DayUnits {
+		clone.DayUnits[feature] = units
+	}
+	clone.MonthUnits = make(map[vision.FeatureType]int64, len(s.MonthUnits))
+	for feature, units := range s.MonthUnits {
+		clone.MonthUnits[feature] = units
+	}
+	return clone
+}
+
+// Budget counts billable units as API calls succeed and enforces the
+// configured spend caps. It implements vision.Observer.
+type Budget struct {
+	mu      sync.Mutex
+	saveMu  sync.Mutex
+	options *Options
+	state   State
+	dirty   bool
+	warned  map[string]string
+	now     func() time.Time
+}
+
+// New creates a budget, resuming the totals stored at the state path
+func New(opts ...OptionFunc) (*Budget, error) {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if err := options.validate(); err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	b := &Budget{
+		options: options,
+		warned:  make(map[string]string),
+		now:     time.Now,
+	}
+
+	if options.StatePath != "" {
+		if _, err := os.Stat(options.StatePath); err == nil {
+			if err := utils.LoadJSON(options.StatePath, &b.state); err != nil {
+				return nil, fmt.Errorf("failed to load budget state: %w", err)
+			}
+		}
+	}
+	b.rollover()
+
+	return b, nil
+}
+
+// ObserveCall implements vision.Observer. Every successful call is one
+// billable unit of its feature; the totals are persisted by Save.
+func (b *Budget) ObserveCall(feature vision.FeatureType, statusCode int, duration time.Duration, bytes int64) {
+	if statusCode != 200 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+	b.state.DayUnits[feature]++
+	b.state.MonthUnits[feature]++
+	b.state.UpdatedAt = b.now()
+	b.dirty = true
+
+	b.checkCaps()
+}
+
+// Save persists the running totals to the state path if they changed since
+// the last save. The file is written without holding the lock API calls
+// are counted under, so calls don't wait for the disk.
+func (b *Budget) Save() error {
+	if b.options.StatePath == "" {
+		return nil
+	}
+
+	// Saves are serialized so an older snapshot never overwrites a newer one
+	b.saveMu.Lock()
+	defer b.saveMu.Unlock()
+
+	b.mu.Lock()
+	if !b.dirty {
+		b.mu.Unlock()
+		return nil
+	}
+	state := b.state.clone()
+	b.dirty = false
+	b.mu.Unlock()
+
+	if err := utils.SaveJSON(b.options.StatePath, state); err != nil {
+		b.mu.Lock()
+		b.dirty = true
+		b.mu.Unlock()
+		return fmt.Errorf("failed to save budget state: %w", err)
+	}
+	return nil
+}
+
+// ObserveRetry implements vision.Observer
+func (b *Budget) ObserveRetry(feature vision.FeatureType) {}
+
+// ObserveRateLimitWait implements vision.Observer
+func (b *Budget) ObserveRateLimitWait(wait time.Duration) {}
+
+// Exhausted reports whether a hard cap has been reached
+func (b *Budget) Exhausted() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+	day, month := b.spend()
+	return reached(day, b.options.DailyHardCap) || reached(month, b.options.MonthlyHardCap)
+}
+
+// Spend returns the spend of the current day and month
+func (b *Budget) Spend() (day, month float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+	return b.spend()
+}
+
+// rollover resets the totals when a new day or month has started
+func (b *Budget) rollover() {
+	now := b.now()
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+
+	if b.state.Month != month || b.state.MonthUnits == nil {
+		b.state.Month = month
+		b.state.MonthUnits = make(map[vision.FeatureType]int64)
+	}
+	if b.state.Day != day || b.state.DayUnits == nil {
+		b.state.Day = day
+		b.state.DayUnits = make(map[vision.FeatureType]int64)
+	}
+}
+
+// spend prices the current totals. Units of the day are priced after the
+// units used earlier in the month so tier boundaries are respected. If a
+// total can't be priced it is treated as infinite so hard caps still hold.
+func (b *Budget) spend() (day, month float64) {
+	_, month, err := b.options.Prices.Estimate(b.state.MonthUnits, nil)
+	if err != nil {
+		return math.Inf(1), math.Inf(1)
+	}
+
+	earlier := make(map[vision.FeatureType]int64, len(b.state.MonthUnits))
+	for feature, units := range b.state.MonthUnits {
+		earlier[feature] = units - b.state.DayUnits[feature]
+	}
+	_, day, err = b.options.Prices.Estimate(b.state.DayUnits, earlier)
+	if err != nil {
+		return math.Inf(1), month
+	}
+
+	return day, month
+}
+
+// checkCaps logs each cap the first time it is reached in its period
+func (b *Budget) checkCaps() {
+	day, month := b.spend()
+
+	caps := []struct {
+		name   string
+		period string
+		spend  float64
+		limit  float64
+	}{
+		{"daily soft cap", b.state.Day, day, b.options.DailySoftCap},
+		{"daily hard cap", b.state.Day, day, b.options.DailyHardCap},
+		{"monthly soft cap", b.state.Month, month, b.options.MonthlySoftCap},
+		{"monthly hard cap", b.state.Month, month, b.options.MonthlyHardCap},
+	}
+
+	for _, c := range caps {
+		if !reached(c.spend, c.limit) || b.warned[c.name] == c.period {
+			continue
+		}
+		b.warned[c.name] = c.period
+		b.options.Logger.Warn("budget "+c.name+" reached",
+			"period", c.period,
+			"spend", c.spend,
+			"cap", c.limit,
+		)
+	}
+}
+
+// reached reports whether spend has reached an enabled cap
+func reached(spend, limit float64) bool {
+	return limit > 0 && spend >= limit
+}
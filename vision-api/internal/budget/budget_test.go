@@ -0,0 +1,136 @@
+package budget
+
+import (
+	"io"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vision_api/internal/utils"
+	"vision_api/pkg/vision"
+)
+
+// flatPrices charges 1 per unit of label detection
+var flatPrices = vision.PriceTable{
+	vision.LabelDetection: {{UpTo: 0, PricePer1000: 1000}},
+}
+
+func newTestBudget(t *testing.T, now *time.Time, opts ...OptionFunc) *Budget {
+	t.Helper()
+	opts = append([]OptionFunc{
+		WithPrices(flatPrices),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	b, err := New(opts...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	b.now = func() time.Time { return *now }
+	b.rollover()
+	return b
+}
+
+func TestHardCaps(t *testing.T) {
+	now := time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)
+	b := newTestBudget(t, &now, WithDailyCaps(0, 3), WithMonthlyCaps(0, 5))
+
+	for i := 0; i < 3; i++ {
+		if b.Exhausted() {
+			t.Fatalf("Exhausted() after %d calls, want false", i)
+		}
+		b.ObserveCall(vision.LabelDetection, 200, time.Second, 100)
+	}
+	// Failed calls are not billed
+	b.ObserveCall(vision.LabelDetection, 500, time.Second, 100)
+
+	if day, month := b.Spend(); day != 3 || month != 3 {
+		t.Errorf("Spend() = %g, %g; want 3, 3", day, month)
+	}
+	if !b.Exhausted() {
+		t.Error("Exhausted() = false at the daily hard cap")
+	}
+
+	// A new month resets both totals
+	now = now.Add(2 * time.Hour)
+	if b.Exhausted() {
+		t.Error("Exhausted() = true after the month rolled over")
+	}
+	if day, month := b.Spend(); day != 0 || month != 0 {
+		t.Errorf("Spend() after rollover = %g, %g; want 0, 0", day, month)
+	}
+}
+
+func TestMonthlyCapAcrossDays(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	b := newTestBudget(t, &now, WithMonthlyCaps(0, 4))
+
+	for day := 0; day < 4; day++ {
+		b.ObserveCall(vision.LabelDetection, 200, time.Second, 1)
+		now = now.AddDate(0, 0, 1)
+	}
+	if day, month := b.Spend(); day != 0 || month != 4 {
+		t.Errorf("Spend() = %g, %g; want 0, 4", day, month)
+	}
+	if !b.Exhausted() {
+		t.Error("Exhausted() = false at the monthly hard cap")
+	}
+}
+
+func TestUnpricedFeatureExhausts(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	b := newTestBudget(t, &now, WithDailyCaps(0, 1000))
+
+	b.ObserveCall(vision.ObjectLocalization, 200, time.Second, 1)
+	if day, _ := b.Spend(); !math.IsInf(day, 1) {
+		t.Errorf("Spend() of an unpriced feature = %g, want +Inf", day)
+	}
+	if !b.Exhausted() {
+		t.Error("Exhausted() = false for spend that can't be priced")
+	}
+}
+
+func TestSaveAndResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.json")
+	// New resumes the totals of the current day
+	now := time.Now()
+	b := newTestBudget(t, &now, WithStatePath(path))
+
+	b.ObserveCall(vision.LabelDetection, 200, time.Second, 1)
+	b.ObserveCall(vision.LabelDetection, 200, time.Second, 1)
+	if err := b.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	var state State
+	if err := utils.LoadJSON(path, &state); err != nil {
+		t.Fatalf("loading state: %v", err)
+	}
+	if today := now.Format("2006-01-02"); state.DayUnits[vision.LabelDetection] != 2 || state.Day != today {
+		t.Errorf("saved state = %+v, want 2 label units on %s", state, today)
+	}
+
+	// Mutating the budget after a save leaves the saved snapshot alone
+	b.ObserveCall(vision.LabelDetection, 200, time.Second, 1)
+	if state.DayUnits[vision.LabelDetection] != 2 {
+		t.Error("saved state shares its maps with the budget")
+	}
+
+	resumed := newTestBudget(t, &now, WithStatePath(path))
+	if day, month := resumed.Spend(); day != 2 || month != 2 {
+		t.Errorf("resumed Spend() = %g, %g; want 2, 2", day, month)
+	}
+}
+
+func TestValidateCaps(t *testing.T) {
+	for name, opt := range map[string]OptionFunc{
+		"negative":            WithDailyCaps(-1, 0),
+		"daily soft > hard":   WithDailyCaps(10, 5),
+		"monthly soft > hard": WithMonthlyCaps(10, 5),
+	} {
+		if _, err := New(opt); err == nil {
+			t.Errorf("New() with %s caps succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+
+	"vision_api/internal/utils"
+)
+
+// downscaleFactor is the size of an image after a downscale step relative
+// to the step before
+const downscaleFactor = 0.75
+
+// Compression describes how Process encoded an image
+type Compression struct {
+	Format Format
+
+	// Quality is the JPEG quality, or 0 if the image wasn't encoded as JPEG
+	Quality int
+
+	// Size is the encoded size in bytes
+	Size int64
+
+	// Scale is the width of the result relative to the upright source
+	Scale float64
+}
+
+// Processed is the reader returned by StandardHandler.Process
+type Processed struct {
+	*bytes.Reader
+	Compression Compression
+}
+
+// newProcessed wraps encoded image data
+func newProcessed(data []byte, compression Compression) *Processed {
+	return &Processed{Reader: bytes.NewReader(data), Compression: compression}
+}
+
+// fitBudget encodes img as JPEG within budget bytes. size is the encoded
+// size at maxQuality and seeds the quality search. While even minQuality
+// is too large, the image is downscaled up to the configured number of
+// steps. Scale is relative to img.
+func (h *StandardHandler) fitBudget(ctx context.Context, img image.Image, budget int64, maxQuality int, size int64) ([]byte, Compression, error) {
+	bounds := img.Bounds()
+	scaled := img
+	scale := 1.0
+	seed := h.GetOptimalQuality(size, budget)
+
+	for step := 0; ; step++ {
+		encoded, quality, smallest, err := searchQuality(ctx, scaled, budget, maxQuality, seed)
+		if err != nil {
+			return nil, Compression{}, err
+		}
+		if encoded != nil {
+			return encoded, Compression{Format: JPEG, Quality: quality, Size: int64(len(encoded)), Scale: scale}, nil
+		}
+		if step >= h.config.DownscaleSteps {
+			return nil, Compression{}, fmt.Errorf("%w: %d bytes at the lowest quality exceeds limit of %d",
+				utils.ErrImageTooLarge, smallest, budget)
+		}
+
+		// Each step resamples img so the losses don't add up
+		scale *= downscaleFactor
+		width := max(1, int(math.Round(float64(bounds.Dx())*scale)))
+		height := max(1, int(math.Round(float64(bounds.Dy())*scale)))
+		scaled = imaging.Resize(img, width, height, imaging.Lanczos)
+		seed = 0
+	}
+}
+
+// searchQuality binary searches the highest JPEG quality up to maxQuality
+// whose encoding of img fits budget, probing seed first if it is in range.
+// If no quality fits, it returns nil and the size at the lowest quality.
+func searchQuality(ctx context.Context, img image.Image, budget int64, maxQuality, seed int) ([]byte, int, int64, error) {
+	var best []byte
+	var quality int
+	var smallest int64
+
+	low, high := min(minQuality, maxQuality), maxQuality
+	for probe := seed; low <= high; probe = 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, 0, err
+		}
+		if probe < low || probe > high {
+			probe = (low + high) / 2
+		}
+
+		encoded, err := encode(img, JPEG, probe)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if int64(len(encoded)) <= budget {
+			best, quality = encoded, probe
+			low = probe + 1
+		} else {
+			smallest = int64(len(encoded))
+			high = probe - 1
+		}
+	}
+	return best, quality, smallest, nil
+}
@@ -0,0 +1,333 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNoEXIF is returned when an image carries no EXIF data
+var ErrNoEXIF = errors.New("no EXIF data")
+
+// EXIF contains the camera and capture details of an image
+type EXIF struct {
+	Make       string     `json:"make,omitempty"`
+	Model      string     `json:"model,omitempty"`
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+
+	// ExposureTime is in seconds and FocalLength in millimeters
+	ExposureTime float64 `json:"exposure_time,omitempty"`
+	FNumber      float64 `json:"f_number,omitempty"`
+	ISO          int     `json:"iso,omitempty"`
+	FocalLength  float64 `json:"focal_length,omitempty"`
+
+	// Orientation is the EXIF orientation, 1 to 8; 1 is upright
+	Orientation int `json:"orientation,omitempty"`
+
+	GPS *GPS `json:"gps,omitempty"`
+}
+
+// GPS is the location an image was captured at, in decimal degrees
+type GPS struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+// EXIF tags read from the image directories
+const (
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagExposureTime     = 0x829a
+	tagFNumber          = 0x829d
+	tagISO              = 0x8827
+	tagDateTimeOriginal = 0x9003
+	tagOffsetOriginal   = 0x9011
+	tagFocalLength      = 0x920a
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+	tagGPSAltitudeRef  = 0x0005
+	tagGPSAltitude     = 0x0006
+)
+
+// exifTimeLayout is the layout of EXIF timestamps
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// ReadEXIF extracts the EXIF data of a JPEG, TIFF or PNG image. Capture
+// times without an offset tag are read as UTC.
+func ReadEXIF(data []byte) (*EXIF, error) {
+	tiff, err := exifSegment(data)
+	if err != nil {
+		return nil, err
+	}
+
+	order := tiffByteOrder(tiff)
+	if order == nil {
+		return nil, fmt.Errorf("invalid EXIF header")
+	}
+	ifd0, err := readIFD(tiff, order, order.Uint32(tiff[4:]))
+	if err != nil {
+		return nil, err
+	}
+
+	exif := &EXIF{
+		Make:        ifd0.string(tagMake),
+		Model:       ifd0.string(tagModel),
+		Orientation: int(ifd0.uint(tagOrientation)),
+	}
+	captured := ifd0.string(tagDateTime)
+	offset := ""
+
+	if pointer := ifd0.uint(tagExifIFD); pointer != 0 {
+		sub, err := readIFD(tiff, order, pointer)
+		if err != nil {
+			return nil, err
+		}
+		exif.ExposureTime = sub.rational(tagExposureTime, 0)
+		exif.FNumber = sub.rational(tagFNumber, 0)
+		exif.ISO = int(sub.uint(tagISO))
+		exif.FocalLength = sub.rational(tagFocalLength, 0)
+		if original := sub.string(tagDateTimeOriginal); original != "" {
+			captured = original
+			offset = sub.string(tagOffsetOriginal)
+		}
+	}
+	if t, ok := parseEXIFTime(captured, offset); ok {
+		exif.CapturedAt = &t
+	}
+
+	if pointer := ifd0.uint(tagGPSIFD); pointer != 0 {
+		gps, err := readIFD(tiff, order, pointer)
+		if err != nil {
+			return nil, err
+		}
+		exif.GPS = gps.location()
+	}
+
+	return exif, nil
+}
+
+// exifSegment returns the TIFF structured EXIF block of an image
+func exifSegment(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		return jpegEXIF(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return pngEXIF(data)
+	case tiffByteOrder(data) != nil:
+		return data, nil
+	default:
+		return nil, ErrNoEXIF
+	}
+}
+
+// jpegEXIF finds the APP1 Exif segment among the JPEG markers before the
+// image data
+func jpegEXIF(data []byte) ([]byte, error) {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return nil, fmt.Errorf("invalid JPEG marker at offset %d", i)
+		}
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 {
+			break // start of scan or end of image
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil, fmt.Errorf("truncated JPEG segment at offset %d", i)
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+		i += 2 + length
+	}
+	return nil, ErrNoEXIF
+}
+
+// pngEXIF finds the eXIf chunk of a PNG image
+func pngEXIF(data []byte) ([]byte, error) {
+	for i := 8; i+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		kind := string(data[i+4 : i+8])
+		if i+12+length > len(data) {
+			return nil, fmt.Errorf("truncated PNG chunk at offset %d", i)
+		}
+		switch kind {
+		case "eXIf":
+			return data[i+8 : i+8+length], nil
+		case "IEND":
+			return nil, ErrNoEXIF
+		}
+		i += 12 + length // length, type, data and CRC
+	}
+	return nil, ErrNoEXIF
+}
+
+// parseEXIFTime parses an EXIF timestamp with an optional "+hh:mm" offset
+func parseEXIFTime(value, offset string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if offset != "" {
+		if t, err := time.Parse(exifTimeLayout+"-07:00", value+offset); err == nil {
+			return t, true
+		}
+	}
+	t, err := time.Parse(exifTimeLayout, value)
+	return t, err == nil
+}
+
+// ifd is a parsed image file directory
+type ifd struct {
+	order   binary.ByteOrder
+	entries map[uint16]ifdEntry
+}
+
+// ifdEntry is a directory entry with its value bytes resolved
+type ifdEntry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// typeSizes are the sizes of the TIFF field types in bytes
+var typeSizes = map[uint16]uint32{
+	1:  1, // BYTE
+	2:  1, // ASCII
+	3:  2, // SHORT
+	4:  4, // LONG
+	5:  8, // RATIONAL
+	7:  1, // UNDEFINED
+	9:  4, // SLONG
+	10: 8, // SRATIONAL
+}
+
+// readIFD parses the directory at offset. Entries of unknown types are
+// skipped.
+func readIFD(data []byte, order binary.ByteOrder, offset uint32) (*ifd, error) {
+	start := int64(offset)
+	if start+2 > int64(len(data)) {
+		return nil, fmt.Errorf("EXIF directory offset %d out of range", offset)
+	}
+	count := int64(order.Uint16(data[start:]))
+	if start+2+count*12 > int64(len(data)) {
+		return nil, fmt.Errorf("truncated EXIF directory at offset %d", offset)
+	}
+
+	dir := &ifd{order: order, entries: make(map[uint16]ifdEntry, count)}
+	for i := int64(0); i < count; i++ {
+		raw := data[start+2+i*12:]
+		tag := order.Uint16(raw)
+		entry := ifdEntry{kind: order.Uint16(raw[2:]), count: order.Uint32(raw[4:])}
+
+		size, ok := typeSizes[entry.kind]
+		if !ok {
+			continue
+		}
+		length := int64(size) * int64(entry.count)
+		if length <= 4 {
+			entry.value = raw[8 : 8+length]
+		} else {
+			at := int64(order.Uint32(raw[8:]))
+			if at+length > int64(len(data)) {
+				continue
+			}
+			entry.value = data[at : at+length]
+		}
+		dir.entries[tag] = entry
+	}
+	return dir, nil
+}
+
+// string returns an ASCII value
+func (d *ifd) string(tag uint16) string {
+	entry, ok := d.entries[tag]
+	if !ok || entry.kind != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(entry.value), "\x00"))
+}
+
+// uint returns the first value of an unsigned integer field
+func (d *ifd) uint(tag uint16) uint32 {
+	entry, ok := d.entries[tag]
+	if !ok || len(entry.value) == 0 {
+		return 0
+	}
+	switch entry.kind {
+	case 1, 7:
+		return uint32(entry.value[0])
+	case 3:
+		return uint32(d.order.Uint16(entry.value))
+	case 4:
+		return d.order.Uint32(entry.value)
+	default:
+		return 0
+	}
+}
+
+// rational returns the i-th value of a rational field
+func (d *ifd) rational(tag uint16, i int) float64 {
+	entry, ok := d.entries[tag]
+	if !ok || (entry.kind != 5 && entry.kind != 10) || len(entry.value) < (i+1)*8 {
+		return 0
+	}
+	value := entry.value[i*8:]
+	if entry.kind == 10 {
+		num, den := int32(d.order.Uint32(value)), int32(d.order.Uint32(value[4:]))
+		if den == 0 {
+			return 0
+		}
+		return float64(num) / float64(den)
+	}
+	num, den := d.order.Uint32(value), d.order.Uint32(value[4:])
+	if den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+// location reads the coordinates of a GPS directory
+func (d *ifd) location() *GPS {
+	if _, ok := d.entries[tagGPSLatitude]; !ok {
+		return nil
+	}
+	if _, ok := d.entries[tagGPSLongitude]; !ok {
+		return nil
+	}
+
+	gps := &GPS{
+		Latitude:  d.degrees(tagGPSLatitude),
+		Longitude: d.degrees(tagGPSLongitude),
+	}
+	if d.string(tagGPSLatitudeRef) == "S" {
+		gps.Latitude = -gps.Latitude
+	}
+	if d.string(tagGPSLongitudeRef) == "W" {
+		gps.Longitude = -gps.Longitude
+	}
+	if _, ok := d.entries[tagGPSAltitude]; ok {
+		altitude := d.rational(tagGPSAltitude, 0)
+		if d.uint(tagGPSAltitudeRef) == 1 {
+			altitude = -altitude // below sea level
+		}
+		gps.Altitude = &altitude
+	}
+	return gps
+}
+
+// degrees converts a degrees, minutes and seconds field to decimal degrees
+func (d *ifd) degrees(tag uint16) float64 {
+	return d.rational(tag, 0) + d.rational(tag, 1)/60 + d.rational(tag, 2)/3600
+}
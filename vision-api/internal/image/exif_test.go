@@ -0,0 +1,160 @@
+package image
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestReadEXIF(t *testing.T) {
+	orientation := tiffEntry{tag: tagOrientation, kind: 3, count: 1, value: OrientationRotate90}
+	// The value of a two-entry directory at offset 8 starts right after it
+	valueAt := 8 + ifdSize(2)
+
+	tests := []struct {
+		name            string
+		data            []byte
+		wantErr         bool
+		wantMake        string
+		wantOrientation int
+	}{
+		{
+			name: "inline and offset values",
+			data: append(appendIFD(tiffHeader(8), []tiffEntry{
+				{tag: tagMake, kind: 2, count: 6, value: valueAt},
+				orientation,
+			}, 0), "Canon\x00"...),
+			wantMake:        "Canon",
+			wantOrientation: OrientationRotate90,
+		},
+		{
+			name:    "first directory out of range",
+			data:    tiffHeader(1 << 20),
+			wantErr: true,
+		},
+		{
+			name:    "truncated directory",
+			data:    appendIFD(tiffHeader(8), []tiffEntry{orientation}, 0)[:8+2+6],
+			wantErr: true,
+		},
+		{
+			name:    "entry count beyond data",
+			data:    binary.LittleEndian.AppendUint16(tiffHeader(8), 0xffff),
+			wantErr: true,
+		},
+		{
+			name: "oversized value count is skipped",
+			data: append(appendIFD(tiffHeader(8), []tiffEntry{
+				{tag: tagMake, kind: 2, count: 0xffffffff, value: valueAt},
+				orientation,
+			}, 0), "Canon\x00"...),
+			wantOrientation: OrientationRotate90,
+		},
+		{
+			name: "value offset beyond data is skipped",
+			data: appendIFD(tiffHeader(8), []tiffEntry{
+				{tag: tagMake, kind: 2, count: 6, value: 0xfffffff0},
+				orientation,
+			}, 0),
+			wantOrientation: OrientationRotate90,
+		},
+		{
+			name: "unknown field type is skipped",
+			data: appendIFD(tiffHeader(8), []tiffEntry{
+				{tag: tagMake, kind: 99, count: 1, value: 0},
+				orientation,
+			}, 0),
+			wantOrientation: OrientationRotate90,
+		},
+		{
+			name: "sub-directories pointing back at the first",
+			data: appendIFD(tiffHeader(8), []tiffEntry{
+				orientation,
+				{tag: tagExifIFD, kind: 4, count: 1, value: 8},
+				{tag: tagGPSIFD, kind: 4, count: 1, value: 8},
+			}, 8),
+			wantOrientation: OrientationRotate90,
+		},
+		{
+			name: "sub-directory out of range",
+			data: appendIFD(tiffHeader(8), []tiffEntry{
+				{tag: tagExifIFD, kind: 4, count: 1, value: 1 << 20},
+			}, 0),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exif, err := ReadEXIF(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ReadEXIF() = %+v, want error", exif)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadEXIF() error = %v", err)
+			}
+			if exif.Make != tt.wantMake {
+				t.Errorf("Make = %q, want %q", exif.Make, tt.wantMake)
+			}
+			if exif.Orientation != tt.wantOrientation {
+				t.Errorf("Orientation = %d, want %d", exif.Orientation, tt.wantOrientation)
+			}
+		})
+	}
+}
+
+func TestReadEXIFContainers(t *testing.T) {
+	tiff := appendIFD(tiffHeader(8), []tiffEntry{
+		{tag: tagOrientation, kind: 3, count: 1, value: OrientationRotate180},
+	}, 0)
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xff, 0xd8, 0xff, 0xe1}
+	jpeg = binary.BigEndian.AppendUint16(jpeg, uint16(len(app1)+2))
+	jpeg = append(jpeg, app1...)
+	jpeg = append(jpeg, 0xff, 0xda)
+
+	png := []byte("\x89PNG\r\n\x1a\n")
+	png = binary.BigEndian.AppendUint32(png, uint32(len(tiff)))
+	png = append(png, "eXIf"...)
+	png = append(png, tiff...)
+	png = append(png, 0, 0, 0, 0) // CRC
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+		wantIs  error
+	}{
+		{name: "jpeg", data: jpeg},
+		{name: "png", data: png},
+		{name: "jpeg without exif", data: []byte{0xff, 0xd8, 0xff, 0xda}, wantErr: true, wantIs: ErrNoEXIF},
+		{name: "truncated jpeg segment", data: []byte{0xff, 0xd8, 0xff, 0xe1, 0xff, 0xff, 0x00}, wantErr: true},
+		{name: "truncated png chunk", data: append([]byte("\x89PNG\r\n\x1a\n"), 0xff, 0xff, 0xff, 0xff, 'e', 'X', 'I', 'f'), wantErr: true},
+		{name: "unknown format", data: []byte("not an image"), wantErr: true, wantIs: ErrNoEXIF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exif, err := ReadEXIF(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ReadEXIF() = %+v, want error", exif)
+				}
+				if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+					t.Fatalf("ReadEXIF() error = %v, want %v", err, tt.wantIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadEXIF() error = %v", err)
+			}
+			if exif.Orientation != OrientationRotate180 {
+				t.Errorf("Orientation = %d, want %d", exif.Orientation, OrientationRotate180)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"vision_api/internal/utils"
+)
+
+// FrameStrategy selects which frames of an animated GIF are annotated
+type FrameStrategy string
+
+const (
+	// FramesFirst annotates only the first frame
+	FramesFirst FrameStrategy = "first"
+
+	// FramesEvery annotates every Nth frame, starting with the first
+	FramesEvery FrameStrategy = "every"
+
+	// FramesSpaced annotates K frames spaced evenly across the animation,
+	// including the first and last
+	FramesSpaced FrameStrategy = "spaced"
+)
+
+// IsValid reports whether the strategy is supported
+func (s FrameStrategy) IsValid() bool {
+	return s == FramesFirst || s == FramesEvery || s == FramesSpaced
+}
+
+// FrameOptions controls how frames of animated GIFs are sampled
+type FrameOptions struct {
+	Strategy FrameStrategy
+
+	// Count is N for FramesEvery and K for FramesSpaced
+	Count int
+}
+
+// Frame is a sampled frame of an animation, encoded for upload
+type Frame struct {
+	*Processed
+
+	// Index is the position of the frame in the animation, starting at 0
+	Index int
+}
+
+// Frames implements FrameHandler.Frames. Each sampled frame is composited
+// as it is displayed, honoring the disposal of earlier frames, and encoded
+// as Process would encode a whole image. Images that aren't GIFs have a
+// single frame.
+func (h *StandardHandler) Frames(ctx context.Context, input io.Reader, sampling FrameOptions, opts ProcessOptions) ([]Frame, int, error) {
+	if !sampling.Strategy.IsValid() {
+		return nil, 0, fmt.Errorf("%w: unsupported frame strategy %q", utils.ErrInvalidInput, sampling.Strategy)
+	}
+	opts = h.withDefaults(opts)
+
+	data, err := readLimited(input, h.config.MaxImageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, format, err := image.DecodeConfig(bytes.NewReader(data)); err != nil || Format(format) != GIF {
+		return nil, 1, nil
+	}
+
+	animation, err := h.config.Limits.decodeGIF(ctx, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(animation.Image)
+	target := encodingFor(h.outputFormat(GIF, opts))
+
+	sampled := make(map[int]bool)
+	for _, index := range SampleFrames(total, sampling) {
+		sampled[index] = true
+	}
+
+	var frames []Frame
+	canvas := image.NewRGBA(image.Rect(0, 0, animation.Config.Width, animation.Config.Height))
+	for i, frame := range animation.Image {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(animation.Disposal) {
+			disposal = animation.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if sampled[i] {
+			processed, err := h.encodeFitted(ctx, canvas, target, opts)
+			if err != nil {
+				return nil, 0, fmt.Errorf("frame %d: %w", i, err)
+			}
+			frames = append(frames, Frame{Processed: processed, Index: i})
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, total, nil
+}
+
+// SampleFrames returns the indexes of the frames a strategy picks from an
+// animation with total frames, in order
+func SampleFrames(total int, sampling FrameOptions) []int {
+	if total < 1 {
+		return nil
+	}
+
+	switch {
+	case sampling.Strategy == FramesEvery && sampling.Count > 0:
+		var indexes []int
+		for i := 0; i < total; i += sampling.Count {
+			indexes = append(indexes, i)
+		}
+		return indexes
+	case sampling.Strategy == FramesSpaced && sampling.Count > 1 && total > 1:
+		count := min(sampling.Count, total)
+		indexes := make([]int, count)
+		for i := range indexes {
+			indexes[i] = i * (total - 1) / (count - 1)
+		}
+		return indexes
+	default:
+		return []int{0}
+	}
+}
+
+// FrameCount returns the number of frames of an image without decoding
+// them. Only GIFs can have more than one.
+func FrameCount(r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read image: %w", err)
+	}
+	if _, format, err := image.DecodeConfig(bytes.NewReader(data)); err != nil || Format(format) != GIF {
+		return 1, nil
+	}
+
+	return gifFrames(data)
+}
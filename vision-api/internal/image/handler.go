@@ -15,6 +15,7 @@ const (
 	GIF  Format = "gif"
 	BMP  Format = "bmp"
 	WEBP Format = "webp"
+	TIFF Format = "tiff"
 )
 
 // Dimensions represents image dimensions
@@ -30,6 +31,7 @@ type Metadata struct {
 	Dimensions Dimensions
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
+	EXIF       *EXIF
 	Extra      map[string]interface{}
 }
 
@@ -90,12 +92,43 @@ type CompressHandler interface {
 	GetOptimalQuality(currentSize, targetSize int64) int
 }
 
+// TileHandler defines the interface for splitting large images into tiles
+type TileHandler interface {
+	// Tile splits an image into overlapping tiles processed like whole
+	// images and returns them with the dimensions of the upright image
+	Tile(ctx context.Context, input io.Reader, tiling TileOptions, opts ProcessOptions) ([]Tile, Dimensions, error)
+}
+
+// FrameHandler defines the interface for sampling frames of animations
+type FrameHandler interface {
+	// Frames returns the sampled frames of an animated image, processed
+	// like whole images, and the number of frames in the animation
+	Frames(ctx context.Context, input io.Reader, sampling FrameOptions, opts ProcessOptions) ([]Frame, int, error)
+}
+
+// QualityHandler defines the interface for screening image quality
+type QualityHandler interface {
+	// AnalyzeQuality computes the blur, exposure and detail scores of an image
+	AnalyzeQuality(ctx context.Context, input io.Reader) (*QualityScores, error)
+}
+
+// HashHandler defines the interface for perceptual hashing
+type HashHandler interface {
+	// DifferenceHash decodes an image within the decode limits and computes
+	// its difference hash
+	DifferenceHash(ctx context.Context, input io.Reader) (PerceptualHash, error)
+}
+
 // Handler combines all image handling interfaces
 type Handler interface {
 	ImageHandler
 	ResizeHandler
 	ValidationHandler
 	CompressHandler
+	TileHandler
+	FrameHandler
+	QualityHandler
+	HashHandler
 }
 
 // Option represents a functional option for configuring handlers
@@ -108,6 +141,10 @@ type handlerConfig struct {
 	DefaultQuality  int
 	SupportedTypes  []Format
 	PreserveFormat  bool
+	OutputFormat    Format
+	TargetSize      int64
+	DownscaleSteps  int
+	Limits          DecodeLimits
 }
 
 // NewHandlerConfig creates a new handler configuration with defaults
@@ -116,8 +153,9 @@ func NewHandlerConfig() *handlerConfig {
 		MaxImageSize:   40 * 1024 * 1024, // 40MB
 		MaxDimensions:  Dimensions{Width: 4096, Height: 4096},
 		DefaultQuality: 85,
-		SupportedTypes: []Format{JPEG, PNG, GIF, BMP},
+		SupportedTypes: []Format{JPEG, PNG, GIF, BMP, WEBP, TIFF},
 		PreserveFormat: true,
+		Limits:         DefaultDecodeLimits,
 	}
 }
 
@@ -156,4 +194,53 @@ func WithPreserveFormat(preserve bool) Option {
 	return func(c *handlerConfig) {
 		c.PreserveFormat = preserve
 	}
+}
+
+// WithOutputFormat sets the format prepared images are encoded in. An
+// empty format keeps the source format where it can be encoded.
+func WithOutputFormat(format Format) Option {
+	return func(c *handlerConfig) {
+		c.OutputFormat = format
+		c.PreserveFormat = format == ""
+	}
+}
+
+// WithTargetSize sets the byte budget of processed images. Zero uses the
+// maximum image size.
+func WithTargetSize(size int64) Option {
+	return func(c *handlerConfig) {
+		c.TargetSize = size
+	}
+}
+
+// WithDownscaleSteps sets how many times an image that doesn't fit the
+// byte budget at the lowest quality is downscaled
+func WithDownscaleSteps(steps int) Option {
+	return func(c *handlerConfig) {
+		c.DownscaleSteps = steps
+	}
+}
+
+// WithMaxPixels sets the largest number of pixels decoded for an image,
+// counting every frame of an animation. Larger images are rejected from
+// their header.
+func WithMaxPixels(pixels int64) Option {
+	return func(c *handlerConfig) {
+		c.Limits.MaxPixels = pixels
+	}
+}
+
+// WithMaxFrames sets the largest number of frames of an animation
+func WithMaxFrames(frames int) Option {
+	return func(c *handlerConfig) {
+		c.Limits.MaxFrames = frames
+	}
+}
+
+// WithDecodeTimeout sets how long decoding an image may take. Zero
+// disables the timeout.
+func WithDecodeTimeout(timeout time.Duration) Option {
+	return func(c *handlerConfig) {
+		c.Limits.Timeout = timeout
+	}
 }
\ No newline at end of file
@@ -0,0 +1,231 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/gif"
+	"runtime"
+	"time"
+
+	"vision_api/internal/utils"
+)
+
+// DecodeLimits bound the memory and time spent decoding untrusted images
+type DecodeLimits struct {
+	// MaxPixels is the largest number of pixels decoded for an image,
+	// counting every frame of an animation
+	MaxPixels int64
+
+	// MaxFrames is the largest number of frames of an animation
+	MaxFrames int
+
+	// Timeout bounds each decode; zero disables it
+	Timeout time.Duration
+}
+
+// DefaultDecodeLimits are the limits of handlers without other limits and
+// of the package level decoders. GIF frames take one byte per pixel, so
+// the pixel limit also fits most animations.
+var DefaultDecodeLimits = DecodeLimits{
+	MaxPixels: 200 * 1000 * 1000,
+	MaxFrames: 1000,
+	Timeout:   30 * time.Second,
+}
+
+// checkConfig reads the header of an image and rejects it if its pixels
+// exceed the limit, before any pixel data is decoded
+func (l DecodeLimits) checkConfig(data []byte) (image.Config, Format, error) {
+	var config image.Config
+	var name string
+	err := recoverPanic(func() (err error) {
+		config, name, err = image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%w: failed to decode image header: %v", utils.ErrInvalidInput, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return config, "", err
+	}
+
+	if config.Width < 1 || config.Height < 1 {
+		return config, "", fmt.Errorf("%w: image has no pixels", utils.ErrInvalidInput)
+	}
+	if pixels := int64(config.Width) * int64(config.Height); l.MaxPixels > 0 && pixels > l.MaxPixels {
+		return config, "", fmt.Errorf("%w: %dx%d image exceeds %d pixels",
+			utils.ErrDecodeLimit, config.Width, config.Height, l.MaxPixels)
+	}
+	return config, Format(name), nil
+}
+
+// decode decodes the first frame of an image within the limits
+func (l DecodeLimits) decode(ctx context.Context, data []byte) (image.Image, Format, error) {
+	if _, _, err := l.checkConfig(data); err != nil {
+		return nil, "", err
+	}
+
+	type decoded struct {
+		img  image.Image
+		name string
+	}
+	result, err := runDecode(ctx, l.Timeout, func() (decoded, error) {
+		img, name, err := image.Decode(bytes.NewReader(data))
+		return decoded{img, name}, err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return result.img, Format(result.name), nil
+}
+
+// decodeGIF decodes every frame of a GIF within the limits. The frames are
+// counted before any of them is decoded.
+func (l DecodeLimits) decodeGIF(ctx context.Context, data []byte) (*gif.GIF, error) {
+	config, _, err := l.checkConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	frames, err := gifFrames(data)
+	if err != nil {
+		return nil, err
+	}
+	if l.MaxFrames > 0 && frames > l.MaxFrames {
+		return nil, fmt.Errorf("%w: %d frames exceed limit of %d", utils.ErrDecodeLimit, frames, l.MaxFrames)
+	}
+	if pixels := int64(frames) * int64(config.Width) * int64(config.Height); l.MaxPixels > 0 && pixels > l.MaxPixels {
+		return nil, fmt.Errorf("%w: %d frames of %dx%d exceed %d pixels",
+			utils.ErrDecodeLimit, frames, config.Width, config.Height, l.MaxPixels)
+	}
+
+	return runDecode(ctx, l.Timeout, func() (*gif.GIF, error) {
+		return gif.DecodeAll(bytes.NewReader(data))
+	})
+}
+
+// decodeSlots limits the decoders running at once. A slot is held until
+// the decoder goroutine exits, including decoders abandoned after a
+// timeout, so their allocations count against the limit until they are
+// freed and timeouts can't pile up unbounded background decodes.
+var decodeSlots = make(chan struct{}, runtime.NumCPU())
+
+// runDecode runs a decoder in its own goroutine so a codec panic is
+// reported as a malformed image, and gives up waiting once ctx is done or
+// the timeout passes. An abandoned decoder finishes in the background and
+// keeps its decode slot until then. Waiting for a slot ends only with ctx.
+func runDecode[T any](ctx context.Context, timeout time.Duration, decode func() (T, error)) (T, error) {
+	type outcome struct {
+		value T
+		err   error
+	}
+
+	var zero T
+	select {
+	case decodeSlots <- struct{}{}:
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() { <-decodeSlots }()
+
+		var result outcome
+		result.err = recoverPanic(func() error {
+			value, err := decode()
+			if err != nil {
+				return fmt.Errorf("%w: failed to decode image: %v", utils.ErrInvalidInput, err)
+			}
+			result.value = value
+			return nil
+		})
+		done <- result
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case result := <-done:
+		return result.value, result.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case <-expired:
+		return zero, fmt.Errorf("%w: decoding took longer than %s", utils.ErrDecodeLimit, timeout)
+	}
+}
+
+// recoverPanic calls fn and turns a panic into an error
+func recoverPanic(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: codec panic: %v", utils.ErrMalformedImage, r)
+		}
+	}()
+	return fn()
+}
+
+// gifFrames counts the frames of a GIF by walking its blocks, without
+// decoding any image data
+func gifFrames(data []byte) (int, error) {
+	if len(data) < 13 || !(bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a"))) {
+		return 0, fmt.Errorf("%w: not a GIF", utils.ErrInvalidInput)
+	}
+
+	// Header, logical screen descriptor and global color table
+	i := 13 + colorTableSize(data[10])
+	if i > len(data) {
+		return 0, fmt.Errorf("%w: truncated GIF color table", utils.ErrMalformedImage)
+	}
+	frames := 0
+	for i < len(data) {
+		var err error
+		switch data[i] {
+		case 0x21: // extension: introducer, label and data sub-blocks
+			i, err = skipSubBlocks(data, i+2)
+		case 0x2c: // image descriptor, local color table, LZW code size and data
+			if i+10 > len(data) {
+				return frames, fmt.Errorf("%w: truncated GIF image descriptor", utils.ErrMalformedImage)
+			}
+			i, err = skipSubBlocks(data, i+10+colorTableSize(data[i+9])+1)
+			frames++
+		case 0x3b: // trailer
+			return frames, nil
+		default:
+			return frames, fmt.Errorf("%w: unknown GIF block 0x%02x", utils.ErrMalformedImage, data[i])
+		}
+		if err != nil {
+			return frames, err
+		}
+	}
+	return frames, nil
+}
+
+// colorTableSize returns the size of the color table flagged in a GIF
+// descriptor's packed field
+func colorTableSize(flags byte) int {
+	if flags&0x80 == 0 {
+		return 0
+	}
+	return 3 << (flags&0x07 + 1)
+}
+
+// skipSubBlocks returns the offset after the data sub-blocks starting at i
+func skipSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return i, fmt.Errorf("%w: truncated GIF data", utils.ErrMalformedImage)
+		}
+		size := int(data[i])
+		i += size + 1
+		if size == 0 {
+			return i, nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package image
+
+import (
+	"errors"
+	"testing"
+
+	"vision_api/internal/utils"
+)
+
+// gifHeader returns a GIF header and logical screen descriptor with the
+// given packed flags, followed by the global color table they call for
+func gifHeader(flags byte) []byte {
+	data := append([]byte("GIF89a"), 1, 0, 1, 0, flags, 0, 0)
+	return append(data, make([]byte, colorTableSize(flags))...)
+}
+
+// gifFrame returns an image descriptor with the given packed flags, its
+// local color table and one data sub-block
+func gifFrame(flags byte) []byte {
+	data := []byte{0x2c, 0, 0, 0, 0, 1, 0, 1, 0, flags}
+	data = append(data, make([]byte, colorTableSize(flags))...)
+	return append(data, 2, 1, 0x44, 0)
+}
+
+// gifExtension is a graphic control extension
+var gifExtension = []byte{0x21, 0xf9, 4, 0, 0, 0, 0, 0}
+
+// concat joins byte slices
+func concat(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
+
+func TestGIFFrames(t *testing.T) {
+	trailer := []byte{0x3b}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    int
+		wantErr error
+	}{
+		{name: "no frames", data: concat(gifHeader(0), trailer), want: 0},
+		{name: "one frame", data: concat(gifHeader(0), gifFrame(0), trailer), want: 1},
+		{
+			name: "frames with extensions",
+			data: concat(gifHeader(0), gifExtension, gifFrame(0), gifExtension, gifFrame(0), gifExtension, gifFrame(0), trailer),
+			want: 3,
+		},
+		{name: "global color table", data: concat(gifHeader(0x81), gifFrame(0), trailer), want: 1},
+		{name: "local color table", data: concat(gifHeader(0), gifFrame(0x87), gifFrame(0), trailer), want: 2},
+		{name: "missing trailer", data: concat(gifHeader(0), gifFrame(0), gifFrame(0)), want: 2},
+		{name: "data after trailer", data: concat(gifHeader(0), gifFrame(0), trailer, []byte{0xff}), want: 1},
+		{name: "not a gif", data: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x00\x00"), wantErr: utils.ErrInvalidInput},
+		{name: "truncated header", data: []byte("GIF89a"), wantErr: utils.ErrInvalidInput},
+		{name: "truncated color table", data: gifHeader(0x87)[:20], wantErr: utils.ErrMalformedImage},
+		{name: "truncated descriptor", data: concat(gifHeader(0), gifFrame(0)[:6]), wantErr: utils.ErrMalformedImage},
+		{name: "unterminated sub-blocks", data: concat(gifHeader(0), gifFrame(0)[:13]), wantErr: utils.ErrMalformedImage},
+		{name: "sub-block past the end", data: concat(gifHeader(0), gifExtension[:3], []byte{0xff}), wantErr: utils.ErrMalformedImage},
+		{name: "unknown block", data: concat(gifHeader(0), []byte{0x99}), wantErr: utils.ErrMalformedImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gifFrames(tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("gifFrames() = %d, %v, want %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("gifFrames() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("gifFrames() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package image
+
+import (
+	"context"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// EXIF orientations, named after the transform that makes the stored
+// pixels upright
+const (
+	OrientationNormal     = 1
+	OrientationFlipH      = 2
+	OrientationRotate180  = 3
+	OrientationFlipV      = 4
+	OrientationTranspose  = 5
+	OrientationRotate90   = 6 // clockwise
+	OrientationTransverse = 7
+	OrientationRotate270  = 8 // clockwise
+)
+
+// Orient applies an EXIF orientation to img so it is upright. Unknown
+// orientations leave img unchanged.
+func Orient(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case OrientationFlipH:
+		return imaging.FlipH(img)
+	case OrientationRotate180:
+		return imaging.Rotate180(img)
+	case OrientationFlipV:
+		return imaging.FlipV(img)
+	case OrientationTranspose:
+		return imaging.Transpose(img)
+	case OrientationRotate90:
+		return imaging.Rotate270(img) // imaging rotates counter-clockwise
+	case OrientationTransverse:
+		return imaging.Transverse(img)
+	case OrientationRotate270:
+		return imaging.Rotate90(img)
+	default:
+		return img
+	}
+}
+
+// SourcePoint maps a normalized point of an image made upright by Orient
+// back to the stored pixels of the original image
+func SourcePoint(orientation int, x, y float64) (float64, float64) {
+	switch orientation {
+	case OrientationFlipH:
+		return 1 - x, y
+	case OrientationRotate180:
+		return 1 - x, 1 - y
+	case OrientationFlipV:
+		return x, 1 - y
+	case OrientationTranspose:
+		return y, x
+	case OrientationRotate90:
+		return y, 1 - x
+	case OrientationTransverse:
+		return 1 - y, 1 - x
+	case OrientationRotate270:
+		return 1 - y, x
+	default:
+		return x, y
+	}
+}
+
+// orientation returns the EXIF orientation of encoded image data, or
+// OrientationNormal if it has none
+func orientation(data []byte) int {
+	exif, err := ReadEXIF(data)
+	if err != nil || exif.Orientation < OrientationNormal || exif.Orientation > OrientationRotate270 {
+		return OrientationNormal
+	}
+	return exif.Orientation
+}
+
+// decodeOriented decodes data within the limits and makes the image
+// upright. The returned orientation is the one that was applied.
+func decodeOriented(ctx context.Context, data []byte, limits DecodeLimits) (image.Image, Format, int, error) {
+	img, format, err := limits.decode(ctx, data)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	applied := orientation(data)
+	return Orient(img, applied), format, applied, nil
+}
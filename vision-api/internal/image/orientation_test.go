@@ -0,0 +1,71 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSourcePoint(t *testing.T) {
+	tests := []struct {
+		orientation int
+		wantX       float64
+		wantY       float64
+	}{
+		{orientation: OrientationNormal, wantX: 0.2, wantY: 0.1},
+		{orientation: OrientationFlipH, wantX: 0.8, wantY: 0.1},
+		{orientation: OrientationRotate180, wantX: 0.8, wantY: 0.9},
+		{orientation: OrientationFlipV, wantX: 0.2, wantY: 0.9},
+		{orientation: OrientationTranspose, wantX: 0.1, wantY: 0.2},
+		{orientation: OrientationRotate90, wantX: 0.1, wantY: 0.8},
+		{orientation: OrientationTransverse, wantX: 0.9, wantY: 0.8},
+		{orientation: OrientationRotate270, wantX: 0.9, wantY: 0.2},
+		{orientation: 0, wantX: 0.2, wantY: 0.1},
+	}
+
+	for _, tt := range tests {
+		x, y := SourcePoint(tt.orientation, 0.2, 0.1)
+		if !near(x, tt.wantX) || !near(y, tt.wantY) {
+			t.Errorf("SourcePoint(%d, 0.2, 0.1) = (%g, %g), want (%g, %g)", tt.orientation, x, y, tt.wantX, tt.wantY)
+		}
+	}
+}
+
+// TestSourcePointInvertsOrient checks that every pixel of an image made
+// upright by Orient maps back to the source pixel it was copied from
+func TestSourcePointInvertsOrient(t *testing.T) {
+	const width, height = 3, 2
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			src.Set(x, y, color.NRGBA{R: uint8(x * 80), G: uint8(y * 80), A: 255})
+		}
+	}
+
+	for orientation := OrientationNormal; orientation <= OrientationRotate270; orientation++ {
+		upright := Orient(src, orientation)
+		bounds := upright.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				// Map the pixel center back to the source
+				sx, sy := SourcePoint(orientation,
+					(float64(x-bounds.Min.X)+0.5)/float64(bounds.Dx()),
+					(float64(y-bounds.Min.Y)+0.5)/float64(bounds.Dy()))
+				px, py := int(sx*width), int(sy*height)
+
+				got := color.NRGBAModel.Convert(upright.At(x, y))
+				want := src.At(px, py)
+				if got != want {
+					t.Errorf("orientation %d: pixel (%d, %d) maps to (%d, %d) holding %v, want %v",
+						orientation, x, y, px, py, want, got)
+				}
+			}
+		}
+	}
+}
+
+// near reports whether two coordinates are equal up to rounding
+func near(a, b float64) bool {
+	const epsilon = 1e-9
+	return a-b < epsilon && b-a < epsilon
+}
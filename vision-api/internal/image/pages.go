@@ -0,0 +1,133 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"vision_api/internal/utils"
+)
+
+const (
+	// tiffHeaderSize is the size of a classic TIFF header
+	tiffHeaderSize = 8
+
+	// maxPages bounds the directory walk of corrupt files
+	maxPages = 10000
+)
+
+// PageCount returns the number of pages of an image. Only TIFF files can
+// have more than one page; other formats count as one.
+func PageCount(r io.ReaderAt) (int, error) {
+	_, offsets, err := tiffDirectories(r)
+	if err != nil {
+		return 0, err
+	}
+	return max(1, len(offsets)), nil
+}
+
+// OpenPage opens one page of a multi-page TIFF file, starting at 0. The
+// page is read as a TIFF stream whose first directory is that page, so
+// the regular decoders see a single-page image.
+func OpenPage(path string, page int) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	reader, err := pageReader(file, page)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, file}, nil
+}
+
+// pageReader returns the TIFF stream of one page of file
+func pageReader(file *os.File, page int) (io.Reader, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	order, offsets, err := tiffDirectories(file)
+	if err != nil {
+		return nil, err
+	}
+	if page < 0 || page >= len(offsets) {
+		return nil, fmt.Errorf("%w: page %d of %d-page image", utils.ErrInvalidInput, page, len(offsets))
+	}
+
+	// Point the header at the page's directory; the rest of the file is
+	// read unchanged since directories address data by absolute offset
+	header := make([]byte, tiffHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read TIFF header: %w", err)
+	}
+	order.PutUint32(header[4:], uint32(offsets[page]))
+
+	rest := io.NewSectionReader(file, tiffHeaderSize, stat.Size()-tiffHeaderSize)
+	return io.MultiReader(bytes.NewReader(header), rest), nil
+}
+
+// tiffDirectories returns the byte order and the offsets of the image
+// file directories of a TIFF file, one per page. Other formats have none.
+func tiffDirectories(r io.ReaderAt) (binary.ByteOrder, []int64, error) {
+	header := make([]byte, tiffHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to read image header: %w", err)
+	}
+
+	order := tiffByteOrder(header)
+	if order == nil {
+		return nil, nil, nil
+	}
+
+	var offsets []int64
+	seen := make(map[int64]bool)
+	buf := make([]byte, 4)
+	for offset := int64(order.Uint32(header[4:])); offset != 0; {
+		if seen[offset] || len(offsets) == maxPages {
+			return nil, nil, fmt.Errorf("%w: corrupt TIFF directory chain", utils.ErrInvalidInput)
+		}
+		seen[offset] = true
+		offsets = append(offsets, offset)
+
+		// A directory is an entry count, 12-byte entries and the offset of
+		// the next directory
+		if _, err := r.ReadAt(buf[:2], offset); err != nil {
+			return nil, nil, fmt.Errorf("%w: failed to read TIFF directory: %v", utils.ErrInvalidInput, err)
+		}
+		entries := int64(order.Uint16(buf[:2]))
+		if _, err := r.ReadAt(buf, offset+2+entries*12); err != nil {
+			return nil, nil, fmt.Errorf("%w: failed to read TIFF directory: %v", utils.ErrInvalidInput, err)
+		}
+		offset = int64(order.Uint32(buf))
+	}
+	return order, offsets, nil
+}
+
+// tiffByteOrder returns the byte order of a classic TIFF header, or nil if
+// data doesn't start with one
+func tiffByteOrder(data []byte) binary.ByteOrder {
+	if len(data) < tiffHeaderSize {
+		return nil
+	}
+	switch string(data[:4]) {
+	case "II*\x00":
+		return binary.LittleEndian
+	case "MM\x00*":
+		return binary.BigEndian
+	default:
+		return nil
+	}
+}
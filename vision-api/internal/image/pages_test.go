@@ -0,0 +1,84 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"vision_api/internal/utils"
+)
+
+// tiffEntry is a directory entry of a test TIFF. value holds the inline
+// value or the offset of the value bytes.
+type tiffEntry struct {
+	tag, kind uint16
+	count     uint32
+	value     uint32
+}
+
+// tiffHeader returns a little-endian TIFF header pointing at the first directory
+func tiffHeader(first uint32) []byte {
+	return binary.LittleEndian.AppendUint32([]byte("II*\x00"), first)
+}
+
+// appendIFD appends a directory with the given entries and next directory offset
+func appendIFD(data []byte, entries []tiffEntry, next uint32) []byte {
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(entries)))
+	for _, e := range entries {
+		data = binary.LittleEndian.AppendUint16(data, e.tag)
+		data = binary.LittleEndian.AppendUint16(data, e.kind)
+		data = binary.LittleEndian.AppendUint32(data, e.count)
+		data = binary.LittleEndian.AppendUint32(data, e.value)
+	}
+	return binary.LittleEndian.AppendUint32(data, next)
+}
+
+// ifdSize returns the size of a directory with n entries
+func ifdSize(n int) uint32 {
+	return uint32(2 + 12*n + 4)
+}
+
+func TestPageCount(t *testing.T) {
+	// Empty directories at offset 8 and right after it
+	second := 8 + ifdSize(0)
+
+	pages := func(data []byte) int {
+		t.Helper()
+		n, err := PageCount(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("PageCount() error = %v", err)
+		}
+		return n
+	}
+
+	// Anything that isn't a TIFF is a single page
+	if n := pages([]byte("\x89PNG\r\n\x1a\n")); n != 1 {
+		t.Errorf("PageCount() of a PNG = %d, want 1", n)
+	}
+	if n := pages([]byte("II*")); n != 1 {
+		t.Errorf("PageCount() of a short header = %d, want 1", n)
+	}
+	if n := pages(appendIFD(tiffHeader(8), nil, 0)); n != 1 {
+		t.Errorf("PageCount() of one directory = %d, want 1", n)
+	}
+	if n := pages(appendIFD(appendIFD(tiffHeader(8), nil, second), nil, 0)); n != 2 {
+		t.Errorf("PageCount() of two directories = %d, want 2", n)
+	}
+}
+
+func TestPageCountRejectsMalformedChains(t *testing.T) {
+	second := 8 + ifdSize(0)
+	malformed := map[string][]byte{
+		"directory pointing at itself": appendIFD(tiffHeader(8), nil, 8),
+		"cycle over two directories":   appendIFD(appendIFD(tiffHeader(8), nil, second), nil, 8),
+		"first directory out of range": tiffHeader(1 << 20),
+		"entry count beyond data":      append(tiffHeader(8), 0xff, 0xff),
+		"next offset truncated":        appendIFD(tiffHeader(8), nil, 0)[:8+2+2],
+	}
+	for name, data := range malformed {
+		if n, err := PageCount(bytes.NewReader(data)); !errors.Is(err, utils.ErrInvalidInput) {
+			t.Errorf("%s: PageCount() = %d, %v, want invalid input error", name, n, err)
+		}
+	}
+}
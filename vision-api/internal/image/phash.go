@@ -0,0 +1,75 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+
+	"github.com/disintegration/imaging"
+)
+
+// hashWidth and hashHeight define the grid sampled by the difference hash.
+// One extra column is needed so every row yields 8 horizontal comparisons.
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// PerceptualHash is a 64-bit difference hash (dHash) of an image.
+// Visually similar images produce hashes with a small Hamming distance,
+// which survives resizing, re-encoding and mild color adjustments.
+type PerceptualHash uint64
+
+// DifferenceHash decodes an image within the configured size and decode
+// limits and computes its difference hash
+func (h *StandardHandler) DifferenceHash(ctx context.Context, input io.Reader) (PerceptualHash, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	data, err := readLimited(input, h.config.MaxImageSize)
+	if err != nil {
+		return 0, err
+	}
+	img, _, err := h.config.Limits.decode(ctx, data)
+	if err != nil {
+		return 0, err
+	}
+
+	return ComputeDifferenceHash(img), nil
+}
+
+// ComputeDifferenceHash computes the difference hash of a decoded image
+func ComputeDifferenceHash(img image.Image) PerceptualHash {
+	// Shrink to a 9x8 grayscale thumbnail; the box filter averages all source
+	// pixels, which makes the hash robust against resampling artifacts
+	small := imaging.Grayscale(imaging.Resize(img, hashWidth, hashHeight, imaging.Box))
+
+	var hash PerceptualHash
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			left := small.Pix[small.PixOffset(x, y)]
+			right := small.Pix[small.PixOffset(x+1, y)]
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// Distance returns the Hamming distance between two hashes
+func (h PerceptualHash) Distance(other PerceptualHash) int {
+	return bits.OnesCount64(uint64(h ^ other))
+}
+
+// String returns the hash as a 16 digit hexadecimal string
+func (h PerceptualHash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
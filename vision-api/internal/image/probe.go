@@ -0,0 +1,28 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"io"
+)
+
+// Probe reads only the image header and returns its dimensions and format
+// without decoding any pixel data
+func Probe(input io.Reader) (Dimensions, Format, error) {
+	var config image.Config
+	var format string
+	err := recoverPanic(func() (err error) {
+		config, format, err = image.DecodeConfig(input)
+		return err
+	})
+	if err != nil {
+		return Dimensions{}, "", fmt.Errorf("failed to read image header: %w", err)
+	}
+
+	return Dimensions{Width: config.Width, Height: config.Height}, Format(format), nil
+}
+
+// Pixels returns the number of pixels covered by the dimensions
+func (d Dimensions) Pixels() int64 {
+	return int64(d.Width) * int64(d.Height)
+}
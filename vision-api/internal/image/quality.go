@@ -0,0 +1,189 @@
+package image
+
+import (
+	"context"
+	"image"
+	"io"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// analysisSize is the longest edge images are scaled to before their
+	// quality is analyzed, so scores don't depend on the resolution
+	analysisSize = 1024
+
+	// solidTolerance is the largest channel range of a solid color image
+	solidTolerance = 8
+)
+
+// Quality issues reported by QualityThresholds.Check
+const (
+	IssueSolidColor  = "solid_color"
+	IssueTooDark     = "too_dark"
+	IssueOverexposed = "overexposed"
+	IssueLowEntropy  = "low_entropy"
+	IssueBlurry      = "blurry"
+)
+
+// QualityScores describes how useful an image is likely to be for
+// annotation. Luminance is on a 0-255 scale.
+type QualityScores struct {
+	// Sharpness is the variance of the Laplacian of the luminance; blurry
+	// images score low
+	Sharpness float64 `json:"sharpness"`
+
+	MeanLuminance float64 `json:"mean_luminance"`
+
+	// DarkLuminance and BrightLuminance are the 5th and 95th percentiles
+	DarkLuminance   float64 `json:"dark_luminance"`
+	BrightLuminance float64 `json:"bright_luminance"`
+
+	// Entropy is the Shannon entropy of the luminance histogram in bits,
+	// from 0 to 8
+	Entropy float64 `json:"entropy"`
+
+	// SolidColor reports whether every pixel has nearly the same color
+	SolidColor bool `json:"solid_color"`
+}
+
+// QualityThresholds decide which images are not worth annotating. Zero
+// values disable a check.
+type QualityThresholds struct {
+	// MinSharpness flags images with a lower Laplacian variance as blurry
+	MinSharpness float64
+
+	// MaxDarkLuminance flags images whose 95th luminance percentile is
+	// below it as too dark
+	MaxDarkLuminance float64
+
+	// MinBrightLuminance flags images whose 5th luminance percentile is
+	// above it as overexposed
+	MinBrightLuminance float64
+
+	// MinEntropy flags images with less luminance entropy
+	MinEntropy float64
+
+	// SkipSolidColor flags solid color images
+	SkipSolidColor bool
+}
+
+// Check returns the first quality issue of an image, or "" if it passes
+func (t QualityThresholds) Check(scores *QualityScores) string {
+	switch {
+	case t.SkipSolidColor && scores.SolidColor:
+		return IssueSolidColor
+	case t.MaxDarkLuminance > 0 && scores.BrightLuminance < t.MaxDarkLuminance:
+		return IssueTooDark
+	case t.MinBrightLuminance > 0 && scores.DarkLuminance > t.MinBrightLuminance:
+		return IssueOverexposed
+	case t.MinEntropy > 0 && scores.Entropy < t.MinEntropy:
+		return IssueLowEntropy
+	case t.MinSharpness > 0 && scores.Sharpness < t.MinSharpness:
+		return IssueBlurry
+	default:
+		return ""
+	}
+}
+
+// AnalyzeQuality implements QualityHandler.AnalyzeQuality
+func (h *StandardHandler) AnalyzeQuality(ctx context.Context, input io.Reader) (*QualityScores, error) {
+	data, err := readLimited(input, h.config.MaxImageSize)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := h.config.Limits.decode(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return AnalyzeQuality(img), nil
+}
+
+// AnalyzeQuality scores an image after scaling it to at most analysisSize
+// pixels on its longest edge
+func AnalyzeQuality(img image.Image) *QualityScores {
+	bounds := img.Bounds()
+	var pixels *image.NRGBA
+	if bounds.Dx() > analysisSize || bounds.Dy() > analysisSize {
+		pixels = imaging.Fit(img, analysisSize, analysisSize, imaging.Box)
+	} else {
+		pixels = imaging.Clone(img)
+	}
+
+	width, height := pixels.Bounds().Dx(), pixels.Bounds().Dy()
+	if width*height == 0 {
+		return &QualityScores{SolidColor: true}
+	}
+	luminance := make([]float64, width*height)
+	var histogram [256]int
+	low := [3]uint8{255, 255, 255}
+	var high [3]uint8
+	for y := 0; y < height; y++ {
+		row := pixels.Pix[y*pixels.Stride:]
+		for x := 0; x < width; x++ {
+			px := row[x*4 : x*4+3]
+			for c, v := range px {
+				low[c], high[c] = min(low[c], v), max(high[c], v)
+			}
+			l := 0.299*float64(px[0]) + 0.587*float64(px[1]) + 0.114*float64(px[2])
+			luminance[y*width+x] = l
+			histogram[int(math.Round(l))]++
+		}
+	}
+
+	scores := &QualityScores{
+		Sharpness:  laplacianVariance(luminance, width, height),
+		SolidColor: true,
+	}
+	for c := range low {
+		if int(high[c])-int(low[c]) > solidTolerance {
+			scores.SolidColor = false
+		}
+	}
+
+	total := float64(len(luminance))
+	seen := 0
+	for level, count := range histogram {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / total
+		scores.MeanLuminance += p * float64(level)
+		scores.Entropy -= p * math.Log2(p)
+
+		// Percentiles are the first level reaching their share of pixels
+		if float64(seen) < 0.05*total && float64(seen+count) >= 0.05*total {
+			scores.DarkLuminance = float64(level)
+		}
+		if float64(seen) < 0.95*total && float64(seen+count) >= 0.95*total {
+			scores.BrightLuminance = float64(level)
+		}
+		seen += count
+	}
+	return scores
+}
+
+// laplacianVariance returns the variance of the 4-neighbour Laplacian over
+// the interior pixels of a luminance plane
+func laplacianVariance(luminance []float64, width, height int) float64 {
+	if width < 3 || height < 3 {
+		return 0
+	}
+
+	var sum, squares float64
+	n := float64((width - 2) * (height - 2))
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			i := y*width + x
+			v := luminance[i-1] + luminance[i+1] + luminance[i-width] + luminance[i+width] - 4*luminance[i]
+			sum += v
+			squares += v * v
+		}
+	}
+	mean := sum / n
+	return squares/n - mean*mean
+}
@@ -12,6 +12,9 @@ import (
 	"math"
 
 	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/bmp"  // Register BMP format
+	_ "golang.org/x/image/tiff" // Register TIFF format
+	_ "golang.org/x/image/webp" // Register WebP format
 )
 
 // Resizer implements the ResizeHandler interface
@@ -36,16 +39,22 @@ func (r *Resizer) Resize(ctx context.Context, input io.Reader, dimensions Dimens
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
-		return r.resize(input, dimensions)
+		return r.resize(ctx, input, dimensions)
 	}
 }
 
-// FitToSize implements ResizeHandler.FitToSize
+// FitToSize implements ResizeHandler.FitToSize. Dimensions are those of
+// the image made upright according to its EXIF orientation.
 func (r *Resizer) FitToSize(ctx context.Context, input io.Reader, maxDimensions Dimensions) (io.Reader, error) {
+	data, err := readLimited(input, r.config.MaxImageSize)
+	if err != nil {
+		return nil, err
+	}
+
 	// First get the image dimensions
-	img, format, err := image.Decode(input)
+	img, format, applied, err := decodeOriented(ctx, data, r.config.Limits)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		return nil, err
 	}
 
 	bounds := img.Bounds()
@@ -58,16 +67,18 @@ func (r *Resizer) FitToSize(ctx context.Context, input io.Reader, maxDimensions
 	newDims := r.GetResizedDimensions(currentDims, maxDimensions)
 
 	// If no resize needed, return original
-	if newDims == currentDims {
-		return input, nil
+	if newDims == currentDims && applied == OrientationNormal {
+		return bytes.NewReader(data), nil
 	}
 
-	// Perform resize
-	resized := imaging.Resize(img, newDims.Width, newDims.Height, imaging.Lanczos)
+	// Perform resize; an image that was only rotated keeps its size
+	if newDims != currentDims {
+		img = imaging.Resize(img, newDims.Width, newDims.Height, imaging.Lanczos)
+	}
 
 	// Encode the result
 	var buf bytes.Buffer
-	if err := r.encodeImage(resized, format, &buf); err != nil {
+	if err := r.encodeImage(img, string(format), &buf); err != nil {
 		return nil, fmt.Errorf("failed to encode resized image: %w", err)
 	}
 
@@ -103,12 +114,17 @@ func (r *Resizer) GetResizedDimensions(current, max Dimensions) Dimensions {
 	}
 }
 
-// resize performs the actual image resizing
-func (r *Resizer) resize(input io.Reader, dimensions Dimensions) (io.Reader, error) {
+// resize performs the actual image resizing on the upright image
+func (r *Resizer) resize(ctx context.Context, input io.Reader, dimensions Dimensions) (io.Reader, error) {
+	data, err := readLimited(input, r.config.MaxImageSize)
+	if err != nil {
+		return nil, err
+	}
+
 	// Decode image
-	img, format, err := image.Decode(input)
+	img, format, _, err := decodeOriented(ctx, data, r.config.Limits)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		return nil, err
 	}
 
 	// Perform resize using Lanczos resampling
@@ -116,7 +132,7 @@ func (r *Resizer) resize(input io.Reader, dimensions Dimensions) (io.Reader, err
 
 	// Encode the resized image
 	var buf bytes.Buffer
-	if err := r.encodeImage(resized, format, &buf); err != nil {
+	if err := r.encodeImage(resized, string(format), &buf); err != nil {
 		return nil, fmt.Errorf("failed to encode resized image: %w", err)
 	}
 
@@ -127,7 +143,7 @@ func (r *Resizer) resize(input io.Reader, dimensions Dimensions) (io.Reader, err
 func (r *Resizer) encodeImage(img image.Image, format string, w io.Writer) error {
 	switch format {
 	case "jpeg", "jpg":
-		return imaging.Encode(w, img, imaging.JPEG, imaging.Quality(r.config.DefaultQuality))
+		return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(r.config.DefaultQuality))
 	case "png":
 		return imaging.Encode(w, img, imaging.PNG)
 	case "gif":
@@ -0,0 +1,63 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+)
+
+// SniffLen is the number of leading bytes Sniff looks at
+const SniffLen = 18
+
+// Sniff returns the format of an image from the magic bytes at the start
+// of its content, or "" if they match no known format
+func Sniff(header []byte) Format {
+	switch {
+	case bytes.HasPrefix(header, []byte("\xff\xd8\xff")):
+		return JPEG
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return GIF
+	case isBMP(header):
+		return BMP
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return WEBP
+	case tiffByteOrder(header) != nil:
+		return TIFF
+	default:
+		return ""
+	}
+}
+
+// isBMP reports whether header starts with a BMP file header followed by
+// a DIB header of one of the sizes BMP versions use. "BM" alone is too
+// common at the start of text files to identify a bitmap.
+func isBMP(header []byte) bool {
+	if len(header) < 18 || !bytes.HasPrefix(header, []byte("BM")) {
+		return false
+	}
+	switch binary.LittleEndian.Uint32(header[14:18]) {
+	case 12, 40, 56, 108, 124:
+		return true
+	default:
+		return false
+	}
+}
+
+// FormatFromExtension returns the format named by the extension of a
+// filename, ignoring case. Alternative spellings such as .jpg and .tif map
+// to their format; unknown extensions are returned lower-cased without the
+// dot.
+func FormatFromExtension(name string) Format {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	switch ext {
+	case "jpg", "jpe", "jfif":
+		return JPEG
+	case "tif":
+		return TIFF
+	default:
+		return Format(ext)
+	}
+}
@@ -0,0 +1,63 @@
+package image
+
+import "testing"
+
+func TestSniff(t *testing.T) {
+	// Headers mapped to the format they are detected as
+	headers := map[string]Format{
+		"\xff\xd8\xff\xe0\x00\x10JFIF":                                       JPEG,
+		"\x89PNG\r\n\x1a\n\x00\x00\x00\x0d":                                  PNG,
+		"GIF87a\x01\x00\x01\x00":                                             GIF,
+		"GIF89a\x01\x00\x01\x00":                                             GIF,
+		"BM\x36\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00\x28\x00\x00\x00": BMP,
+		"BM\x8a\x00\x00\x00\x00\x00\x00\x00\x8a\x00\x00\x00\x7c\x00\x00\x00": BMP,
+		"RIFF\x24\x00\x00\x00WEBPVP8 ":                                       WEBP,
+		"II*\x00\x08\x00\x00\x00":                                            TIFF,
+		"MM\x00*\x00\x00\x00\x08":                                            TIFF,
+	}
+	for header, want := range headers {
+		if got := Sniff([]byte(header)); got != want {
+			t.Errorf("Sniff(%q) = %q, want %q", header, got, want)
+		}
+	}
+
+	// Truncated signatures and lookalikes are not detected
+	unknown := []string{
+		"BM\x36\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00\x29\x00\x00\x00",
+		"BMW service records",
+		"BM\x36\x00\x00\x00",
+		"RIFF\x24\x00\x00\x00WAVEfmt ",
+		"RIFF\x24\x00\x00\x00WEB",
+		"II*\x00",
+		"\xff\xd8",
+		"hello world!",
+		"",
+	}
+	for _, header := range unknown {
+		if got := Sniff([]byte(header)); got != "" {
+			t.Errorf("Sniff(%q) = %q, want no format", header, got)
+		}
+	}
+}
+
+func TestFormatFromExtension(t *testing.T) {
+	tests := []struct {
+		name string
+		want Format
+	}{
+		{name: "photo.jpg", want: JPEG},
+		{name: "photo.JPEG", want: JPEG},
+		{name: "photo.jfif", want: JPEG},
+		{name: "scan.tif", want: TIFF},
+		{name: "scan.TIFF", want: TIFF},
+		{name: "dir.v2/image.PNG", want: PNG},
+		{name: "image.HEIC", want: "heic"},
+		{name: "noextension", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := FormatFromExtension(tt.name); got != tt.want {
+			t.Errorf("FormatFromExtension(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
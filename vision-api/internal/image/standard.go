@@ -0,0 +1,371 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/disintegration/imaging"
+
+	"vision_api/internal/utils"
+)
+
+// minQuality is the lowest JPEG quality used to fit an image into the
+// maximum size
+const minQuality = 30
+
+// StandardHandler implements Handler with the standard library decoders
+// and the imaging package
+type StandardHandler struct {
+	*Resizer
+}
+
+// NewHandler creates an image handler with the given options
+func NewHandler(opts ...Option) (Handler, error) {
+	config := NewHandlerConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	return &StandardHandler{Resizer: &Resizer{config: config}}, nil
+}
+
+// validate checks if the handler configuration is valid
+func (c *handlerConfig) validate() error {
+	if c.MaxImageSize < 1 {
+		return fmt.Errorf("max image size must be at least 1 byte")
+	}
+	if c.MaxDimensions.Width < 1 || c.MaxDimensions.Height < 1 {
+		return fmt.Errorf("max dimensions must be positive")
+	}
+	if c.DefaultQuality < 1 || c.DefaultQuality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100")
+	}
+	if len(c.SupportedTypes) == 0 {
+		return fmt.Errorf("at least one supported format is required")
+	}
+	if c.TargetSize < 0 {
+		return fmt.Errorf("target size cannot be negative")
+	}
+	if c.DownscaleSteps < 0 {
+		return fmt.Errorf("downscale steps cannot be negative")
+	}
+	if c.Limits.MaxPixels < 0 || c.Limits.MaxFrames < 0 || c.Limits.Timeout < 0 {
+		return fmt.Errorf("decode limits cannot be negative")
+	}
+	if c.OutputFormat != "" && !canEncode(c.OutputFormat) {
+		return fmt.Errorf("cannot encode output format %s", c.OutputFormat)
+	}
+	return nil
+}
+
+// Process implements ImageHandler.Process. The image is made upright
+// according to its EXIF orientation, fitted within the maximum dimensions
+// and, if still larger than opts.MaxSize, encoded as JPEG at the highest
+// quality that fits, downscaling it if needed. Zero values in opts fall
+// back to the handler configuration. An upright image that already fits is
+// returned unchanged; re-encoded images carry no EXIF data, so no
+// orientation tag is left to apply twice. The result is a *Processed
+// recording the encoding.
+func (h *StandardHandler) Process(ctx context.Context, input io.Reader, opts ProcessOptions) (io.Reader, error) {
+	opts = h.withDefaults(opts)
+
+	data, err := readLimited(input, h.config.MaxImageSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	img, format, applied, err := decodeOriented(ctx, data, h.config.Limits)
+	if err != nil {
+		return nil, err
+	}
+	if !h.ValidateFormat(format) {
+		return nil, fmt.Errorf("%w: %s", utils.ErrUnsupportedFormat, format)
+	}
+
+	target := h.outputFormat(format, opts)
+	current := Dimensions{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	resized := h.GetResizedDimensions(current, opts.MaxDimensions)
+
+	if applied == OrientationNormal && resized == current && target == format && int64(len(data)) <= opts.MaxSize {
+		return newProcessed(data, Compression{Format: format, Size: int64(len(data)), Scale: 1}), nil
+	}
+	if !canEncode(target) {
+		// Sources that are passed through unchanged may not be writable
+		target = encodingFor(opts.Format)
+	}
+	return h.encodeFitted(ctx, img, target, opts)
+}
+
+// encodeFitted encodes an upright image in target after fitting it within
+// the maximum dimensions and byte size of opts
+func (h *StandardHandler) encodeFitted(ctx context.Context, img image.Image, target Format, opts ProcessOptions) (*Processed, error) {
+	current := Dimensions{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	resized := h.GetResizedDimensions(current, opts.MaxDimensions)
+	if resized != current {
+		img = imaging.Resize(img, resized.Width, resized.Height, imaging.Lanczos)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	encoded, err := encode(img, target, opts.Quality)
+	if err != nil {
+		return nil, err
+	}
+	compression := Compression{Format: target, Size: int64(len(encoded)), Scale: 1}
+	if target == JPEG {
+		compression.Quality = opts.Quality
+	}
+
+	// Only JPEG can trade quality for size, so other formats are converted
+	if compression.Size > opts.MaxSize {
+		encoded, compression, err = h.fitBudget(ctx, img, opts.MaxSize, opts.Quality, compression.Size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	compression.Scale *= float64(resized.Width) / float64(current.Width)
+
+	return newProcessed(encoded, compression), nil
+}
+
+// GetMetadata implements ImageHandler.GetMetadata. Only the image header
+// and EXIF data are decoded. Timestamps are filled in when input is a file,
+// and the creation time is the EXIF capture time if there is one.
+func (h *StandardHandler) GetMetadata(ctx context.Context, input io.Reader) (*Metadata, error) {
+	data, err := readLimited(input, h.config.MaxImageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	dims, format, err := Probe(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrInvalidInput, err)
+	}
+
+	metadata := &Metadata{
+		Format:     format,
+		Size:       int64(len(data)),
+		Dimensions: dims,
+		Extra:      make(map[string]interface{}),
+	}
+
+	// Images without readable EXIF data still have the other metadata
+	if exif, err := ReadEXIF(data); err == nil {
+		metadata.EXIF = exif
+		if exif.CapturedAt != nil {
+			metadata.CreatedAt = *exif.CapturedAt
+		}
+	}
+	if file, ok := input.(*os.File); ok {
+		if stat, err := file.Stat(); err == nil {
+			metadata.UpdatedAt = stat.ModTime()
+			metadata.Extra["path"] = file.Name()
+		}
+	}
+	return metadata, nil
+}
+
+// ValidateImage implements ValidationHandler.ValidateImage. It checks the
+// size and format and decodes the whole image to detect corrupt data.
+func (h *StandardHandler) ValidateImage(ctx context.Context, input io.Reader) error {
+	data, err := readLimited(input, h.config.MaxImageSize)
+	if err != nil {
+		return err
+	}
+	if err := h.ValidateSize(int64(len(data))); err != nil {
+		return err
+	}
+
+	dims, format, err := Probe(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrInvalidInput, err)
+	}
+	if !h.ValidateFormat(format) {
+		return fmt.Errorf("%w: %s", utils.ErrUnsupportedFormat, format)
+	}
+	if dims.Width < 1 || dims.Height < 1 {
+		return fmt.Errorf("%w: image has no pixels", utils.ErrInvalidInput)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, _, err := h.config.Limits.decode(ctx, data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ValidateSize implements ValidationHandler.ValidateSize
+func (h *StandardHandler) ValidateSize(size int64) error {
+	if size < 1 {
+		return fmt.Errorf("%w: image is empty", utils.ErrInvalidInput)
+	}
+	if size > h.config.MaxImageSize {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", utils.ErrImageTooLarge, size, h.config.MaxImageSize)
+	}
+	return nil
+}
+
+// GetSupportedFormats implements ValidationHandler.GetSupportedFormats
+func (h *StandardHandler) GetSupportedFormats() []Format {
+	return append([]Format(nil), h.config.SupportedTypes...)
+}
+
+// Compress implements CompressHandler.Compress. PNG images are recompressed
+// losslessly; every other format is encoded as JPEG with the given quality.
+// The image is made upright since the EXIF orientation is not kept.
+func (h *StandardHandler) Compress(ctx context.Context, input io.Reader, quality int) (io.Reader, error) {
+	if quality < 1 || quality > 100 {
+		return nil, fmt.Errorf("%w: quality must be between 1 and 100", utils.ErrInvalidInput)
+	}
+
+	data, err := readLimited(input, h.config.MaxImageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	img, format, _, err := decodeOriented(ctx, data, h.config.Limits)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if format == PNG {
+		err = imaging.Encode(&buf, img, imaging.PNG, imaging.PNGCompressionLevel(png.BestCompression))
+	} else {
+		err = imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(quality))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return &buf, nil
+}
+
+// GetOptimalQuality implements CompressHandler.GetOptimalQuality. JPEG size
+// shrinks roughly in proportion to quality over the useful range, so the
+// default quality is scaled by the required reduction. The estimate seeds
+// the quality search of Process.
+func (h *StandardHandler) GetOptimalQuality(currentSize, targetSize int64) int {
+	if currentSize <= 0 || targetSize <= 0 || currentSize <= targetSize {
+		return h.config.DefaultQuality
+	}
+
+	quality := int(float64(h.config.DefaultQuality) * float64(targetSize) / float64(currentSize))
+	return max(minQuality, min(quality, h.config.DefaultQuality))
+}
+
+// withDefaults fills unset process options from the handler configuration
+func (h *StandardHandler) withDefaults(opts ProcessOptions) ProcessOptions {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = h.config.TargetSize
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = h.config.MaxImageSize
+	}
+	if opts.MaxDimensions.Width <= 0 || opts.MaxDimensions.Height <= 0 {
+		opts.MaxDimensions = h.config.MaxDimensions
+	}
+	if opts.Quality <= 0 || opts.Quality > 100 {
+		opts.Quality = h.config.DefaultQuality
+	}
+	if opts.Format == "" {
+		opts.Format = h.config.OutputFormat
+		opts.PreserveFormat = opts.PreserveFormat || h.config.PreserveFormat
+	}
+	return opts
+}
+
+// outputFormat picks the encoding of a processed image: the source format
+// when preserving it and the API accepts it, otherwise the requested format,
+// falling back to JPEG
+func (h *StandardHandler) outputFormat(source Format, opts ProcessOptions) Format {
+	if opts.PreserveFormat && apiAccepts(source) {
+		return source
+	}
+	return encodingFor(opts.Format)
+}
+
+// apiAccepts reports whether the Vision API annotates images in format.
+// TIFF is only accepted by the file annotation endpoints.
+func apiAccepts(format Format) bool {
+	switch format {
+	case JPEG, PNG, GIF, BMP, WEBP:
+		return true
+	default:
+		return false
+	}
+}
+
+// encodingFor returns format if processed images can be written in it and
+// JPEG otherwise
+func encodingFor(format Format) Format {
+	if canEncode(format) {
+		return format
+	}
+	return JPEG
+}
+
+// canEncode reports whether processed images can be written in format
+func canEncode(format Format) bool {
+	switch format {
+	case JPEG, PNG, GIF, BMP:
+		return true
+	default:
+		return false
+	}
+}
+
+// encode writes img in format
+func encode(img image.Image, format Format, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case JPEG:
+		err = imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(quality))
+	case PNG:
+		err = imaging.Encode(&buf, img, imaging.PNG)
+	case GIF:
+		err = imaging.Encode(&buf, img, imaging.GIF)
+	case BMP:
+		err = imaging.Encode(&buf, img, imaging.BMP)
+	default:
+		return nil, fmt.Errorf("%w: cannot encode %s", utils.ErrUnsupportedFormat, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readLimited reads input, failing once it exceeds limit bytes
+func readLimited(input io.Reader, limit int64) ([]byte, error) {
+	if input == nil {
+		return nil, fmt.Errorf("%w: input reader is required", utils.ErrInvalidInput)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(input, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: exceeds limit of %d bytes", utils.ErrImageTooLarge, limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,96 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/disintegration/imaging"
+
+	"vision_api/internal/utils"
+)
+
+// TileOptions controls how images are split into tiles
+type TileOptions struct {
+	// Size is the edge length of the square tiles in source pixels
+	Size int
+
+	// Overlap is the minimum number of pixels neighbouring tiles share, so
+	// objects on a seam are seen whole by at least one tile
+	Overlap int
+}
+
+// Tile is one region of a tiled image, encoded for upload
+type Tile struct {
+	*Processed
+
+	// Bounds is the region of the upright image the tile covers
+	Bounds image.Rectangle
+}
+
+// Tile implements TileHandler.Tile. The image is made upright before it
+// is split, and every tile is fitted and encoded as Process would encode a
+// whole image. Images no larger than one tile yield a single tile.
+func (h *StandardHandler) Tile(ctx context.Context, input io.Reader, tiling TileOptions, opts ProcessOptions) ([]Tile, Dimensions, error) {
+	if tiling.Size < 1 || tiling.Overlap < 0 || tiling.Overlap >= tiling.Size {
+		return nil, Dimensions{}, fmt.Errorf("%w: tile overlap must be less than the tile size", utils.ErrInvalidInput)
+	}
+	opts = h.withDefaults(opts)
+
+	data, err := readLimited(input, h.config.MaxImageSize)
+	if err != nil {
+		return nil, Dimensions{}, err
+	}
+	img, format, _, err := decodeOriented(ctx, data, h.config.Limits)
+	if err != nil {
+		return nil, Dimensions{}, err
+	}
+	if !h.ValidateFormat(format) {
+		return nil, Dimensions{}, fmt.Errorf("%w: %s", utils.ErrUnsupportedFormat, format)
+	}
+
+	bounds := img.Bounds()
+	dims := Dimensions{Width: bounds.Dx(), Height: bounds.Dy()}
+	target := encodingFor(h.outputFormat(format, opts))
+
+	var tiles []Tile
+	for _, y := range tileStarts(dims.Height, tiling) {
+		for _, x := range tileStarts(dims.Width, tiling) {
+			region := image.Rect(x, y, min(x+tiling.Size, dims.Width), min(y+tiling.Size, dims.Height))
+			processed, err := h.encodeFitted(ctx, imaging.Crop(img, region.Add(bounds.Min)), target, opts)
+			if err != nil {
+				return nil, Dimensions{}, fmt.Errorf("tile at %v: %w", region.Min, err)
+			}
+			tiles = append(tiles, Tile{Processed: processed, Bounds: region})
+		}
+	}
+	return tiles, dims, nil
+}
+
+// TileCount returns the number of tiles Tile splits an image of the given
+// dimensions into
+func TileCount(dims Dimensions, tiling TileOptions) int {
+	if tiling.Size < 1 || tiling.Overlap < 0 || tiling.Overlap >= tiling.Size {
+		return 1
+	}
+	return len(tileStarts(dims.Width, tiling)) * len(tileStarts(dims.Height, tiling))
+}
+
+// tileStarts returns the offsets of the tiles along an edge of the given
+// length. Tiles are spread evenly so the last one ends at the edge and
+// neighbours overlap by at least the configured amount.
+func tileStarts(length int, tiling TileOptions) []int {
+	if length <= tiling.Size {
+		return []int{0}
+	}
+
+	stride := tiling.Size - tiling.Overlap
+	count := int(math.Ceil(float64(length-tiling.Size)/float64(stride))) + 1
+	starts := make([]int, count)
+	for i := range starts {
+		starts[i] = int(math.Round(float64(i*(length-tiling.Size)) / float64(count-1)))
+	}
+	return starts
+}
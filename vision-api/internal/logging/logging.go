@@ -0,0 +1,196 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ComponentKey is the attribute that names the package a logger belongs
+// to. Per-component levels are matched against its value.
+const ComponentKey = "component"
+
+// CorrelationKey is the attribute carrying the per-image correlation ID
+const CorrelationKey = "correlation_id"
+
+// Options contains configuration for the logger
+type Options struct {
+	// Format is the output format, text or json
+	Format string
+
+	// Level is the minimum level for components without an override
+	Level slog.Level
+
+	// Levels overrides the level per component
+	Levels map[string]slog.Level
+
+	// Output is where log records are written
+	Output io.Writer
+}
+
+// OptionFunc is a function that configures Options
+type OptionFunc func(*Options)
+
+// defaultOptions returns the default logger options
+func defaultOptions() *Options {
+	return &Options{
+		Format: FormatText,
+		Level:  slog.LevelInfo,
+		Levels: make(map[string]slog.Level),
+		Output: os.Stderr,
+	}
+}
+
+// WithFormat sets the output format
+func WithFormat(format string) OptionFunc {
+	return func(o *Options) {
+		if format != "" {
+			o.Format = format
+		}
+	}
+}
+
+// WithLevel sets the default minimum level
+func WithLevel(level slog.Level) OptionFunc {
+	return func(o *Options) {
+		o.Level = level
+	}
+}
+
+// WithComponentLevel overrides the level of a single component
+func WithComponentLevel(component string, level slog.Level) OptionFunc {
+	return func(o *Options) {
+		o.Levels[component] = level
+	}
+}
+
+// WithOutput sets the writer log records are written to
+func WithOutput(w io.Writer) OptionFunc {
+	return func(o *Options) {
+		if w != nil {
+			o.Output = w
+		}
+	}
+}
+
+// New creates a logger. Records logged with a context carrying a
+// correlation ID are tagged with it.
+func New(opts ...OptionFunc) (*slog.Logger, error) {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// The inner handler accepts everything; levels are enforced per component
+	handlerOpts := &slog.HandlerOptions{Level: slog.Level(-100)}
+
+	var inner slog.Handler
+	switch options.Format {
+	case FormatText:
+		inner = slog.NewTextHandler(options.Output, handlerOpts)
+	case FormatJSON:
+		inner = slog.NewJSONHandler(options.Output, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unsupported log format: %s", options.Format)
+	}
+
+	return slog.New(&handler{
+		inner:  inner,
+		level:  options.Level,
+		levels: options.Levels,
+		def:    options.Level,
+	}), nil
+}
+
+// ParseLevel parses a level name such as debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return level, fmt.Errorf("invalid log level: %s", name)
+	}
+	return level, nil
+}
+
+// handler filters records by the level of the logger's component and adds
+// the correlation ID from the context
+type handler struct {
+	inner  slog.Handler
+	level  slog.Level
+	levels map[string]slog.Level
+	def    slog.Level
+}
+
+// Enabled implements slog.Handler
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+// Handle implements slog.Handler
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := CorrelationID(ctx); id != "" {
+		r.AddAttrs(slog.String(CorrelationKey, id))
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler. A component attribute switches the
+// returned handler to that component's level.
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithAttrs(attrs)
+	for _, attr := range attrs {
+		if attr.Key != ComponentKey {
+			continue
+		}
+		if level, ok := h.levels[attr.Value.String()]; ok {
+			clone.level = level
+		} else {
+			clone.level = h.def
+		}
+	}
+	return &clone
+}
+
+// WithGroup implements slog.Handler
+func (h *handler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithGroup(name)
+	return &clone
+}
+
+// correlationKey is the context key of the correlation ID
+type correlationKey struct{}
+
+// WithCorrelationID returns a context carrying the correlation ID
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, if any
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// NewCorrelationID returns a random 16 character hex ID
+func NewCorrelationID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
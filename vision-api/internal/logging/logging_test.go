@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestComponentLevels(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(
+		WithFormat(FormatJSON),
+		WithLevel(slog.LevelWarn),
+		WithComponentLevel("processor", slog.LevelDebug),
+		WithOutput(&out),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	processor := logger.With(ComponentKey, "processor")
+	vision := logger.With(ComponentKey, "vision")
+
+	processor.Debug("processor debug")
+	vision.Info("vision info")
+	vision.Warn("vision warn")
+	logger.Info("root info")
+
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record struct {
+			Msg string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid JSON record %q: %v", line, err)
+		}
+		messages = append(messages, record.Msg)
+	}
+	if got, want := strings.Join(messages, ","), "processor debug,vision warn"; got != want {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(WithOutput(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := NewCorrelationID()
+	if len(id) != 16 {
+		t.Fatalf("NewCorrelationID() = %q, want 16 hex characters", id)
+	}
+	ctx := WithCorrelationID(context.Background(), id)
+	if got := CorrelationID(ctx); got != id {
+		t.Errorf("CorrelationID() = %q, want %q", got, id)
+	}
+
+	logger.InfoContext(ctx, "tagged")
+	logger.Info("untagged")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if !strings.Contains(lines[0], CorrelationKey+"="+id) {
+		t.Errorf("tagged record %q lacks the correlation ID", lines[0])
+	}
+	if strings.Contains(lines[1], CorrelationKey) {
+		t.Errorf("untagged record %q has a correlation ID", lines[1])
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		got, err := ParseLevel(name)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(verbose) succeeded")
+	}
+	if _, err := New(WithFormat("xml")); err == nil {
+		t.Error("New() accepted an unsupported format")
+	}
+}
@@ -0,0 +1,276 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vision_api/internal/utils"
+	"vision_api/pkg/dataset"
+)
+
+// version is the manifest format version
+const version = 1
+
+// Status describes how a file relates to its manifest entry
+type Status string
+
+const (
+	// StatusUnchanged means the file and the relevant config are unchanged
+	StatusUnchanged Status = "unchanged"
+	// StatusNew means the file has no entry
+	StatusNew Status = "new"
+	// StatusModified means the file content changed
+	StatusModified Status = "modified"
+	// StatusConfigChanged means the config the file was processed with changed
+	StatusConfigChanged Status = "config_changed"
+	// StatusIncomplete means some pages of a multi-page file weren't processed
+	StatusIncomplete Status = "incomplete"
+)
+
+// NeedsProcessing reports whether a file with this status must be processed
+func (s Status) NeedsProcessing() bool {
+	return s != StatusUnchanged
+}
+
+// Entry records a successfully processed file
+type Entry struct {
+	Path              string         `json:"path"`
+	Size              int64          `json:"size"`
+	ModTime           time.Time      `json:"mtime"`
+	Hash              string         `json:"hash"`
+	ConfigFingerprint string         `json:"config_fingerprint"`
+	ProcessedAt       time.Time      `json:"processed_at"`
+	Record            dataset.Record `json:"record"`
+
+	// PageCount and Pages record the pages of a multi-page file
+	PageCount int              `json:"page_count,omitempty"`
+	Pages     []dataset.Record `json:"pages,omitempty"`
+}
+
+// Manifest tracks the files processed into an output directory so later
+// runs only process new or changed files
+type Manifest struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*Entry
+}
+
+// file is the on-disk layout of a manifest
+type file struct {
+	Version int      `json:"version"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Load reads the manifest at path. A missing file yields an empty manifest.
+func Load(path string) (*Manifest, error) {
+	m := &Manifest{
+		path:    path,
+		entries: make(map[string]*Entry),
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return m, nil
+	}
+
+	var f file
+	if err := utils.LoadJSON(path, &f); err != nil {
+		return nil, fmt.Errorf("failed to load manifest: %w", err)
+	}
+	if f.Version != version {
+		return nil, fmt.Errorf("unsupported manifest version %d", f.Version)
+	}
+
+	for _, entry := range f.Entries {
+		m.entries[entry.Path] = entry
+	}
+	return m, nil
+}
+
+// Save writes the manifest atomically
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return utils.SaveJSON(m.path, file{Version: version, Entries: m.sorted()})
+}
+
+// Check compares a file against its entry. Size and modification time are
+// trusted when they match; otherwise the content hash decides, so touched
+// but unchanged files are not reprocessed.
+func (m *Manifest) Check(path, fingerprint string) (Status, error) {
+	m.mu.Lock()
+	entry, ok := m.entries[path]
+	m.mu.Unlock()
+
+	if !ok {
+		return StatusNew, nil
+	}
+	if entry.ConfigFingerprint != fingerprint {
+		return StatusConfigChanged, nil
+	}
+	if len(entry.Pages) < entry.PageCount {
+		return StatusIncomplete, nil
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if stat.Size() == entry.Size && stat.ModTime().Equal(entry.ModTime) {
+		return StatusUnchanged, nil
+	}
+
+	info, err := utils.GetFileInfo(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Hash != entry.Hash {
+		return StatusModified, nil
+	}
+
+	// Same content under a new mtime; remember it to skip hashing next time
+	m.mu.Lock()
+	entry.Size = stat.Size()
+	entry.ModTime = stat.ModTime()
+	m.mu.Unlock()
+
+	return StatusUnchanged, nil
+}
+
+// Update records a successfully processed file with its dataset record
+func (m *Manifest) Update(path, fingerprint string, record dataset.Record) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	info, err := utils.GetFileInfo(path)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[path] = &Entry{
+		Path:              path,
+		Size:              stat.Size(),
+		ModTime:           stat.ModTime(),
+		Hash:              info.Hash,
+		ConfigFingerprint: fingerprint,
+		ProcessedAt:       time.Now(),
+		Record:            record,
+	}
+	return nil
+}
+
+// UpdatePage records a successfully processed page of a multi-page file.
+// Pages recorded earlier are kept while the file and config are unchanged,
+// so the file is complete once each of its pages has been recorded.
+func (m *Manifest) UpdatePage(path, fingerprint string, pages int, record dataset.Record) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	info, err := utils.GetFileInfo(path)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[path]
+	if !ok || entry.Hash != info.Hash || entry.ConfigFingerprint != fingerprint || entry.PageCount != pages {
+		entry = &Entry{
+			Path:              path,
+			Hash:              info.Hash,
+			ConfigFingerprint: fingerprint,
+			PageCount:         pages,
+		}
+		m.entries[path] = entry
+	}
+	entry.Size = stat.Size()
+	entry.ModTime = stat.ModTime()
+	entry.ProcessedAt = time.Now()
+
+	for i, page := range entry.Pages {
+		if page.Page == record.Page {
+			entry.Pages[i] = record
+			return nil
+		}
+	}
+	entry.Pages = append(entry.Pages, record)
+	return nil
+}
+
+// Prune drops the entries of files not in paths and returns their paths
+func (m *Manifest) Prune(paths []string) []string {
+	existing := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		existing[path] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var removed []string
+	for path := range m.entries {
+		if !existing[path] {
+			removed = append(removed, path)
+			delete(m.entries, path)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// Records returns the dataset records of all entries ordered by path
+func (m *Manifest) Records() []dataset.Record {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := m.sorted()
+	records := make([]dataset.Record, 0, len(entries))
+	for _, entry := range entries {
+		if entry.PageCount > 0 {
+			records = append(records, entry.Pages...)
+			continue
+		}
+		records = append(records, entry.Record)
+	}
+	return records
+}
+
+// Len returns the number of entries
+func (m *Manifest) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+// sorted returns the entries ordered by path; the caller holds the lock
+func (m *Manifest) sorted() []*Entry {
+	entries := make([]*Entry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// Fingerprint hashes the config values that affect results, such as the
+// requested features and resize limits. Values are sorted so their order
+// doesn't matter.
+func Fingerprint(values ...string) string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
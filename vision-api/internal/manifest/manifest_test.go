@@ -0,0 +1,131 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vision_api/pkg/dataset"
+)
+
+// writeFile writes content to name in dir with the given modification time
+func writeFile(t *testing.T, dir, name, content string, mtime time.Time) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	path := writeFile(t, dir, "cat.jpg", "meow", mtime)
+
+	m, err := Load(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	check := func(step, fingerprint string, want Status) {
+		t.Helper()
+		got, err := m.Check(path, fingerprint)
+		if err != nil {
+			t.Fatalf("%s: Check() error = %v", step, err)
+		}
+		if got != want {
+			t.Errorf("%s: Check() = %s, want %s", step, got, want)
+		}
+		if got.NeedsProcessing() != (want != StatusUnchanged) {
+			t.Errorf("%s: %s.NeedsProcessing() = %t", step, got, got.NeedsProcessing())
+		}
+	}
+
+	check("before processing", "v1", StatusNew)
+
+	if err := m.Update(path, "v1", dataset.Record{ID: path}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	check("after processing", "v1", StatusUnchanged)
+	check("with new settings", "v2", StatusConfigChanged)
+
+	// Touching the file without changing it only costs a hash
+	writeFile(t, dir, "cat.jpg", "meow", mtime.Add(time.Hour))
+	check("touched", "v1", StatusUnchanged)
+
+	writeFile(t, dir, "cat.jpg", "woof", mtime.Add(2*time.Hour))
+	check("same size, new content", "v1", StatusModified)
+
+	os.Remove(path)
+	if _, err := m.Check(path, "v1"); err == nil {
+		t.Error("Check() of a deleted file succeeded")
+	}
+}
+
+func TestSaveLoadPrune(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Truncate(time.Second)
+	a := writeFile(t, dir, "a.jpg", "a", mtime)
+	b := writeFile(t, dir, "b.jpg", "b", mtime)
+
+	manifestPath := filepath.Join(dir, "manifest.json")
+	m, err := Load(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{b, a} {
+		if err := m.Update(path, "v1", dataset.Record{ID: path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(manifestPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if status, _ := loaded.Check(a, "v1"); status != StatusUnchanged {
+		t.Errorf("Check() after reload = %s, want %s", status, StatusUnchanged)
+	}
+	records := loaded.Records()
+	if len(records) != 2 || records[0].ID != a || records[1].ID != b {
+		t.Errorf("Records() = %v, want a then b", records)
+	}
+
+	if removed := loaded.Prune([]string{b}); len(removed) != 1 || removed[0] != a {
+		t.Errorf("Prune() = %v, want [%s]", removed, a)
+	}
+	if loaded.Len() != 1 {
+		t.Errorf("Len() after prune = %d, want 1", loaded.Len())
+	}
+}
+
+func TestLoadRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "entries": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load() accepted manifest version 99")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint("max_width=1024", "feature=LABEL_DETECTION")
+	if b := Fingerprint("feature=LABEL_DETECTION", "max_width=1024"); a != b {
+		t.Errorf("Fingerprint() depends on value order: %s != %s", a, b)
+	}
+	if c := Fingerprint("max_width=2048", "feature=LABEL_DETECTION"); a == c {
+		t.Error("Fingerprint() ignores changed values")
+	}
+	if len(a) != 16 {
+		t.Errorf("Fingerprint() = %q, want 16 hex characters", a)
+	}
+}
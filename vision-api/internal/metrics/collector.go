@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"vision_api/pkg/vision"
+)
+
+// Collector records the metrics of a processing run. It implements
+// vision.Observer for API call metrics and receives pipeline metrics from
+// the processor.
+type Collector struct {
+	registry *Registry
+	start    time.Time
+
+	stageDuration *HistogramVec
+	apiCalls      *CounterVec
+	apiDuration   *HistogramVec
+	retries       *CounterVec
+	rateLimitWait *HistogramVec
+	bytesUploaded *CounterVec
+	images        *CounterVec
+	duplicates    *CounterVec
+	cacheHits     *CounterVec
+	cacheLookups  *CounterVec
+
+	processed atomic.Int64
+}
+
+// NewCollector creates a collector with all metrics registered
+func NewCollector() *Collector {
+	r := NewRegistry()
+	c := &Collector{
+		registry: r,
+		start:    time.Now(),
+
+		stageDuration: r.NewHistogram("vision_stage_duration_seconds",
+			"Time spent per image in each pipeline stage.", DefaultBuckets, "stage"),
+		apiCalls: r.NewCounter("vision_api_calls_total",
+			"Vision API calls by feature and status code (0 if no response was received).", "feature", "code"),
+		apiDuration: r.NewHistogram("vision_api_call_duration_seconds",
+			"Duration of single Vision API calls.", DefaultBuckets, "feature"),
+		retries: r.NewCounter("vision_api_retries_total",
+			"Vision API calls that were retried.", "feature"),
+		rateLimitWait: r.NewHistogram("vision_rate_limit_wait_seconds",
+			"Time spent waiting for the rate limiter.", DefaultBuckets),
+		bytesUploaded: r.NewCounter("vision_uploaded_bytes_total",
+			"Image bytes sent to the Vision API.", "feature"),
+		images: r.NewCounter("vision_images_processed_total",
+			"Images that finished processing by status.", "status"),
+		duplicates: r.NewCounter("vision_duplicates_total",
+			"Images served from the result of a duplicate in the same batch."),
+		cacheHits: r.NewCounter("vision_cache_hits_total",
+			"Images that needed no API call because they were unchanged since the last run (manifest) or duplicates in the batch (duplicate).", "source"),
+		cacheLookups: r.NewCounter("vision_cache_lookups_total",
+			"Images checked against the manifest or the duplicates in their batch."),
+	}
+
+	r.NewGaugeFunc("vision_images_per_second",
+		"Average processing throughput since the run started.", c.imagesPerSecond)
+
+	return c
+}
+
+// Registry returns the underlying metrics registry
+func (c *Collector) Registry() *Registry {
+	return c.registry
+}
+
+// ObserveCall implements vision.Observer
+func (c *Collector) ObserveCall(feature vision.FeatureType, statusCode int, duration time.Duration, bytes int64) {
+	c.apiCalls.Inc(string(feature), strconv.Itoa(statusCode))
+	c.apiDuration.Observe(duration.Seconds(), string(feature))
+	c.bytesUploaded.Add(float64(bytes), string(feature))
+}
+
+// ObserveRetry implements vision.Observer
+func (c *Collector) ObserveRetry(feature vision.FeatureType) {
+	c.retries.Inc(string(feature))
+}
+
+// ObserveRateLimitWait implements vision.Observer
+func (c *Collector) ObserveRateLimitWait(wait time.Duration) {
+	c.rateLimitWait.Observe(wait.Seconds())
+}
+
+// ObserveStage records the time an image spent in a pipeline stage
+func (c *Collector) ObserveStage(stage string, duration time.Duration) {
+	c.stageDuration.Observe(duration.Seconds(), stage)
+}
+
+// ObserveImage records a finished image by status: success, failed or
+// skipped
+func (c *Collector) ObserveImage(status string) {
+	c.images.Inc(status)
+	c.processed.Add(1)
+}
+
+// ObserveDuplicate records an image served from the result of its duplicate
+func (c *Collector) ObserveDuplicate() {
+	c.duplicates.Inc()
+}
+
+// ObserveCacheLookup records an image of a batch that was annotated (miss)
+// or served from the result of its duplicate (hit)
+func (c *Collector) ObserveCacheLookup(hit bool) {
+	c.cacheLookups.Inc()
+	if hit {
+		c.cacheHits.Inc("duplicate")
+	}
+}
+
+// ObserveUnchanged records images that were checked against the manifest
+// and found unchanged, so they never reached a batch
+func (c *Collector) ObserveUnchanged(count int) {
+	c.cacheLookups.Add(float64(count))
+	c.cacheHits.Add(float64(count), "manifest")
+}
+
+// imagesPerSecond returns the average throughput since the collector was created
+func (c *Collector) imagesPerSecond() float64 {
+	elapsed := time.Since(c.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(c.processed.Load()) / elapsed
+}
+
+// Start serves the metrics on addr under /metrics until ctx is canceled.
+// It returns once the listener is bound so address errors surface early.
+func (c *Collector) Start(ctx context.Context, addr string, shutdownTimeout time.Duration) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", c.registry)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics server error", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	return nil
+}
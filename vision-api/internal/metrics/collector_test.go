@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"vision_api/pkg/vision"
+)
+
+// scrape renders the collector in the text exposition format
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+	var out strings.Builder
+	if err := c.Registry().WriteText(&out); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	return out.String()
+}
+
+func TestCollectorSamples(t *testing.T) {
+	c := NewCollector()
+
+	c.ObserveCall(vision.LabelDetection, 200, 300*time.Millisecond, 2048)
+	c.ObserveCall(vision.LabelDetection, 429, time.Second, 2048)
+	c.ObserveRetry(vision.LabelDetection)
+	c.ObserveImage("success")
+	c.ObserveImage("skipped")
+	c.ObserveImage("failed")
+	c.ObserveDuplicate()
+	c.ObserveCacheLookup(false)
+	c.ObserveCacheLookup(true)
+	c.ObserveUnchanged(3)
+
+	out := scrape(t, c)
+	for _, sample := range []string{
+		`vision_api_calls_total{feature="LABEL_DETECTION",code="200"} 1`,
+		`vision_api_calls_total{feature="LABEL_DETECTION",code="429"} 1`,
+		`vision_api_retries_total{feature="LABEL_DETECTION"} 1`,
+		`vision_uploaded_bytes_total{feature="LABEL_DETECTION"} 4096`,
+		`vision_api_call_duration_seconds_count{feature="LABEL_DETECTION"} 2`,
+		`vision_api_call_duration_seconds_sum{feature="LABEL_DETECTION"} 1.3`,
+		`vision_images_processed_total{status="success"} 1`,
+		`vision_images_processed_total{status="skipped"} 1`,
+		`vision_images_processed_total{status="failed"} 1`,
+		`vision_duplicates_total 1`,
+		`vision_cache_hits_total{source="duplicate"} 1`,
+		`vision_cache_hits_total{source="manifest"} 3`,
+		`vision_cache_lookups_total 5`,
+		"# TYPE vision_images_per_second gauge",
+	} {
+		if !strings.Contains(out, sample+"\n") {
+			t.Errorf("scrape is missing %q", sample)
+		}
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("wait_seconds", "Wait.", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var out strings.Builder
+	if err := r.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP wait_seconds Wait.
+# TYPE wait_seconds histogram
+wait_seconds_bucket{le="0.1"} 1
+wait_seconds_bucket{le="1"} 2
+wait_seconds_bucket{le="+Inf"} 3
+wait_seconds_sum 5.55
+wait_seconds_count 3
+`
+	if out.String() != want {
+		t.Errorf("WriteText() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
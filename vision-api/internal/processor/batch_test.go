@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+
+	"vision_api/internal/utils"
+)
+
+// newTestProcessor returns a processor that never reaches the Vision API,
+// for batches whose inputs all fail before annotation
+func newTestProcessor(t *testing.T, opts ...OptionFunc) *VisionProcessor {
+	t.Helper()
+	options := defaultOptions()
+	options.DeleteTempFiles = false
+	options.PrepareWorkers = 4
+	for _, opt := range opts {
+		opt(options)
+	}
+	p := &VisionProcessor{
+		options: options,
+		budget:  newMemoryBudget(options.MaxInflightBytes, options.MaxInflightPixels),
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	p.gate = newGate(p.annotateWorkers())
+	return p
+}
+
+func TestProcessBatchPreservesOrder(t *testing.T) {
+	p := newTestProcessor(t, WithPreserveOrder(true))
+
+	// Inputs without a reader or path fail in the prepare stage
+	inputs := make([]ProcessInput, 20)
+	for i := range inputs {
+		inputs[i].Filename = "image.jpg"
+	}
+	inputs[5].ID = "custom"
+
+	outputs, err := p.ProcessBatch(context.Background(), inputs)
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+	if len(outputs) != len(inputs) {
+		t.Fatalf("ProcessBatch() = %d outputs, want %d", len(outputs), len(inputs))
+	}
+
+	seen := make(map[string]bool)
+	for i, output := range outputs {
+		want := strconv.Itoa(i)
+		if i == 5 {
+			want = "custom"
+		}
+		if output.ID != want {
+			t.Errorf("outputs[%d].ID = %q, want %q", i, output.ID, want)
+		}
+		if output.CorrelationID == "" || seen[output.CorrelationID] {
+			t.Errorf("outputs[%d] has a missing or repeated correlation ID %q", i, output.CorrelationID)
+		}
+		seen[output.CorrelationID] = true
+		if !errors.Is(output.Error, utils.ErrInvalidInput) {
+			t.Errorf("outputs[%d].Error = %v, want %v", i, output.Error, utils.ErrInvalidInput)
+		}
+	}
+	if inputs[0].ID != "" {
+		t.Error("ProcessBatch() assigned IDs to the caller's inputs")
+	}
+}
+
+func TestProcessBatchCanceled(t *testing.T) {
+	p := newTestProcessor(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	inputs := []ProcessInput{{ID: "a", Filename: "a.jpg"}, {ID: "b", Filename: "b.jpg"}}
+	outputs, err := p.ProcessBatch(ctx, inputs)
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("ProcessBatch() = %d outputs, want 2", len(outputs))
+	}
+	for _, output := range outputs {
+		if !errors.Is(output.Error, utils.ErrNotProcessed) || !errors.Is(output.Error, context.Canceled) {
+			t.Errorf("output %s error = %v, want not processed because canceled", output.ID, output.Error)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// errDraining stops the scheduler once the processor is draining
+var errDraining = errors.New("processor is draining")
+
+// gate limits how many annotation calls run at once and holds back new
+// work while paused. Once draining it admits nothing more. Calls already
+// running are never interrupted.
+type gate struct {
+	mu       sync.Mutex
+	limit    int
+	active   int
+	paused   bool
+	draining bool
+	changed  chan struct{}
+}
+
+// newGate creates an open gate admitting limit calls at once
+func newGate(limit int) *gate {
+	return &gate{
+		limit:   limit,
+		changed: make(chan struct{}),
+	}
+}
+
+// acquire waits until the gate is open and has a free slot. It returns
+// errDraining once the gate is draining, even for callers already waiting.
+func (g *gate) acquire(ctx context.Context) error {
+	for {
+		g.mu.Lock()
+		if g.draining {
+			g.mu.Unlock()
+			return errDraining
+		}
+		if !g.paused && g.active < g.limit {
+			g.active++
+			g.mu.Unlock()
+			return nil
+		}
+		changed := g.changed
+		g.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// release frees the slot taken by acquire
+func (g *gate) release() {
+	g.update(func() { g.active-- })
+}
+
+// waitResumed returns once the gate isn't paused, or errDraining once it
+// is draining
+func (g *gate) waitResumed(ctx context.Context) error {
+	for {
+		g.mu.Lock()
+		paused, draining, changed := g.paused, g.draining, g.changed
+		g.mu.Unlock()
+
+		if draining {
+			return errDraining
+		}
+		if !paused {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// update applies fn under the lock and wakes up all waiters
+func (g *gate) update(fn func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	fn()
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// Pause stops new images from being scheduled and prepared images from
+// being sent to the API. Calls in flight are finished.
+func (p *VisionProcessor) Pause() {
+	p.gate.update(func() { p.gate.paused = true })
+	p.logger.Info("processing paused")
+	p.reportPaused(true)
+}
+
+// Resume continues processing after Pause
+func (p *VisionProcessor) Resume() {
+	p.gate.update(func() { p.gate.paused = false })
+	p.logger.Info("processing resumed")
+	p.reportPaused(false)
+}
+
+// Drain stops admitting new images for good. Calls to the API already in
+// flight are finished; images that were scheduled or prepared but not yet
+// sent, and those never scheduled, are reported with utils.ErrNotProcessed.
+func (p *VisionProcessor) Drain() {
+	p.gate.update(func() { p.gate.draining = true })
+	p.logger.Info("processing draining")
+}
+
+// Draining reports whether Drain was called
+func (p *VisionProcessor) Draining() bool {
+	p.gate.mu.Lock()
+	defer p.gate.mu.Unlock()
+	return p.gate.draining
+}
+
+// Paused reports whether processing is paused
+func (p *VisionProcessor) Paused() bool {
+	p.gate.mu.Lock()
+	defer p.gate.mu.Unlock()
+	return p.gate.paused
+}
+
+// SetPoolSize changes the number of concurrent Vision API calls. Lowering
+// it lets calls in flight finish before fewer new ones start.
+func (p *VisionProcessor) SetPoolSize(size int) error {
+	if size < 1 || size > p.options.MaxPoolSize {
+		return fmt.Errorf("pool size must be between 1 and %d", p.options.MaxPoolSize)
+	}
+
+	p.gate.update(func() { p.gate.limit = size })
+	p.logger.Info("pool size changed", "size", size)
+	return nil
+}
+
+// PoolSize returns the number of concurrent Vision API calls
+func (p *VisionProcessor) PoolSize() int {
+	p.gate.mu.Lock()
+	defer p.gate.mu.Unlock()
+	return p.gate.limit
+}
+
+// reportPaused shows the paused state on the progress tracker
+func (p *VisionProcessor) reportPaused(paused bool) {
+	p.mu.RLock()
+	tracker := p.tracker
+	p.mu.RUnlock()
+	if tracker != nil {
+		tracker.SetPaused(paused)
+	}
+}
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vision_api/internal/utils"
+)
+
+func TestGateLimit(t *testing.T) {
+	g := newGate(1)
+	ctx := context.Background()
+	if err := g.acquire(ctx); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	full, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := g.acquire(full); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire() on a full gate = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	g.release()
+	if err := g.acquire(ctx); err != nil {
+		t.Errorf("acquire() after release = %v", err)
+	}
+}
+
+func TestPauseHoldsBackCalls(t *testing.T) {
+	p := newTestProcessor(t, WithPoolSize(2))
+	p.Pause()
+	if !p.Paused() {
+		t.Fatal("Paused() = false after Pause()")
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- p.gate.acquire(context.Background()) }()
+	select {
+	case err := <-acquired:
+		t.Fatalf("acquire() returned %v while paused", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	p.Resume()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("acquire() after Resume() = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire() still waiting after Resume()")
+	}
+}
+
+func TestSetPoolSize(t *testing.T) {
+	p := newTestProcessor(t, WithPoolSize(2))
+	for _, size := range []int{0, p.options.MaxPoolSize + 1} {
+		if err := p.SetPoolSize(size); err == nil {
+			t.Errorf("SetPoolSize(%d) succeeded", size)
+		}
+	}
+
+	// Lowering the size holds back calls until enough running calls finish
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := p.gate.acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.SetPoolSize(1); err != nil {
+		t.Fatalf("SetPoolSize(1) error = %v", err)
+	}
+	p.gate.release()
+
+	full, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := p.gate.acquire(full); err == nil {
+		t.Error("acquire() admitted a second call at pool size 1")
+	}
+	p.gate.release()
+	if err := p.gate.acquire(ctx); err != nil {
+		t.Errorf("acquire() with a free slot = %v", err)
+	}
+	if p.PoolSize() != 1 {
+		t.Errorf("PoolSize() = %d, want 1", p.PoolSize())
+	}
+}
+
+func TestGateDrainWakesWaiters(t *testing.T) {
+	g := newGate(1)
+	g.update(func() { g.paused = true })
+
+	acquired := make(chan error, 1)
+	resumed := make(chan error, 1)
+	go func() { acquired <- g.acquire(context.Background()) }()
+	go func() { resumed <- g.waitResumed(context.Background()) }()
+
+	g.update(func() { g.draining = true })
+
+	for name, ch := range map[string]chan error{"acquire": acquired, "waitResumed": resumed} {
+		select {
+		case err := <-ch:
+			if !errors.Is(err, errDraining) {
+				t.Errorf("%s() = %v, want %v", name, err, errDraining)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s() still waiting after drain", name)
+		}
+	}
+
+	// Callers turned away by the drain hold no slot
+	if g.active != 0 {
+		t.Errorf("active = %d after drain, want 0", g.active)
+	}
+}
+
+func TestProcessBatchDrained(t *testing.T) {
+	metrics := &fakeMetrics{images: map[string]int{}, stages: map[string]int{}}
+	p := newTestProcessor(t, WithMetrics(metrics))
+	p.Drain()
+
+	outputs, err := p.ProcessBatch(context.Background(), []ProcessInput{{Filename: "a.jpg"}, {Filename: "b.jpg"}})
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+	for _, output := range outputs {
+		if !errors.Is(output.Error, utils.ErrNotProcessed) || !errors.Is(output.Error, errDraining) {
+			t.Errorf("output %s error = %v, want not processed because draining", output.ID, output.Error)
+		}
+	}
+	if len(metrics.images) != 0 {
+		t.Errorf("drained images were counted as finished: %v", metrics.images)
+	}
+}
@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"vision_api/internal/utils"
+)
+
+// DeadLetter is the envelope written for an input that failed permanently
+type DeadLetter struct {
+	InputPath     string                 `json:"input_path"`
+	Filename      string                 `json:"filename"`
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	ErrorClass    string                 `json:"error_class"`
+	Error         string                 `json:"error"`
+	Warning       string                 `json:"warning,omitempty"`
+	Attempts      []Attempt              `json:"attempts"`
+	FirstFailedAt time.Time              `json:"first_failed_at"`
+	LastFailedAt  time.Time              `json:"last_failed_at"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Input converts the envelope back into a process input for reprocessing
+func (d DeadLetter) Input() ProcessInput {
+	metadata := make(map[string]interface{}, len(d.Metadata)+1)
+	for k, v := range d.Metadata {
+		metadata[k] = v
+	}
+	metadata["path"] = d.InputPath
+
+	input := ProcessInput{
+		ID:       d.InputPath,
+		Filename: d.Filename,
+		Metadata: metadata,
+	}
+	if page, ok := inputPage(input); ok {
+		input.ID = PageID(d.InputPath, page)
+	}
+	return input
+}
+
+// LoadDeadLetters reads every envelope in a dead-letter directory
+func LoadDeadLetters(dir string) ([]DeadLetter, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	sort.Strings(paths)
+
+	letters := make([]DeadLetter, 0, len(paths))
+	for _, path := range paths {
+		var letter DeadLetter
+		if err := utils.LoadJSON(path, &letter); err != nil {
+			return nil, fmt.Errorf("failed to load dead letter %s: %w", path, err)
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+// deadLetterUpdate is a finished input whose dead letter is yet to be
+// written or removed
+type deadLetterUpdate struct {
+	input  ProcessInput
+	output ProcessOutput
+}
+
+// updateDeadLetter writes an envelope for a permanently failed input and
+// removes any stale envelope once the input succeeds or is skipped.
+// Canceled and pending inputs are left untouched since they never had a
+// chance to complete.
+func (p *VisionProcessor) updateDeadLetter(input ProcessInput, output ProcessOutput) error {
+	if p.options.DeadLetterDir == "" {
+		return nil
+	}
+
+	path := p.deadLetterPath(input)
+	class := utils.ClassifyError(output.Error)
+	if output.Error == nil || class == utils.ClassSkipped {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove dead letter: %w", err)
+		}
+		return nil
+	}
+
+	if class == utils.ClassCanceled || class == utils.ClassPending {
+		return nil
+	}
+
+	if err := utils.EnsureDirectory(p.options.DeadLetterDir); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	letter := DeadLetter{
+		InputPath:     inputPath(input),
+		Filename:      input.Filename,
+		FirstFailedAt: now,
+		Metadata:      input.Metadata,
+	}
+
+	// Keep the history of earlier runs when an input fails again
+	if _, err := os.Stat(path); err == nil {
+		if err := utils.LoadJSON(path, &letter); err != nil {
+			return fmt.Errorf("failed to load dead letter: %w", err)
+		}
+	}
+
+	letter.CorrelationID = output.CorrelationID
+	letter.ErrorClass = string(class)
+	letter.Error = output.Error.Error()
+	letter.Warning, _ = output.Metadata["format_warning"].(string)
+	letter.Attempts = append(letter.Attempts, output.Attempts...)
+	letter.LastFailedAt = now
+
+	return utils.SaveJSON(path, letter)
+}
+
+// deadLetterPath returns a stable envelope path for an input
+func (p *VisionProcessor) deadLetterPath(input ProcessInput) string {
+	key := inputRef(input)
+	if key == "" {
+		key = input.Filename
+	}
+	sum := sha256.Sum256([]byte(key))
+
+	name := strings.TrimSuffix(utils.SafeFileName(input.Filename), filepath.Ext(input.Filename))
+	return filepath.Join(p.options.DeadLetterDir, name+"-"+hex.EncodeToString(sum[:4])+".json")
+}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"vision_api/internal/utils"
+	"vision_api/pkg/vision"
+)
+
+func TestDeadLetterLifecycle(t *testing.T) {
+	options := defaultOptions()
+	options.DeadLetterDir = t.TempDir()
+	p := &VisionProcessor{options: options}
+
+	input := ProcessInput{
+		ID:       PageID("/in/scan.tif", 2),
+		Filename: "scan.tif",
+		Metadata: map[string]interface{}{"path": "/in/scan.tif", PageKey: 2},
+	}
+	failure := ProcessOutput{
+		CorrelationID: "first",
+		Error:         fmt.Errorf("%w: bad image", utils.ErrRejected),
+		Attempts:      []Attempt{{Number: 1, Error: "bad image"}},
+	}
+	path := p.deadLetterPath(input)
+
+	// Failing twice keeps the first failure time and all attempts
+	if err := p.updateDeadLetter(input, failure); err != nil {
+		t.Fatalf("updateDeadLetter() error = %v", err)
+	}
+	failure.CorrelationID = "second"
+	if err := p.updateDeadLetter(input, failure); err != nil {
+		t.Fatalf("updateDeadLetter() error = %v", err)
+	}
+
+	letters, err := LoadDeadLetters(options.DeadLetterDir)
+	if err != nil {
+		t.Fatalf("LoadDeadLetters() error = %v", err)
+	}
+	if len(letters) != 1 {
+		t.Fatalf("LoadDeadLetters() = %d letters, want 1", len(letters))
+	}
+	letter := letters[0]
+	if letter.ErrorClass != string(utils.ClassRejected) || letter.CorrelationID != "second" {
+		t.Errorf("letter class/correlation = %s/%s, want %s/second", letter.ErrorClass, letter.CorrelationID, utils.ClassRejected)
+	}
+	if len(letter.Attempts) != 2 || letter.FirstFailedAt.After(letter.LastFailedAt) {
+		t.Errorf("letter has %d attempts from %v to %v, want 2 in order",
+			len(letter.Attempts), letter.FirstFailedAt, letter.LastFailedAt)
+	}
+	if got := letter.Input(); got.ID != input.ID || inputPath(got) != "/in/scan.tif" {
+		t.Errorf("Input() = %s at %s, want %s at /in/scan.tif", got.ID, inputPath(got), input.ID)
+	}
+
+	// Inputs that never ran leave the letter alone; a success removes it
+	for _, reason := range []error{context.Canceled, utils.ErrNotProcessed} {
+		if err := p.updateDeadLetter(input, ProcessOutput{Error: reason}); err != nil {
+			t.Fatalf("updateDeadLetter(%v) error = %v", reason, err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("dead letter gone after %v: %v", reason, err)
+		}
+	}
+	if err := p.updateDeadLetter(input, ProcessOutput{}); err != nil {
+		t.Fatalf("updateDeadLetter() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("dead letter still present after success: %v", err)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	classes := map[int]utils.ErrorClass{
+		http.StatusBadRequest:            utils.ClassRejected,
+		http.StatusNotFound:              utils.ClassRejected,
+		http.StatusUnprocessableEntity:   utils.ClassRejected,
+		http.StatusRequestEntityTooLarge: utils.ClassUnknown,
+		http.StatusTooManyRequests:       utils.ClassRateLimited,
+		http.StatusGatewayTimeout:        utils.ClassTimeout,
+		http.StatusServiceUnavailable:    utils.ClassUnavailable,
+	}
+	for code, want := range classes {
+		err := apiError(&vision.StatusError{StatusCode: code, Message: "failed"})
+		if got := utils.ClassifyError(err); got != want {
+			t.Errorf("status %d classified as %s, want %s", code, got, want)
+		}
+		var status *vision.StatusError
+		if !errors.As(err, &status) {
+			t.Errorf("status %d: StatusError not kept in the chain", code)
+		}
+	}
+}
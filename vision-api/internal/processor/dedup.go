@@ -0,0 +1,287 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+
+	"vision_api/internal/image"
+	"vision_api/internal/utils"
+)
+
+// duplicateCluster groups batch inputs that contain the same or a nearly
+// identical image. Only the representative is sent to the Vision API.
+type duplicateCluster struct {
+	ID             string
+	Representative int
+	Duplicates     []int
+	hash           image.PerceptualHash
+	hasHash        bool
+}
+
+// fingerprint contains the content hashes used to detect duplicates
+type fingerprint struct {
+	sha256   string
+	phash    image.PerceptualHash
+	hasPHash bool
+}
+
+// findDuplicates fingerprints the inputs and groups exact duplicates by
+// SHA-256 and near duplicates by perceptual hash distance. Inputs without a
+// path are buffered in memory so they can still be read after hashing.
+// Only clusters with at least one duplicate are returned.
+func (p *VisionProcessor) findDuplicates(ctx context.Context, inputs []ProcessInput) ([]*duplicateCluster, error) {
+	fps, err := p.fingerprintAll(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := make(map[string]*duplicateCluster)
+	var clusters []*duplicateCluster
+	var near bkTree
+
+	for i, fp := range fps {
+		if fp == nil {
+			// Unreadable and rejected inputs are left to fail in the regular pipeline
+			continue
+		}
+
+		if cluster, ok := byHash[fp.sha256]; ok {
+			cluster.Duplicates = append(cluster.Duplicates, i)
+			continue
+		}
+
+		if fp.hasPHash {
+			if cluster := near.nearest(fp.phash, p.options.DedupThreshold); cluster != nil {
+				cluster.Duplicates = append(cluster.Duplicates, i)
+				byHash[fp.sha256] = cluster
+				continue
+			}
+		}
+
+		cluster := &duplicateCluster{
+			ID:             "cluster-" + fp.sha256[:12],
+			Representative: i,
+			hash:           fp.phash,
+			hasHash:        fp.hasPHash,
+		}
+		byHash[fp.sha256] = cluster
+		clusters = append(clusters, cluster)
+		if cluster.hasHash {
+			near.insert(cluster)
+		}
+	}
+
+	duplicated := make([]*duplicateCluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		if len(cluster.Duplicates) > 0 {
+			duplicated = append(duplicated, cluster)
+		}
+	}
+
+	return duplicated, nil
+}
+
+// fingerprintAll fingerprints the inputs on the prepare workers. Inputs
+// that fail the input checks or can't be hashed get a nil fingerprint.
+func (p *VisionProcessor) fingerprintAll(ctx context.Context, inputs []ProcessInput) ([]*fingerprint, error) {
+	fps := make([]*fingerprint, len(inputs))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < max(1, p.options.PrepareWorkers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if p.checkInput(inputs[i]) != nil {
+					continue
+				}
+				if fp, err := p.fingerprint(ctx, &inputs[i]); err == nil {
+					fps[i] = &fp
+				}
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for i := range inputs {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+	return fps, nil
+}
+
+// fingerprint computes the exact and perceptual hashes of an input. Files
+// are hashed by utils.GetFileInfo and only read into memory for the
+// perceptual hash, which is computed when near-duplicate detection is
+// enabled. Readers and pages are buffered, at most MaxFileSize bytes.
+func (p *VisionProcessor) fingerprint(ctx context.Context, input *ProcessInput) (fingerprint, error) {
+	var fp fingerprint
+
+	if _, isPage := inputPage(*input); input.Reader == nil && !isPage {
+		info, err := utils.GetFileInfo(inputPath(*input))
+		if err != nil {
+			return fp, err
+		}
+		fp.sha256 = info.Hash
+		if !p.options.DetectNearDuplicates {
+			return fp, nil
+		}
+	}
+
+	source, err := openInput(*input)
+	if err != nil {
+		return fp, err
+	}
+	data, err := io.ReadAll(io.LimitReader(source, p.options.MaxFileSize+1))
+	source.Close()
+	if err != nil {
+		return fp, fmt.Errorf("failed to read input: %w", err)
+	}
+	if int64(len(data)) > p.options.MaxFileSize {
+		// Hand the unread rest back so the pipeline rejects the input as usual
+		if input.Reader != nil {
+			input.Reader = io.MultiReader(bytes.NewReader(data), input.Reader)
+		}
+		return fp, fmt.Errorf("%w: exceeds limit of %d bytes", utils.ErrImageTooLarge, p.options.MaxFileSize)
+	}
+	if input.Reader != nil {
+		input.Reader = bytes.NewReader(data)
+	}
+
+	if fp.sha256 == "" {
+		sum := sha256.Sum256(data)
+		fp.sha256 = hex.EncodeToString(sum[:])
+	}
+
+	if p.options.DetectNearDuplicates {
+		if phash, err := p.options.ImageHandler.DifferenceHash(ctx, bytes.NewReader(data)); err == nil {
+			fp.phash, fp.hasPHash = phash, true
+		}
+	}
+	return fp, nil
+}
+
+// bkTree indexes clusters by the perceptual hash of their representative so
+// near duplicates are found without comparing against every cluster
+type bkTree struct {
+	root *bkNode
+}
+
+// bkNode is a cluster in the tree with its children keyed by their
+// distance to the cluster
+type bkNode struct {
+	cluster  *duplicateCluster
+	children map[int]*bkNode
+}
+
+// insert adds a cluster to the tree
+func (t *bkTree) insert(cluster *duplicateCluster) {
+	node := &bkNode{cluster: cluster}
+	if t.root == nil {
+		t.root = node
+		return
+	}
+
+	current := t.root
+	for {
+		distance := current.cluster.hash.Distance(cluster.hash)
+		child, ok := current.children[distance]
+		if !ok {
+			if current.children == nil {
+				current.children = make(map[int]*bkNode)
+			}
+			current.children[distance] = node
+			return
+		}
+		current = child
+	}
+}
+
+// nearest returns the cluster closest to the hash, or nil if none is within
+// the threshold. Ties go to the cluster of the earliest input.
+func (t *bkTree) nearest(hash image.PerceptualHash, threshold int) *duplicateCluster {
+	if t.root == nil {
+		return nil
+	}
+
+	var nearest *duplicateCluster
+	best := threshold + 1
+	pending := []*bkNode{t.root}
+	for len(pending) > 0 {
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		distance := node.cluster.hash.Distance(hash)
+		if distance < best || (distance == best && nearest != nil && node.cluster.Representative < nearest.Representative) {
+			nearest, best = node.cluster, distance
+		}
+
+		// By the triangle inequality only children whose distance to this
+		// node is within the threshold of the hash's distance can match
+		for d, child := range node.children {
+			if d >= distance-threshold && d <= distance+threshold {
+				pending = append(pending, child)
+			}
+		}
+	}
+
+	return nearest
+}
+
+// fanOutDuplicate builds the output of a duplicate input from the result
+// of its cluster representative
+func fanOutDuplicate(rep ProcessOutput, repInput, dup ProcessInput, cluster *duplicateCluster) ProcessOutput {
+	output := rep
+	output.ID = dup.ID
+	output.Filename = dup.Filename
+	output.EXIF = nil // the duplicate's own EXIF data was never read
+	output.Metadata = make(map[string]interface{}, len(rep.Metadata)+len(dup.Metadata)+2)
+	for k, v := range rep.Metadata {
+		output.Metadata[k] = v
+	}
+	delete(output.Metadata, "format_warning") // it names the representative's extension
+	for k, v := range dup.Metadata {
+		output.Metadata[k] = v
+	}
+
+	duplicateOf := repInput.Filename
+	if ref := inputRef(repInput); ref != "" {
+		duplicateOf = ref
+	}
+	output.Metadata["cluster_id"] = cluster.ID
+	output.Metadata["duplicate_of"] = duplicateOf
+
+	return output
+}
+
+// markRepresentative records cluster membership on a representative output
+func markRepresentative(output *ProcessOutput, cluster *duplicateCluster) {
+	if output.Metadata == nil {
+		output.Metadata = make(map[string]interface{})
+	}
+	output.Metadata["cluster_id"] = cluster.ID
+	output.Metadata["cluster_size"] = len(cluster.Duplicates) + 1
+}
+
+// inputPath returns the source path recorded in the input metadata, if any
+func inputPath(input ProcessInput) string {
+	path, _ := input.Metadata["path"].(string)
+	return path
+}
@@ -0,0 +1,143 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"vision_api/internal/image"
+	"vision_api/internal/utils"
+)
+
+func TestBKTreeNearest(t *testing.T) {
+	var tree bkTree
+	clusters := []*duplicateCluster{
+		{Representative: 0, hash: 0b0000},
+		{Representative: 1, hash: 0b1111_0000},
+		{Representative: 2, hash: 0b0011},
+		{Representative: 3, hash: 0b1111_0011},
+	}
+	for _, cluster := range clusters {
+		tree.insert(cluster)
+	}
+
+	tests := []struct {
+		hash      image.PerceptualHash
+		threshold int
+		want      int // representative, -1 for no match
+	}{
+		{hash: 0b0000, threshold: 0, want: 0},
+		{hash: 0b0111_0000, threshold: 2, want: 1},
+		{hash: 0b0001, threshold: 1, want: 0}, // equally close to 0 and 2
+		{hash: 0b1111_0111, threshold: 1, want: 3},
+		{hash: 0xff00_0000, threshold: 4, want: -1},
+	}
+	for _, tt := range tests {
+		got := tree.nearest(tt.hash, tt.threshold)
+		switch {
+		case tt.want < 0 && got != nil:
+			t.Errorf("nearest(%#b, %d) = cluster %d, want none", tt.hash, tt.threshold, got.Representative)
+		case tt.want >= 0 && (got == nil || got.Representative != tt.want):
+			t.Errorf("nearest(%#b, %d) = %v, want cluster %d", tt.hash, tt.threshold, got, tt.want)
+		}
+	}
+
+	var empty bkTree
+	if got := empty.nearest(0, 64); got != nil {
+		t.Errorf("nearest() on an empty tree = %v, want nil", got)
+	}
+}
+
+func TestFindDuplicatesExact(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) ProcessInput {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return ProcessInput{ID: name, Filename: name, Metadata: map[string]interface{}{"path": path}}
+	}
+
+	options := defaultOptions()
+	options.DetectNearDuplicates = false
+	p := &VisionProcessor{options: options}
+
+	inputs := []ProcessInput{
+		write("a.jpg", "first"),
+		write("b.jpg", "second"),
+		write("c.jpg", "first"),
+		write("d.txt", "first"), // rejected by the format filter
+		write("e.jpg", "first"),
+	}
+	clusters, err := p.findDuplicates(context.Background(), inputs)
+	if err != nil {
+		t.Fatalf("findDuplicates() error = %v", err)
+	}
+	if len(clusters) != 1 {
+		t.Fatalf("findDuplicates() = %d clusters, want 1", len(clusters))
+	}
+
+	cluster := clusters[0]
+	if cluster.Representative != 0 || !reflect.DeepEqual(cluster.Duplicates, []int{2, 4}) {
+		t.Errorf("cluster = representative %d with duplicates %v, want 0 with [2 4]",
+			cluster.Representative, cluster.Duplicates)
+	}
+
+	// The exact hash is the one utils.GetFileInfo reports for the file
+	info, err := utils.GetFileInfo(inputPath(inputs[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "cluster-" + info.Hash[:12]; cluster.ID != want {
+		t.Errorf("cluster ID = %q, want %q", cluster.ID, want)
+	}
+}
+
+func TestDuplicateOutputs(t *testing.T) {
+	cluster := &duplicateCluster{ID: "cluster-1", Representative: 0, Duplicates: []int{1}}
+	repInput := ProcessInput{ID: "0", Filename: "a.png", Metadata: map[string]interface{}{"path": "/in/a.png"}}
+	dupInput := ProcessInput{ID: "1", Filename: "b.jpg", Metadata: map[string]interface{}{"album": "holiday"}}
+	rep := ProcessOutput{
+		ID:       "0",
+		Filename: "a.png",
+		Metadata: map[string]interface{}{"format_warning": "a.png is a JPEG", "width": 10},
+	}
+	markRepresentative(&rep, cluster)
+
+	dup := fanOutDuplicate(rep, repInput, dupInput, cluster)
+	if dup.ID != "1" || dup.Filename != "b.jpg" {
+		t.Errorf("duplicate output is %s/%s, want 1/b.jpg", dup.ID, dup.Filename)
+	}
+	wantMetadata := map[string]interface{}{
+		"width":        10,
+		"album":        "holiday",
+		"cluster_id":   "cluster-1",
+		"cluster_size": 2,
+		"duplicate_of": "/in/a.png",
+	}
+	if !reflect.DeepEqual(dup.Metadata, wantMetadata) {
+		t.Errorf("duplicate metadata = %v, want %v", dup.Metadata, wantMetadata)
+	}
+	if _, ok := rep.Metadata["duplicate_of"]; ok {
+		t.Error("fanOutDuplicate() modified the representative metadata")
+	}
+
+	// Representative and duplicate each get an output file
+	options := defaultOptions()
+	options.OutputDir = t.TempDir()
+	p := &VisionProcessor{options: options}
+	for _, output := range []ProcessOutput{rep, dup} {
+		if err := p.saveResults(context.Background(), output); err != nil {
+			t.Fatalf("saveResults(%s) error = %v", output.Filename, err)
+		}
+	}
+	var saved ProcessOutput
+	if err := utils.LoadJSON(filepath.Join(options.OutputDir, "b.jpg.json"), &saved); err != nil {
+		t.Fatalf("loading duplicate output: %v", err)
+	}
+	if saved.Metadata["duplicate_of"] != "/in/a.png" {
+		t.Errorf("saved duplicate_of = %v, want /in/a.png", saved.Metadata["duplicate_of"])
+	}
+}
@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"vision_api/internal/image"
+	"vision_api/internal/utils"
+)
+
+// ExtensionPolicy selects what happens when the format sniffed from an
+// image's content disagrees with its filename extension
+type ExtensionPolicy string
+
+const (
+	// ExtensionOverride processes the image by its content and reports
+	// the mismatch as a warning
+	ExtensionOverride ExtensionPolicy = "override"
+
+	// ExtensionReject fails images whose content doesn't match their
+	// extension
+	ExtensionReject ExtensionPolicy = "reject"
+)
+
+// IsValid reports whether the policy is supported
+func (e ExtensionPolicy) IsValid() bool {
+	return e == ExtensionOverride || e == ExtensionReject
+}
+
+// allowsFormat reports whether a format is in the allowed formats,
+// ignoring case and alternative extensions such as jpg for jpeg
+func (p *VisionProcessor) allowsFormat(format image.Format) bool {
+	for _, allowed := range p.options.AllowedFormats {
+		if image.FormatFromExtension("."+allowed) == format {
+			return true
+		}
+	}
+	return false
+}
+
+// checkContent compares the format sniffed from an image's content with
+// its filename extension. The content must be an allowed format; if it
+// disagrees with the extension, the image is rejected or the mismatch is
+// returned as a warning, depending on the extension policy.
+func (p *VisionProcessor) checkContent(input ProcessInput, data []byte) (string, error) {
+	detected := image.Sniff(data)
+	if detected == "" {
+		return "", fmt.Errorf("%w: content is not a known image format", utils.ErrUnsupportedFormat)
+	}
+	if !p.allowsFormat(detected) {
+		return "", fmt.Errorf("%w: %s content", utils.ErrUnsupportedFormat, detected)
+	}
+
+	if image.FormatFromExtension(input.Filename) == detected {
+		return "", nil
+	}
+	mismatch := fmt.Sprintf("extension %s does not match %s content", filepath.Ext(input.Filename), detected)
+	if p.options.ExtensionMismatch == ExtensionReject {
+		return "", fmt.Errorf("%w: %s", utils.ErrInvalidInput, mismatch)
+	}
+	return mismatch, nil
+}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"vision_api/internal/image"
+	"vision_api/internal/utils"
+	"vision_api/pkg/vision"
+)
+
+// preparedFrame is a prepared frame of an animated image
+type preparedFrame struct {
+	file  *utils.FileInfo
+	index int
+}
+
+// samplesFrames reports whether frames beyond the first are sampled from
+// an image
+func (p *VisionProcessor) samplesFrames(metadata *image.Metadata) bool {
+	return p.options.FrameSampling.Strategy != image.FramesFirst &&
+		metadata != nil && metadata.Format == image.GIF
+}
+
+// sampledFrames returns the number of frames sampled from an input, or 0
+// if it isn't an animation. Like tileCount, only inputs with a path are
+// read.
+func (p *VisionProcessor) sampledFrames(input ProcessInput) int {
+	if p.options.FrameSampling.Strategy == image.FramesFirst || inputPath(input) == "" {
+		return 0
+	}
+	file, err := openInput(input)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	total, err := image.FrameCount(file)
+	if err != nil || total <= 1 {
+		return 0
+	}
+	return len(image.SampleFrames(total, p.options.FrameSampling))
+}
+
+// prepareFrames samples the frames of an animated image and writes each to
+// a temp file. The first frame is left out, since the whole image is
+// annotated as the first frame. The total number of frames is returned.
+func (p *VisionProcessor) prepareFrames(ctx context.Context, input ProcessInput, data []byte) ([]preparedFrame, int, error) {
+	frames, total, err := p.options.ImageHandler.Frames(ctx, bytes.NewReader(data), p.options.FrameSampling, image.ProcessOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var prepared []preparedFrame
+	for _, frame := range frames {
+		if frame.Index == 0 {
+			continue
+		}
+		info, err := p.writeTemp(fmt.Sprintf("vision-%s-frame%d-", input.Filename, frame.Index), frame)
+		if err != nil {
+			return nil, 0, err
+		}
+		prepared = append(prepared, preparedFrame{file: info, index: frame.Index})
+	}
+	return prepared, total, nil
+}
+
+// annotateFrames annotates the sampled frames of a job. It returns the
+// per-frame results, starting with the whole image as the first frame, and
+// a response whose labels are the union of the frame labels with their
+// highest score. Objects stay those of the first frame.
+func (p *VisionProcessor) annotateFrames(ctx context.Context, job *pipelineJob, whole *vision.AnnotateResponse, imageContext *vision.ImageContext) (*vision.AnnotateResponse, []FrameResult, []Attempt, error) {
+	orientation := sourceOrientation(job.source.metadata)
+	var attempts []Attempt
+	labels := [][]vision.Label{whole.Labels}
+	results := []FrameResult{newFrameResult(0, whole, orientation)}
+
+	for _, frame := range job.source.frames {
+		response, frameAttempts, err := p.annotateOnce(ctx, job.input, frame.file, imageContext)
+		attempts = append(attempts, frameAttempts...)
+		if err != nil {
+			return nil, nil, attempts, fmt.Errorf("frame %d: %w", frame.index, err)
+		}
+		labels = append(labels, response.Labels)
+		results = append(results, newFrameResult(frame.index, response, orientation))
+	}
+
+	merged := *whole
+	merged.Labels = mergeLabels(labels, ScoreMax)
+	return &merged, results, attempts, nil
+}
+
+// newFrameResult converts the response for one frame
+func newFrameResult(index int, response *vision.AnnotateResponse, orientation int) FrameResult {
+	return FrameResult{
+		Index:   index,
+		Labels:  convertLabels(response.Labels),
+		Objects: convertObjects(response.Objects, orientation),
+	}
+}
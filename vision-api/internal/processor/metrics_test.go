@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// fakeMetrics counts finished images by status
+type fakeMetrics struct {
+	images map[string]int
+	stages map[string]int
+}
+
+func (m *fakeMetrics) ObserveStage(stage string, duration time.Duration) { m.stages[stage]++ }
+func (m *fakeMetrics) ObserveImage(status string)                        { m.images[status]++ }
+func (m *fakeMetrics) ObserveDuplicate()                                 {}
+func (m *fakeMetrics) ObserveCacheLookup(hit bool)                       {}
+
+func TestPersistStageMetrics(t *testing.T) {
+	metrics := &fakeMetrics{images: map[string]int{}, stages: map[string]int{}}
+	options := defaultOptions()
+	options.Metrics = metrics
+	p := &VisionProcessor{options: options, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	for _, err := range []error{
+		nil,
+		errors.New("boom"),
+		nil,
+	} {
+		p.persistStage(context.Background(), &pipelineJob{err: err, startTime: time.Now()})
+	}
+
+	want := map[string]int{"success": 2, "failed": 1}
+	if len(metrics.images) != len(want) {
+		t.Errorf("images = %v, want %v", metrics.images, want)
+	}
+	for status, n := range want {
+		if metrics.images[status] != n {
+			t.Errorf("images[%s] = %d, want %d", status, metrics.images[status], n)
+		}
+	}
+	if metrics.stages["total"] != 3 {
+		t.Errorf("total durations = %d, want 3", metrics.stages["total"])
+	}
+}
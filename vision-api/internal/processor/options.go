@@ -2,10 +2,12 @@ package processor
 
 import (
 	"fmt"
+	"log/slog"
+	"runtime"
 	"time"
 
-	"../../pkg/vision"
-	"../image"
+	"vision_api/internal/image"
+	"vision_api/pkg/vision"
 )
 
 // ProcessorOptions contains configuration for the image processor
@@ -45,14 +47,89 @@ type ProcessorOptions struct {
 
 	// AllowedFormats is a list of allowed image formats
 	AllowedFormats []string
+
+	// Deduplicate enables duplicate detection before annotation
+	Deduplicate bool
+
+	// LocationContext sends the EXIF GPS location of images as annotation context
+	LocationContext bool
+
+	// DetectNearDuplicates enables perceptual hash matching in addition to exact matching
+	DetectNearDuplicates bool
+
+	// DedupThreshold is the maximum perceptual hash distance for near duplicates
+	DedupThreshold int
+
+	// DeadLetterDir is the directory for permanently failed inputs; empty disables it
+	DeadLetterDir string
+
+	// MaxInflightBytes caps the total encoded size of images in flight; zero disables it
+	MaxInflightBytes int64
+
+	// MaxInflightPixels caps the total decoded pixels of images in flight; zero disables it
+	MaxInflightPixels int64
+
+	// PrepareWorkers is the number of workers decoding and resizing images
+	PrepareWorkers int
+
+	// AnnotateWorkers is the number of concurrent Vision API calls; zero uses PoolSize
+	AnnotateWorkers int
+
+	// PersistWorkers is the number of workers saving results
+	PersistWorkers int
+
+	// QueueSize is the capacity of each stage queue
+	QueueSize int
+
+	// MaxPoolSize is the largest pool size SetPoolSize accepts
+	MaxPoolSize int
+
+	// Metrics receives processing metrics; nil disables them
+	Metrics MetricsRecorder
+
+	// Logger receives processing logs
+	Logger *slog.Logger
+
+	// PreserveOrder returns batch results in input order instead of
+	// completion order
+	PreserveOrder bool
+
+	// SpendGuard stops scheduling new images when it is exhausted; nil
+	// disables spend limits
+	SpendGuard SpendGuard
+
+	// TileSize splits images larger than it into overlapping square tiles
+	// that are annotated in addition to the whole image; zero disables it
+	TileSize int
+
+	// TileOverlap is the minimum number of pixels neighbouring tiles share
+	TileOverlap int
+
+	// TileNMSThreshold is the IoU above which tile objects of the same name
+	// are merged
+	TileNMSThreshold float64
+
+	// TileLabelScoring combines the labels of tiles into image labels
+	TileLabelScoring LabelScoring
+
+	// FrameSampling selects the frames of animated GIFs that are annotated
+	FrameSampling image.FrameOptions
+
+	// QualityScreening skips images failing its thresholds before they are
+	// annotated; nil disables screening
+	QualityScreening *image.QualityThresholds
+
+	// ExtensionMismatch decides whether images whose content doesn't match
+	// their extension are processed by their content or rejected
+	ExtensionMismatch ExtensionPolicy
 }
 
 // OptionFunc is a function that configures Options
-type OptionFunc func(*Options)
+type OptionFunc func(*ProcessorOptions)
 
 // defaultOptions returns the default processor options
-func defaultOptions() *Options {
-	return &Options{
+func defaultOptions() *ProcessorOptions {
+	return &ProcessorOptions{
 		PoolSize:        4,
 		BatchSize:       100,
 		RetryAttempts:   3,
@@ -60,13 +137,28 @@ func defaultOptions() *Options {
 		MaxRetryDelay:   time.Second * 30,
 		MaxFileSize:     40 * 1024 * 1024, // 40MB
 		DeleteTempFiles: true,
-		AllowedFormats:  []string{"jpg", "jpeg", "png", "gif", "bmp"},
+		AllowedFormats:  []string{"jpg", "jpeg", "png", "gif", "bmp", "webp", "tif", "tiff"},
+
+		DetectNearDuplicates: true,
+		DedupThreshold:       6,
+		MaxInflightBytes:     512 * 1024 * 1024, // 512MB
+		MaxInflightPixels:    400 * 1000 * 1000, // 400MP
+		PrepareWorkers:       runtime.NumCPU(),
+		PersistWorkers:       2,
+		QueueSize:            16,
+		MaxPoolSize:          64,
+		Logger:               slog.Default(),
+		TileOverlap:          256,
+		TileNMSThreshold:     0.5,
+		TileLabelScoring:     ScoreMax,
+		FrameSampling:        image.FrameOptions{Strategy: image.FramesFirst},
+		ExtensionMismatch:    ExtensionOverride,
 	}
 }
 
 // WithPoolSize sets the number of concurrent processors
 func WithPoolSize(size int) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		if size > 0 {
 			o.PoolSize = size
 		}
@@ -75,7 +167,7 @@ func WithPoolSize(size int) OptionFunc {
 
 // WithBatchSize sets the batch size
 func WithBatchSize(size int) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		if size > 0 {
 			o.BatchSize = size
 		}
@@ -84,7 +176,7 @@ func WithBatchSize(size int) OptionFunc {
 
 // WithRetryAttempts sets the maximum retry attempts
 func WithRetryAttempts(attempts int) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		if attempts >= 0 {
 			o.RetryAttempts = attempts
 		}
@@ -93,7 +185,7 @@ func WithRetryAttempts(attempts int) OptionFunc {
 
 // WithRetryDelay sets the retry delay
 func WithRetryDelay(delay time.Duration) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		if delay > 0 {
 			o.RetryDelay = delay
 		}
@@ -102,7 +194,7 @@ func WithRetryDelay(delay time.Duration) OptionFunc {
 
 // WithMaxRetryDelay sets the maximum retry delay
 func WithMaxRetryDelay(delay time.Duration) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		if delay > 0 {
 			o.MaxRetryDelay = delay
 		}
@@ -111,21 +203,21 @@ func WithMaxRetryDelay(delay time.Duration) OptionFunc {
 
 // WithImageHandler sets the image handler
 func WithImageHandler(handler image.Handler) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		o.ImageHandler = handler
 	}
 }
 
 // WithVisionClient sets the vision client
 func WithVisionClient(client *vision.Client) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		o.VisionClient = client
 	}
 }
 
 // WithMaxFileSize sets the maximum file size
 func WithMaxFileSize(size int64) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		if size > 0 {
 			o.MaxFileSize = size
 		}
@@ -134,34 +226,218 @@ func WithMaxFileSize(size int64) OptionFunc {
 
 // WithOutputDir sets the output directory
 func WithOutputDir(dir string) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		o.OutputDir = dir
 	}
 }
 
 // WithTempDir sets the temporary directory
 func WithTempDir(dir string) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		o.TempDir = dir
 	}
 }
 
 // WithDeleteTempFiles sets whether to delete temporary files
 func WithDeleteTempFiles(delete bool) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		o.DeleteTempFiles = delete
 	}
 }
 
 // WithAllowedFormats sets the allowed image formats
 func WithAllowedFormats(formats []string) OptionFunc {
-	return func(o *Options) {
+	return func(o *ProcessorOptions) {
 		if len(formats) > 0 {
 			o.AllowedFormats = formats
 		}
 	}
 }
 
+// WithDeduplication enables duplicate detection before annotation
+func WithDeduplication(enabled bool) OptionFunc {
+	return func(o *ProcessorOptions) {
+		o.Deduplicate = enabled
+	}
+}
+
+// WithLocationContext sets whether the EXIF GPS location of images is sent
+// as annotation context
+func WithLocationContext(enabled bool) OptionFunc {
+	return func(o *ProcessorOptions) {
+		o.LocationContext = enabled
+	}
+}
+
+// WithNearDuplicates sets whether perceptually similar images are treated as duplicates
+func WithNearDuplicates(enabled bool) OptionFunc {
+	return func(o *ProcessorOptions) {
+		o.DetectNearDuplicates = enabled
+	}
+}
+
+// WithDedupThreshold sets the maximum perceptual hash distance for near duplicates
+func WithDedupThreshold(distance int) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if distance >= 0 {
+			o.DedupThreshold = distance
+		}
+	}
+}
+
+// WithDeadLetterDir sets the directory for permanently failed inputs
+func WithDeadLetterDir(dir string) OptionFunc {
+	return func(o *ProcessorOptions) {
+		o.DeadLetterDir = dir
+	}
+}
+
+// WithMaxInflightBytes caps the total encoded size of images in flight
+func WithMaxInflightBytes(size int64) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if size >= 0 {
+			o.MaxInflightBytes = size
+		}
+	}
+}
+
+// WithMaxInflightPixels caps the total decoded pixels of images in flight
+func WithMaxInflightPixels(pixels int64) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if pixels >= 0 {
+			o.MaxInflightPixels = pixels
+		}
+	}
+}
+
+// WithPrepareWorkers sets the number of workers decoding and resizing images
+func WithPrepareWorkers(workers int) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if workers > 0 {
+			o.PrepareWorkers = workers
+		}
+	}
+}
+
+// WithAnnotateWorkers sets the number of concurrent Vision API calls
+func WithAnnotateWorkers(workers int) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if workers > 0 {
+			o.AnnotateWorkers = workers
+		}
+	}
+}
+
+// WithPersistWorkers sets the number of workers saving results
+func WithPersistWorkers(workers int) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if workers > 0 {
+			o.PersistWorkers = workers
+		}
+	}
+}
+
+// WithQueueSize sets the capacity of each stage queue
+func WithQueueSize(size int) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if size > 0 {
+			o.QueueSize = size
+		}
+	}
+}
+
+// WithMaxPoolSize sets the largest pool size SetPoolSize accepts
+func WithMaxPoolSize(size int) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if size > 0 {
+			o.MaxPoolSize = size
+		}
+	}
+}
+
+// WithPreserveOrder returns batch results in input order
+func WithPreserveOrder(preserve bool) OptionFunc {
+	return func(o *ProcessorOptions) {
+		o.PreserveOrder = preserve
+	}
+}
+
+// WithSpendGuard sets the guard that stops new images at a spend cap
+func WithSpendGuard(guard SpendGuard) OptionFunc {
+	return func(o *ProcessorOptions) {
+		o.SpendGuard = guard
+	}
+}
+
+// WithTiling splits images larger than size into tiles overlapping by
+// overlap pixels. A size of zero disables tiling.
+func WithTiling(size, overlap int) OptionFunc {
+	return func(o *ProcessorOptions) {
+		o.TileSize = size
+		o.TileOverlap = overlap
+	}
+}
+
+// WithTileNMSThreshold sets the IoU above which tile objects are merged
+func WithTileNMSThreshold(threshold float64) OptionFunc {
+	return func(o *ProcessorOptions) {
+		o.TileNMSThreshold = threshold
+	}
+}
+
+// WithTileLabelScoring sets how tile labels are combined
+func WithTileLabelScoring(scoring LabelScoring) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if scoring != "" {
+			o.TileLabelScoring = scoring
+		}
+	}
+}
+
+// WithFrameSampling sets which frames of animated GIFs are annotated:
+// every count-th frame or count evenly spaced frames, depending on strategy
+func WithFrameSampling(strategy image.FrameStrategy, count int) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if strategy != "" {
+			o.FrameSampling = image.FrameOptions{Strategy: strategy, Count: count}
+		}
+	}
+}
+
+// WithQualityScreening skips images failing the thresholds before they
+// are annotated. Nil disables screening.
+func WithQualityScreening(thresholds *image.QualityThresholds) OptionFunc {
+	return func(o *ProcessorOptions) {
+		o.QualityScreening = thresholds
+	}
+}
+
+// WithExtensionMismatch sets what happens to images whose content doesn't
+// match their extension
+func WithExtensionMismatch(policy ExtensionPolicy) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if policy != "" {
+			o.ExtensionMismatch = policy
+		}
+	}
+}
+
+// WithLogger sets the logger for processing logs
+func WithLogger(logger *slog.Logger) OptionFunc {
+	return func(o *ProcessorOptions) {
+		if logger != nil {
+			o.Logger = logger
+		}
+	}
+}
+
+// WithMetrics sets the recorder for processing metrics
+func WithMetrics(metrics MetricsRecorder) OptionFunc {
+	return func(o *ProcessorOptions) {
+		o.Metrics = metrics
+	}
+}
+
 // validate checks if the options are valid
 func (o *ProcessorOptions) validate() error {
 	if o.PoolSize < 1 {
@@ -172,6 +448,18 @@ func (o *ProcessorOptions) validate() error {
 		return fmt.Errorf("batch size must be at least 1")
 	}
 
+	if o.PrepareWorkers < 1 || o.PersistWorkers < 1 || o.AnnotateWorkers < 0 {
+		return fmt.Errorf("each pipeline stage needs at least 1 worker")
+	}
+
+	if o.QueueSize < 1 {
+		return fmt.Errorf("queue size must be at least 1")
+	}
+
+	if o.MaxPoolSize < o.PoolSize || o.MaxPoolSize < o.AnnotateWorkers {
+		return fmt.Errorf("max pool size must be at least the pool size")
+	}
+
 	if o.RetryAttempts < 0 {
 		return fmt.Errorf("retry attempts cannot be negative")
 	}
@@ -204,5 +492,37 @@ func (o *ProcessorOptions) validate() error {
 		return fmt.Errorf("at least one allowed format is required")
 	}
 
+	if o.MaxInflightBytes < 0 || o.MaxInflightPixels < 0 {
+		return fmt.Errorf("in-flight memory limits cannot be negative")
+	}
+
+	if o.DedupThreshold < 0 || o.DedupThreshold > 64 {
+		return fmt.Errorf("dedup threshold must be between 0 and 64")
+	}
+
+	if o.TileSize < 0 || o.TileOverlap < 0 || (o.TileSize > 0 && o.TileOverlap >= o.TileSize) {
+		return fmt.Errorf("tile overlap must be less than the tile size")
+	}
+
+	if o.TileNMSThreshold <= 0 || o.TileNMSThreshold > 1 {
+		return fmt.Errorf("tile NMS threshold must be between 0 and 1")
+	}
+
+	if !o.TileLabelScoring.IsValid() {
+		return fmt.Errorf("unsupported tile label scoring: %s", o.TileLabelScoring)
+	}
+
+	if !o.ExtensionMismatch.IsValid() {
+		return fmt.Errorf("unsupported extension mismatch policy: %s", o.ExtensionMismatch)
+	}
+
+	if !o.FrameSampling.Strategy.IsValid() {
+		return fmt.Errorf("unsupported frame strategy: %s", o.FrameSampling.Strategy)
+	}
+
+	if o.FrameSampling.Strategy != image.FramesFirst && o.FrameSampling.Count < 1 {
+		return fmt.Errorf("frame count must be at least 1")
+	}
+
 	return nil
 }
@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PageKey is the input metadata key selecting one page of a multi-page
+// image file. Pages start at 0.
+const PageKey = "page"
+
+// pageSeparator separates the file path and page number of a page ID
+const pageSeparator = "#page="
+
+// PageID returns the input ID of one page of a multi-page image file.
+// Page numbers in IDs start at 1.
+func PageID(path string, page int) string {
+	return fmt.Sprintf("%s%s%d", path, pageSeparator, page+1)
+}
+
+// PagePath returns the file an input ID refers to, without the page of
+// multi-page inputs
+func PagePath(id string) string {
+	path, _ := SplitPageID(id)
+	return path
+}
+
+// SplitPageID splits an input ID into the file it refers to and its page
+// number, starting at 1. The page is 0 for IDs without a page.
+func SplitPageID(id string) (string, int) {
+	i := strings.LastIndex(id, pageSeparator)
+	if i < 0 {
+		return id, 0
+	}
+	page, err := strconv.Atoi(id[i+len(pageSeparator):])
+	if err != nil || page < 1 {
+		return id, 0
+	}
+	return id[:i], page
+}
+
+// inputPage returns the page an input selects, if any. Pages read back
+// from JSON are float64.
+func inputPage(input ProcessInput) (int, bool) {
+	switch page := input.Metadata[PageKey].(type) {
+	case int:
+		return page, true
+	case float64:
+		return int(page), true
+	default:
+		return 0, false
+	}
+}
+
+// inputRef returns the source path of an input including its page, or ""
+// if it has no source path
+func inputRef(input ProcessInput) string {
+	path := inputPath(input)
+	if page, ok := inputPage(input); ok && path != "" {
+		return PageID(path, page)
+	}
+	return path
+}
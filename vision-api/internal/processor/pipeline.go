@@ -0,0 +1,169 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"vision_api/internal/utils"
+)
+
+// Pipeline stage names as reported to stage trackers
+const (
+	StagePrepare  = "prepare"
+	StageAnnotate = "annotate"
+	StagePersist  = "persist"
+)
+
+// pipelineJob carries a batch input through the prepare, annotate and
+// persist stages. Stages skip jobs that already failed.
+type pipelineJob struct {
+	batchJob
+	startTime time.Time
+	prepared  *utils.FileInfo
+	source    sourceInfo
+	output    ProcessOutput
+	err       error
+	span      trace.Span
+}
+
+// stage is a pool of workers draining a bounded queue
+type stage struct {
+	name    string
+	workers int
+	queue   chan *pipelineJob
+	active  atomic.Int64
+	done    chan struct{}
+}
+
+// newStage creates a stage with its own input queue
+func newStage(name string, workers, queueSize int) *stage {
+	return &stage{
+		name:    name,
+		workers: workers,
+		queue:   make(chan *pipelineJob, queueSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// run starts the stage workers. Every job is handed to next once fn
+// returns, and next and done are closed after the queue is drained.
+func (s *stage) run(ctx context.Context, next chan<- *pipelineJob, fn func(context.Context, *pipelineJob)) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range s.queue {
+				s.active.Add(1)
+				fn(ctx, job)
+				s.active.Add(-1)
+				next <- job
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(next)
+		close(s.done)
+	}()
+}
+
+// runPipeline runs the pending jobs through the prepare, annotate and
+// persist stages and returns the channel finished jobs are delivered on.
+// CPU-bound preparation and IO-bound annotation run in separate pools so
+// CPUs stay busy while API calls are in flight.
+func (p *VisionProcessor) runPipeline(ctx context.Context, pending []batchJob) <-chan *pipelineJob {
+	queueSize := p.options.QueueSize
+	prepare := newStage(StagePrepare, p.options.PrepareWorkers, queueSize)
+	annotate := newStage(StageAnnotate, p.options.MaxPoolSize, queueSize)
+	persist := newStage(StagePersist, p.options.PersistWorkers, queueSize)
+	results := make(chan *pipelineJob, queueSize)
+
+	// The scheduler admits jobs into the prepare queue within the memory
+	// budget; the budget is returned once the job has been persisted, since
+	// the prepared image and the annotations stay in memory until then
+	scheduled := make(chan batchJob, queueSize)
+	go p.schedule(ctx, pending, scheduled)
+	go func() {
+		defer close(prepare.queue)
+		for job := range scheduled {
+			prepare.queue <- &pipelineJob{batchJob: job}
+		}
+	}()
+
+	prepare.run(ctx, annotate.queue, func(ctx context.Context, job *pipelineJob) {
+		job.startTime = time.Now()
+		p.prepareStage(ctx, job)
+	})
+	// Annotation runs on enough workers for the largest pool size; the gate
+	// limits how many of them call the API so the pool can be resized
+	annotate.run(ctx, persist.queue, p.gatedAnnotateStage)
+	persist.run(ctx, results, func(ctx context.Context, job *pipelineJob) {
+		p.persistStage(ctx, job)
+		p.budget.release(job.cost)
+	})
+
+	go p.monitorStages(prepare, annotate, persist)
+
+	return results
+}
+
+// gatedAnnotateStage runs annotateStage once the gate admits the job
+func (p *VisionProcessor) gatedAnnotateStage(ctx context.Context, job *pipelineJob) {
+	if job.err != nil {
+		return
+	}
+	if err := p.gate.acquire(ctx); err != nil {
+		// Jobs turned away by the gate were never sent to the API
+		job.err = fmt.Errorf("%w: %w", utils.ErrNotProcessed, err)
+		return
+	}
+	defer p.gate.release()
+
+	p.annotateStage(ctx, job)
+}
+
+// annotateWorkers returns the annotation concurrency, which defaults to the pool size
+func (p *VisionProcessor) annotateWorkers() int {
+	if p.options.AnnotateWorkers > 0 {
+		return p.options.AnnotateWorkers
+	}
+	return p.options.PoolSize
+}
+
+// monitorStages periodically reports stage queue depths to the progress
+// tracker until the last stage has drained
+func (p *VisionProcessor) monitorStages(stages ...*stage) {
+	p.mu.RLock()
+	tracker := p.tracker
+	p.mu.RUnlock()
+	if tracker == nil {
+		return
+	}
+
+	report := func() {
+		for _, s := range stages {
+			tracker.UpdateStage(s.name, len(s.queue), int(s.active.Load()))
+		}
+	}
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	last := stages[len(stages)-1]
+	for {
+		select {
+		case <-ticker.C:
+			report()
+		case <-last.done:
+			report()
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStageRun(t *testing.T) {
+	s := newStage("test", 3, 4)
+	next := make(chan *pipelineJob, 10)
+
+	var running, peak atomic.Int64
+	s.run(context.Background(), next, func(_ context.Context, job *pipelineJob) {
+		n := running.Add(1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		job.output.ID = job.input.ID
+		running.Add(-1)
+	})
+
+	go func() {
+		for _, id := range []string{"a", "b", "c", "d", "e", "f"} {
+			s.queue <- &pipelineJob{batchJob: batchJob{input: ProcessInput{ID: id}}}
+		}
+		close(s.queue)
+	}()
+
+	var ids []string
+	for job := range next {
+		ids = append(ids, job.output.ID)
+	}
+	sort.Strings(ids)
+	if got := len(ids); got != 6 || ids[0] != "a" || ids[5] != "f" {
+		t.Errorf("stage handed on %v, want a to f", ids)
+	}
+
+	select {
+	case <-s.done:
+	case <-time.After(time.Second):
+		t.Fatal("done not closed after the queue drained")
+	}
+	if got := peak.Load(); got > 3 {
+		t.Errorf("%d jobs ran at once on 3 workers", got)
+	}
+}
+
+func TestAnnotateWorkers(t *testing.T) {
+	options := defaultOptions()
+	options.PoolSize = 8
+	p := &VisionProcessor{options: options}
+	if got := p.annotateWorkers(); got != 8 {
+		t.Errorf("annotateWorkers() = %d, want the pool size 8", got)
+	}
+
+	options.AnnotateWorkers = 3
+	if got := p.annotateWorkers(); got != 3 {
+		t.Errorf("annotateWorkers() = %d, want 3", got)
+	}
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"vision_api/internal/utils"
+)
+
+// SkipDuplicate is the skip reason of inputs served from their cluster
+// representative instead of being annotated
+const SkipDuplicate = "duplicate"
+
+// SkippedInput is an input a batch would not send for annotation
+type SkippedInput struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+
+	// Reason is an error class, or SkipDuplicate
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
+
+// Plan describes what ProcessBatch would do with a batch
+type Plan struct {
+	// Total is the number of inputs in the batch
+	Total int `json:"total"`
+
+	// Annotate lists the IDs of inputs that would be sent for annotation
+	Annotate []string `json:"annotate"`
+
+	// Requests is the number of annotation requests, counting the tiles of
+	// tiled images and the sampled frames of animations
+	Requests int `json:"requests"`
+
+	// Skipped lists inputs that would be filtered out or deduplicated
+	Skipped []SkippedInput `json:"skipped"`
+}
+
+// Plan applies the format, size and duplicate filters of ProcessBatch to
+// the inputs without preparing or annotating anything
+func (p *VisionProcessor) Plan(ctx context.Context, inputs []ProcessInput) (*Plan, error) {
+	inputs = append([]ProcessInput(nil), inputs...)
+	plan := &Plan{Total: len(inputs)}
+
+	// Filter first so rejected inputs can't become cluster representatives
+	accepted := make([]ProcessInput, 0, len(inputs))
+	for i, input := range inputs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if input.ID == "" {
+			input.ID = strconv.Itoa(i)
+		}
+		if err := p.checkInput(input); err != nil {
+			plan.Skipped = append(plan.Skipped, SkippedInput{
+				ID:       input.ID,
+				Filename: input.Filename,
+				Reason:   string(utils.ClassifyError(err)),
+				Detail:   err.Error(),
+			})
+			continue
+		}
+		accepted = append(accepted, input)
+	}
+
+	duplicates := make(map[int]string)
+	if p.options.Deduplicate {
+		clusters, err := p.findDuplicates(ctx, accepted)
+		if err != nil {
+			return nil, fmt.Errorf("deduplication failed: %w", err)
+		}
+		for _, cluster := range clusters {
+			for _, index := range cluster.Duplicates {
+				duplicates[index] = accepted[cluster.Representative].ID
+			}
+		}
+	}
+
+	for i, input := range accepted {
+		if representative, ok := duplicates[i]; ok {
+			plan.Skipped = append(plan.Skipped, SkippedInput{
+				ID:       input.ID,
+				Filename: input.Filename,
+				Reason:   SkipDuplicate,
+				Detail:   "duplicate of " + representative,
+			})
+			continue
+		}
+		plan.Annotate = append(plan.Annotate, input.ID)
+		plan.Requests += p.annotateRequests(input)
+	}
+
+	return plan, nil
+}
+
+// annotateRequests returns the number of annotation requests an input
+// takes: one per sampled frame of animations, and otherwise one for the
+// whole image and one per tile
+func (p *VisionProcessor) annotateRequests(input ProcessInput) int {
+	if frames := p.sampledFrames(input); frames > 1 {
+		return frames
+	}
+	return 1 + p.tileCount(input)
+}
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vision_api/internal/utils"
+)
+
+func TestPlan(t *testing.T) {
+	dir := t.TempDir()
+	var inputs []ProcessInput
+	for _, file := range []struct{ name, content string }{
+		{"a.jpg", "same"},
+		{"b.jpg", "same"},
+		{"notes.txt", "text"},
+		{"c.jpg", "other"},
+	} {
+		path := filepath.Join(dir, file.name)
+		if err := os.WriteFile(path, []byte(file.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, ProcessInput{Filename: file.name, Metadata: map[string]interface{}{"path": path}})
+	}
+
+	p := newTestProcessor(t, WithDeduplication(true))
+	p.options.DetectNearDuplicates = false
+
+	plan, err := p.Plan(context.Background(), inputs)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	if plan.Total != 4 || plan.Requests != 2 {
+		t.Errorf("plan has %d inputs and %d requests, want 4 and 2", plan.Total, plan.Requests)
+	}
+	if len(plan.Annotate) != 2 || plan.Annotate[0] != "0" || plan.Annotate[1] != "3" {
+		t.Errorf("Annotate = %v, want [0 3]", plan.Annotate)
+	}
+
+	skipped := map[string]SkippedInput{}
+	for _, s := range plan.Skipped {
+		skipped[s.Filename] = s
+	}
+	if s := skipped["b.jpg"]; s.Reason != SkipDuplicate || s.Detail != "duplicate of 0" {
+		t.Errorf("b.jpg skipped as %q (%s), want a duplicate of 0", s.Reason, s.Detail)
+	}
+	if s := skipped["notes.txt"]; s.Reason != string(utils.ClassUnsupportedFormat) {
+		t.Errorf("notes.txt skipped as %q, want %q", s.Reason, utils.ClassUnsupportedFormat)
+	}
+
+	// Planning leaves the caller's inputs untouched
+	if inputs[0].ID != "" {
+		t.Errorf("Plan() assigned ID %q to the caller's input", inputs[0].ID)
+	}
+}
@@ -3,6 +3,9 @@ package processor
 import (
 	"context"
 	"io"
+	"time"
+
+	"vision_api/internal/image"
 )
 
 // ImageProcessor defines the core interface for image processing operations
@@ -31,18 +34,83 @@ type Handler interface {
 
 // ProgressTracker defines the interface for tracking processing progress
 type ProgressTracker interface {
-	// Update updates the current progress
-	Update(current, total int64)
+	// Update reports how many inputs of the batch succeeded, failed and
+	// were skipped so far
+	Update(succeeded, failed, skipped int64)
+
+	// UpdateStage reports how many jobs are queued for and active in a stage
+	UpdateStage(stage string, queued, active int)
+
+	// SetPaused reports that processing was paused or resumed
+	SetPaused(paused bool)
 
 	// Finish marks the processing as complete
 	Finish()
+}
+
+// MetricsRecorder receives processing metrics
+type MetricsRecorder interface {
+	// ObserveStage records the time an image spent in a pipeline stage
+	ObserveStage(stage string, duration time.Duration)
+
+	// ObserveImage records a finished image by status: success, failed or
+	// skipped
+	ObserveImage(status string)
+
+	// ObserveDuplicate records an image served from the result of its duplicate
+	ObserveDuplicate()
+
+	// ObserveCacheLookup records an image of a batch that was annotated
+	// (miss) or served from the result of its duplicate (hit)
+	ObserveCacheLookup(hit bool)
+}
+
+// Controller is implemented by processors whose throughput can be changed
+// while a batch is running
+type Controller interface {
+	// Pause holds back new work; work in flight is finished
+	Pause()
+
+	// Resume continues after Pause
+	Resume()
+
+	// Paused reports whether processing is paused
+	Paused() bool
+
+	// SetPoolSize changes the number of concurrent API calls
+	SetPoolSize(size int) error
+
+	// PoolSize returns the number of concurrent API calls
+	PoolSize() int
+}
+
+// Drainer is implemented by processors that can stop taking new work
+// while letting work in flight finish, for a graceful shutdown
+type Drainer interface {
+	// Drain stops admitting new inputs; admitted inputs are finished
+	Drain()
+}
 
-	// Error reports an error in processing
-	Error(err error)
+// SpendGuard stops a batch from starting new images once a spend limit
+// has been reached. Images already in flight are finished.
+type SpendGuard interface {
+	// Exhausted reports whether a hard spend cap has been reached
+	Exhausted() bool
+}
+
+// BatchPlanner is implemented by processors that can report which inputs
+// of a batch would be annotated without running it
+type BatchPlanner interface {
+	// Plan applies the batch filters to the inputs without processing them
+	Plan(ctx context.Context, inputs []ProcessInput) (*Plan, error)
 }
 
 // ProcessInput represents the input for image processing
 type ProcessInput struct {
+	// ID identifies the input and is carried by its output. ProcessBatch
+	// assigns the input's index if it is empty.
+	ID string
+
 	// Reader provides the image data
 	Reader io.Reader
 
@@ -55,53 +123,80 @@ type ProcessInput struct {
 
 // ProcessOutput represents the result of image processing
 type ProcessOutput struct {
+	// ID is the ID of the input this output belongs to
+	ID string
+
 	// Data contains the processed image data
 	Data []byte
 
 	// Filename is the output filename
 	Filename string
 
+	// CorrelationID identifies the image in logs, traces and the dataset
+	CorrelationID string
+
 	// Labels contains vision API labels
 	Labels []Label
 
-	// Error contains any processing error
+	// Objects contains localized objects when object localization is requested
+	Objects []ObjectAnnotation
+
+	// EXIF contains the camera and capture details of the source image, if any
+	EXIF *image.EXIF
+
+	// Quality contains the quality scores of the source image when quality
+	// screening is enabled
+	Quality *image.QualityScores
+
+	// Frames contains the results of the sampled frames of animated images,
+	// whose Labels are the union of the frame labels
+	Frames []FrameResult
+
+	// Error contains any processing error. Inputs that never started
+	// because the batch was canceled carry utils.ErrNotProcessed.
 	Error error
 
+	// Attempts records every processing attempt, including failed ones
+	Attempts []Attempt
+
 	// Metadata contains additional output information
 	Metadata map[string]interface{}
 }
 
+// FrameResult holds the annotations of one sampled frame of an animation
+type FrameResult struct {
+	// Index is the position of the frame in the animation, starting at 0
+	Index   int                `json:"index"`
+	Labels  []Label            `json:"labels"`
+	Objects []ObjectAnnotation `json:"objects,omitempty"`
+}
+
 // Label represents a vision API label
 type Label struct {
 	Description string  `json:"description"`
 	Score       float64 `json:"score"`
 }
 
-// Options contains configuration for the processor
-type Options struct {
-	// MaxRetries specifies the maximum number of retries for failed operations
-	MaxRetries int
-
-	// BatchSize specifies the size of processing batches
-	BatchSize int
-
-	// Concurrent specifies whether to process images concurrently
-	Concurrent bool
-
-	// MaxConcurrent specifies the maximum number of concurrent operations
-	MaxConcurrent int
+// ObjectAnnotation represents a localized object detected by the vision API
+type ObjectAnnotation struct {
+	Name     string   `json:"name"`
+	Score    float64  `json:"score"`
+	Vertices []Vertex `json:"vertices"`
+}
 
-	// ErrorHandler handles errors during processing
-	ErrorHandler func(error)
+// Vertex represents a normalized bounding polygon vertex in the stored
+// pixels of the source image, before any EXIF orientation is applied
+type Vertex struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
 }
 
-// DefaultOptions returns the default processor options
-func DefaultOptions() Options {
-	return Options{
-		MaxRetries:    3,
-		BatchSize:     100,
-		Concurrent:    true,
-		MaxConcurrent: 8,
-		ErrorHandler:  func(err error) {},
-	}
+// Attempt records a single processing attempt for an input
+type Attempt struct {
+	Number     int       `json:"number"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Error      string    `json:"error,omitempty"`
+	ErrorClass string    `json:"error_class,omitempty"`
+	Retries    int       `json:"retries,omitempty"` // calls the vision client repeated
 }
@@ -0,0 +1,208 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"vision_api/internal/image"
+)
+
+// maxBypass is how often a deferred job may be overtaken by smaller jobs
+// before the scheduler stops admitting new work until the deferred job fits
+const maxBypass = 64
+
+// imageCost is the memory an image is expected to hold while in flight
+type imageCost struct {
+	bytes  int64
+	pixels int64
+}
+
+// memoryBudget limits the total encoded bytes and decoded pixels of the
+// images in flight. A limit of zero disables that dimension.
+type memoryBudget struct {
+	mu        sync.Mutex
+	maxBytes  int64
+	maxPixels int64
+	bytes     int64
+	pixels    int64
+	inFlight  int
+	released  chan struct{}
+}
+
+// newMemoryBudget creates a budget with the given limits
+func newMemoryBudget(maxBytes, maxPixels int64) *memoryBudget {
+	return &memoryBudget{
+		maxBytes:  maxBytes,
+		maxPixels: maxPixels,
+		released:  make(chan struct{}),
+	}
+}
+
+// tryAcquire reserves budget for cost if it fits. An image larger than the
+// whole budget is admitted only when nothing else is in flight, so it can
+// never block forever.
+func (b *memoryBudget) tryAcquire(cost imageCost) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.inFlight > 0 {
+		if b.maxBytes > 0 && b.bytes+cost.bytes > b.maxBytes {
+			return false
+		}
+		if b.maxPixels > 0 && b.pixels+cost.pixels > b.maxPixels {
+			return false
+		}
+	}
+
+	b.bytes += cost.bytes
+	b.pixels += cost.pixels
+	b.inFlight++
+	return true
+}
+
+// release returns the budget held by cost and wakes up waiting schedulers
+func (b *memoryBudget) release(cost imageCost) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bytes -= cost.bytes
+	b.pixels -= cost.pixels
+	b.inFlight--
+
+	close(b.released)
+	b.released = make(chan struct{})
+}
+
+// releasedCh returns a channel that is closed on the next release
+func (b *memoryBudget) releasedCh() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.released
+}
+
+// deferredJob is a job waiting for enough budget to be released
+type deferredJob struct {
+	job      batchJob
+	bypassed int
+}
+
+// schedule feeds jobs to workers while keeping in-flight memory within
+// budget. Jobs that don't fit are deferred so smaller images keep flowing;
+// once a deferred job has been overtaken maxBypass times, no new jobs are
+// admitted until it fits.
+func (p *VisionProcessor) schedule(ctx context.Context, pending []batchJob, jobs chan<- batchJob) {
+	defer close(jobs)
+
+	send := func(job batchJob) bool {
+		if err := p.gate.waitResumed(ctx); err != nil {
+			p.budget.release(job.cost)
+			return false
+		}
+		if p.spendExhausted() {
+			p.budget.release(job.cost)
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			p.budget.release(job.cost)
+			return false
+		case jobs <- job:
+			return true
+		}
+	}
+
+	var deferred []deferredJob
+	next := 0
+	for next < len(pending) || len(deferred) > 0 {
+		// Grab the release signal before trying so no release is missed
+		released := p.budget.releasedCh()
+
+		// Deferred jobs go first, oldest first
+		remaining := deferred[:0]
+		for _, d := range deferred {
+			if p.budget.tryAcquire(d.job.cost) {
+				if !send(d.job) {
+					return
+				}
+				continue
+			}
+			remaining = append(remaining, d)
+		}
+		deferred = remaining
+
+		starving := len(deferred) > 0 && deferred[0].bypassed >= maxBypass
+		if next < len(pending) && !starving {
+			job := pending[next]
+			next++
+			job.cost = p.estimateCost(job.input)
+
+			if !p.budget.tryAcquire(job.cost) {
+				deferred = append(deferred, deferredJob{job: job})
+				continue
+			}
+			for i := range deferred {
+				deferred[i].bypassed++
+			}
+			if !send(job) {
+				return
+			}
+			continue
+		}
+
+		// Nothing fits right now; wait for in-flight work to finish
+		select {
+		case <-ctx.Done():
+			return
+		case <-released:
+		}
+	}
+}
+
+// spendExhausted reports whether the spend guard forbids new images
+func (p *VisionProcessor) spendExhausted() bool {
+	return p.options.SpendGuard != nil && p.options.SpendGuard.Exhausted()
+}
+
+// estimateCost estimates the encoded size and decoded pixel count of an
+// input from its file size and image header, without decoding it
+func (p *VisionProcessor) estimateCost(input ProcessInput) imageCost {
+	cost := imageCost{bytes: -1, pixels: -1}
+
+	if path := inputPath(input); path != "" {
+		if file, err := os.Open(path); err == nil {
+			if stat, err := file.Stat(); err == nil {
+				cost.bytes = stat.Size()
+			}
+			if dims, _, err := image.Probe(file); err == nil {
+				cost.pixels = dims.Pixels()
+			}
+			file.Close()
+		}
+	} else if seeker, ok := input.Reader.(io.ReadSeeker); ok {
+		// The image starts at the current position, which is restored
+		// for the pipeline afterwards
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			if end, err := seeker.Seek(0, io.SeekEnd); err == nil {
+				cost.bytes = end - start
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err == nil {
+				if dims, _, err := image.Probe(seeker); err == nil {
+					cost.pixels = dims.Pixels()
+				}
+			}
+			seeker.Seek(start, io.SeekStart)
+		}
+	}
+
+	// Inputs that can't be measured are assumed to take a fair share
+	if cost.bytes < 0 {
+		cost.bytes = p.options.MaxFileSize
+	}
+	if cost.pixels < 0 {
+		cost.pixels = p.options.MaxInflightPixels / int64(p.options.PoolSize)
+	}
+
+	return cost
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"bytes"
+	goimage "image"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	b := newMemoryBudget(100, 0)
+
+	// An image larger than the whole budget still runs on its own
+	huge := imageCost{bytes: 500}
+	if !b.tryAcquire(huge) {
+		t.Fatal("tryAcquire() rejected an oversized image with nothing in flight")
+	}
+	if b.tryAcquire(imageCost{bytes: 1}) {
+		t.Fatal("tryAcquire() admitted an image beyond the budget")
+	}
+
+	released := b.releasedCh()
+	b.release(huge)
+	select {
+	case <-released:
+	default:
+		t.Fatal("release() did not signal waiting schedulers")
+	}
+
+	small := imageCost{bytes: 40, pixels: 1 << 40} // pixels are unlimited
+	for i := 0; i < 2; i++ {
+		if !b.tryAcquire(small) {
+			t.Fatalf("tryAcquire() #%d rejected an image within budget", i+1)
+		}
+	}
+	if b.tryAcquire(small) {
+		t.Error("tryAcquire() admitted 120 bytes into a 100 byte budget")
+	}
+}
+
+func TestEstimateCostKeepsReaderPosition(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, goimage.NewGray(goimage.Rect(0, 0, 30, 20))); err != nil {
+		t.Fatal(err)
+	}
+
+	// The image starts after a prefix the caller already consumed
+	const prefix = "header:"
+	reader := bytes.NewReader(append([]byte(prefix), encoded.Bytes()...))
+	if _, err := reader.Seek(int64(len(prefix)), io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	options := defaultOptions()
+	p := &VisionProcessor{options: options}
+	cost := p.estimateCost(ProcessInput{Filename: "image.png", Reader: reader})
+
+	if want := (imageCost{bytes: int64(encoded.Len()), pixels: 600}); cost != want {
+		t.Errorf("estimateCost() = %+v, want %+v", cost, want)
+	}
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rest, encoded.Bytes()) {
+		t.Errorf("reader left at a different position: %d bytes remain, want %d", len(rest), encoded.Len())
+	}
+}
+
+func TestEstimateCostUnmeasurable(t *testing.T) {
+	options := defaultOptions()
+	p := &VisionProcessor{options: options}
+
+	cost := p.estimateCost(ProcessInput{Filename: "stream.jpg", Reader: io.LimitReader(nil, 0)})
+	want := imageCost{
+		bytes:  options.MaxFileSize,
+		pixels: options.MaxInflightPixels / int64(options.PoolSize),
+	}
+	if cost != want {
+		t.Errorf("estimateCost() = %+v, want the fair share %+v", cost, want)
+	}
+}
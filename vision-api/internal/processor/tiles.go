@@ -0,0 +1,239 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"vision_api/internal/image"
+	"vision_api/internal/utils"
+	"vision_api/pkg/vision"
+)
+
+// LabelScoring selects how the labels of tiles are combined into image
+// labels
+type LabelScoring string
+
+const (
+	// ScoreMax keeps the highest score a label has in any region
+	ScoreMax LabelScoring = "max"
+
+	// ScoreMean averages the score of a label over all regions, counting
+	// regions without it as 0, so labels covering the image rank higher
+	ScoreMean LabelScoring = "mean"
+)
+
+// IsValid reports whether the scoring is supported
+func (s LabelScoring) IsValid() bool {
+	return s == ScoreMax || s == ScoreMean
+}
+
+// preparedTile is a prepared tile of an image and the region of the
+// upright image it covers
+type preparedTile struct {
+	file   *utils.FileInfo
+	region box
+}
+
+// box is an axis-aligned rectangle in normalized image coordinates
+type box struct {
+	minX, minY, maxX, maxY float64
+}
+
+// area returns the area of b
+func (b box) area() float64 {
+	return max(0, b.maxX-b.minX) * max(0, b.maxY-b.minY)
+}
+
+// iou returns the intersection over union of two boxes
+func iou(a, b box) float64 {
+	intersection := box{
+		minX: max(a.minX, b.minX),
+		minY: max(a.minY, b.minY),
+		maxX: min(a.maxX, b.maxX),
+		maxY: min(a.maxY, b.maxY),
+	}.area()
+	union := a.area() + b.area() - intersection
+	if union <= 0 {
+		return 0
+	}
+	return intersection / union
+}
+
+// boundingBox returns the box enclosing a polygon
+func boundingBox(vertices []vision.Vertex) box {
+	if len(vertices) == 0 {
+		return box{}
+	}
+	b := box{minX: vertices[0].X, minY: vertices[0].Y, maxX: vertices[0].X, maxY: vertices[0].Y}
+	for _, v := range vertices[1:] {
+		b.minX, b.maxX = min(b.minX, v.X), max(b.maxX, v.X)
+		b.minY, b.maxY = min(b.minY, v.Y), max(b.maxY, v.Y)
+	}
+	return b
+}
+
+// tiled reports whether an image is large enough to be split into tiles
+func (p *VisionProcessor) tiled(metadata *image.Metadata) bool {
+	size := p.options.TileSize
+	return size > 0 && metadata != nil &&
+		(metadata.Dimensions.Width > size || metadata.Dimensions.Height > size)
+}
+
+// tileCount returns the number of tiles an input is split into, or 0 if it
+// isn't tiled. Inputs whose size can't be read from their path count as
+// untiled.
+func (p *VisionProcessor) tileCount(input ProcessInput) int {
+	if p.options.TileSize == 0 || inputPath(input) == "" {
+		return 0
+	}
+	file, err := openInput(input)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	dims, _, err := image.Probe(file)
+	if err != nil || !p.tiled(&image.Metadata{Dimensions: dims}) {
+		return 0
+	}
+	return image.TileCount(dims, image.TileOptions{Size: p.options.TileSize, Overlap: p.options.TileOverlap})
+}
+
+// prepareTiles splits an image into tiles and writes each to a temp file
+func (p *VisionProcessor) prepareTiles(ctx context.Context, input ProcessInput, data []byte) ([]preparedTile, error) {
+	tiling := image.TileOptions{Size: p.options.TileSize, Overlap: p.options.TileOverlap}
+	tiles, dims, err := p.options.ImageHandler.Tile(ctx, bytes.NewReader(data), tiling, image.ProcessOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	prepared := make([]preparedTile, len(tiles))
+	for i, tile := range tiles {
+		info, err := p.writeTemp(fmt.Sprintf("vision-%s-tile%d-", input.Filename, i+1), tile)
+		if err != nil {
+			return nil, err
+		}
+		prepared[i] = preparedTile{
+			file: info,
+			region: box{
+				minX: float64(tile.Bounds.Min.X) / float64(dims.Width),
+				minY: float64(tile.Bounds.Min.Y) / float64(dims.Height),
+				maxX: float64(tile.Bounds.Max.X) / float64(dims.Width),
+				maxY: float64(tile.Bounds.Max.Y) / float64(dims.Height),
+			},
+		}
+	}
+	return prepared, nil
+}
+
+// writeTemp writes data to a new temp file
+func (p *VisionProcessor) writeTemp(prefix string, data io.Reader) (*utils.FileInfo, error) {
+	tempFile, err := p.tempManager.CreateTemp(prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer tempFile.Close()
+
+	if _, err := io.Copy(tempFile, data); err != nil {
+		return nil, fmt.Errorf("failed to write prepared image: %w", err)
+	}
+	return utils.GetFileInfo(tempFile.Name())
+}
+
+// annotateTiles annotates the tiles of a job and merges their results
+// with the response for the whole image. Tile objects are mapped to the
+// whole image and duplicates across tile seams are suppressed.
+func (p *VisionProcessor) annotateTiles(ctx context.Context, job *pipelineJob, whole *vision.AnnotateResponse, imageContext *vision.ImageContext) (*vision.AnnotateResponse, []Attempt, error) {
+	var attempts []Attempt
+	labels := [][]vision.Label{whole.Labels}
+	objects := append([]vision.ObjectAnnotation(nil), whole.Objects...)
+
+	for i, tile := range job.source.tiles {
+		response, tileAttempts, err := p.annotateOnce(ctx, job.input, tile.file, imageContext)
+		attempts = append(attempts, tileAttempts...)
+		if err != nil {
+			return nil, attempts, fmt.Errorf("tile %d of %d: %w", i+1, len(job.source.tiles), err)
+		}
+		labels = append(labels, response.Labels)
+		objects = append(objects, remapObjects(response.Objects, tile.region)...)
+	}
+
+	merged := *whole
+	merged.Labels = mergeLabels(labels, p.options.TileLabelScoring)
+	merged.Objects = suppressObjects(objects, p.options.TileNMSThreshold)
+	return &merged, attempts, nil
+}
+
+// remapObjects maps the normalized vertices of objects found in a tile to
+// the image the tile was cut from
+func remapObjects(objects []vision.ObjectAnnotation, region box) []vision.ObjectAnnotation {
+	remapped := make([]vision.ObjectAnnotation, len(objects))
+	for i, object := range objects {
+		vertices := make([]vision.Vertex, len(object.BoundingBox.NormalizedVertices))
+		for j, v := range object.BoundingBox.NormalizedVertices {
+			vertices[j] = vision.Vertex{
+				X: region.minX + v.X*(region.maxX-region.minX),
+				Y: region.minY + v.Y*(region.maxY-region.minY),
+			}
+		}
+		remapped[i] = object
+		remapped[i].BoundingBox = vision.BoundingPoly{NormalizedVertices: vertices}
+	}
+	return remapped
+}
+
+// suppressObjects applies non-maximum suppression: of the objects with the
+// same name whose bounding boxes overlap by more than threshold IoU, only
+// the highest scoring one is kept. The result is sorted by score.
+func suppressObjects(objects []vision.ObjectAnnotation, threshold float64) []vision.ObjectAnnotation {
+	sorted := append([]vision.ObjectAnnotation(nil), objects...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+
+	var kept []vision.ObjectAnnotation
+	var boxes []box
+	for _, object := range sorted {
+		b := boundingBox(object.BoundingBox.NormalizedVertices)
+		duplicate := false
+		for i, k := range kept {
+			if k.Name == object.Name && iou(boxes[i], b) > threshold {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			kept = append(kept, object)
+			boxes = append(boxes, b)
+		}
+	}
+	return kept
+}
+
+// mergeLabels combines the labels of several regions into image labels,
+// sorted by score
+func mergeLabels(regions [][]vision.Label, scoring LabelScoring) []vision.Label {
+	var merged []vision.Label
+	index := make(map[string]int)
+	for _, labels := range regions {
+		for _, label := range labels {
+			i, ok := index[label.Description]
+			if !ok {
+				i = len(merged)
+				index[label.Description] = i
+				merged = append(merged, vision.Label{Description: label.Description})
+			}
+			if scoring == ScoreMean {
+				merged[i].Score += label.Score / float64(len(regions))
+				merged[i].Topicality += label.Topicality / float64(len(regions))
+			} else {
+				merged[i].Score = max(merged[i].Score, label.Score)
+				merged[i].Topicality = max(merged[i].Topicality, label.Topicality)
+			}
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+	return merged
+}
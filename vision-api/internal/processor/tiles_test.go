@@ -0,0 +1,161 @@
+package processor
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"vision_api/pkg/vision"
+)
+
+// object returns an object annotation with a rectangular bounding box
+func object(name string, score, minX, minY, maxX, maxY float64) vision.ObjectAnnotation {
+	return vision.ObjectAnnotation{
+		Name:  name,
+		Score: score,
+		BoundingBox: vision.BoundingPoly{NormalizedVertices: []vision.Vertex{
+			{X: minX, Y: minY}, {X: maxX, Y: minY}, {X: maxX, Y: maxY}, {X: minX, Y: maxY},
+		}},
+	}
+}
+
+func TestIOU(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b box
+		want float64
+	}{
+		{name: "identical", a: box{0, 0, 1, 1}, b: box{0, 0, 1, 1}, want: 1},
+		{name: "disjoint", a: box{0, 0, 0.4, 0.4}, b: box{0.5, 0.5, 1, 1}, want: 0},
+		{name: "touching", a: box{0, 0, 0.5, 1}, b: box{0.5, 0, 1, 1}, want: 0},
+		{name: "half overlap", a: box{0, 0, 0.5, 1}, b: box{0.25, 0, 0.75, 1}, want: 1.0 / 3},
+		{name: "contained", a: box{0, 0, 1, 1}, b: box{0.25, 0.25, 0.75, 0.75}, want: 0.25},
+		{name: "empty boxes", a: box{}, b: box{}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := iou(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("iou() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemapObjects(t *testing.T) {
+	objects := []vision.ObjectAnnotation{object("cat", 0.9, 0, 0, 1, 0.5)}
+	region := box{minX: 0.5, minY: 0.25, maxX: 1, maxY: 0.75}
+
+	got := remapObjects(objects, region)
+	want := []vision.Vertex{{X: 0.5, Y: 0.25}, {X: 1, Y: 0.25}, {X: 1, Y: 0.5}, {X: 0.5, Y: 0.5}}
+	if !reflect.DeepEqual(got[0].BoundingBox.NormalizedVertices, want) {
+		t.Errorf("remapObjects() vertices = %v, want %v", got[0].BoundingBox.NormalizedVertices, want)
+	}
+	if objects[0].BoundingBox.NormalizedVertices[1].X != 1 || objects[0].BoundingBox.NormalizedVertices[2].Y != 0.5 {
+		t.Errorf("remapObjects() modified its input")
+	}
+}
+
+func TestSuppressObjects(t *testing.T) {
+	tests := []struct {
+		name      string
+		objects   []vision.ObjectAnnotation
+		threshold float64
+		want      []string // name@score of the kept objects
+	}{
+		{
+			name: "overlapping duplicates keep the best",
+			objects: []vision.ObjectAnnotation{
+				object("cat", 0.7, 0, 0, 0.5, 0.5),
+				object("cat", 0.9, 0.05, 0, 0.55, 0.5),
+			},
+			threshold: 0.5,
+			want:      []string{"cat@0.9"},
+		},
+		{
+			name: "different names are kept",
+			objects: []vision.ObjectAnnotation{
+				object("cat", 0.7, 0, 0, 0.5, 0.5),
+				object("dog", 0.9, 0, 0, 0.5, 0.5),
+			},
+			threshold: 0.5,
+			want:      []string{"dog@0.9", "cat@0.7"},
+		},
+		{
+			name: "overlap below threshold is kept",
+			objects: []vision.ObjectAnnotation{
+				object("cat", 0.9, 0, 0, 0.5, 1),
+				object("cat", 0.8, 0.25, 0, 0.75, 1),
+			},
+			threshold: 0.5,
+			want:      []string{"cat@0.9", "cat@0.8"},
+		},
+		{
+			name: "a suppressed object doesn't suppress others",
+			objects: []vision.ObjectAnnotation{
+				object("cat", 0.9, 0, 0, 0.5, 1),
+				object("cat", 0.8, 0.1, 0, 0.6, 1),
+				object("cat", 0.7, 0.4, 0, 0.9, 1),
+			},
+			threshold: 0.5,
+			want:      []string{"cat@0.9", "cat@0.7"},
+		},
+		{
+			name: "seam duplicates from tiles",
+			objects: append(
+				[]vision.ObjectAnnotation{object("car", 0.6, 0.4, 0.4, 0.55, 0.55)},
+				remapObjects([]vision.ObjectAnnotation{object("car", 0.8, 0.8, 0.8, 1, 1)}, box{0, 0, 0.5, 0.5})...,
+			),
+			threshold: 0.3,
+			want:      []string{"car@0.8"},
+		},
+		{name: "no objects", threshold: 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, o := range suppressObjects(tt.objects, tt.threshold) {
+				got = append(got, o.Name+"@"+formatScore(o.Score))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("suppressObjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeLabels(t *testing.T) {
+	regions := [][]vision.Label{
+		{{Description: "street", Score: 0.9}, {Description: "car", Score: 0.4}},
+		{{Description: "car", Score: 0.8}},
+		{{Description: "street", Score: 0.6}, {Description: "sign", Score: 0.3}},
+		{},
+	}
+
+	tests := []struct {
+		scoring LabelScoring
+		want    []string // description@score, sorted by score
+	}{
+		{scoring: ScoreMax, want: []string{"street@0.9", "car@0.8", "sign@0.3"}},
+		{scoring: ScoreMean, want: []string{"street@0.375", "car@0.3", "sign@0.075"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scoring), func(t *testing.T) {
+			var got []string
+			for _, label := range mergeLabels(regions, tt.scoring) {
+				got = append(got, label.Description+"@"+formatScore(label.Score))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// formatScore formats a score rounded to hide floating point noise
+func formatScore(score float64) string {
+	return strconv.FormatFloat(math.Round(score*1e6)/1e6, 'g', -1, 64)
+}
@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"vision_api/internal/logging"
+	"vision_api/internal/utils"
+)
+
+// tracer creates the processor spans; it is a no-op until a tracer
+// provider is installed
+var tracer = otel.Tracer("vision_api/internal/processor")
+
+// imageAttributes returns the span attributes describing an input image
+func imageAttributes(job batchJob) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("image.filename", job.input.Filename),
+		attribute.Int("image.index", job.index),
+		attribute.String(logging.CorrelationKey, job.correlationID),
+	}
+	if path := inputPath(job.input); path != "" {
+		attrs = append(attrs, attribute.String("image.path", path))
+	}
+	if job.cost.bytes > 0 {
+		attrs = append(attrs, attribute.Int64("image.input_bytes", job.cost.bytes))
+	}
+	if job.cost.pixels > 0 {
+		attrs = append(attrs, attribute.Int64("image.pixels", job.cost.pixels))
+	}
+	return attrs
+}
+
+// endSpan records err on the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.String("error.class", string(utils.ClassifyError(err))))
+	}
+	span.End()
+}
+
+// jobContext returns ctx carrying the job's image span and correlation ID
+// so stage spans and logs are tied to the image even when stages run on
+// different workers
+func jobContext(ctx context.Context, job *pipelineJob) context.Context {
+	ctx = logging.WithCorrelationID(ctx, job.correlationID)
+	if job.span == nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, job.span)
+}
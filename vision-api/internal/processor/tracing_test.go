@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"vision_api/internal/logging"
+	"vision_api/internal/utils"
+)
+
+func TestImageSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer provider.Shutdown(context.Background())
+
+	p := &VisionProcessor{options: defaultOptions(), logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	job := &pipelineJob{batchJob: batchJob{
+		index:         3,
+		input:         ProcessInput{ID: "3", Filename: "missing.jpg"},
+		correlationID: "abc123",
+	}}
+
+	// An input without a reader or path fails in the prepare stage
+	p.prepareStage(context.Background(), job)
+	p.persistStage(context.Background(), job)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	image, prepare := spans["process_image"], spans[StagePrepare]
+	if image == nil || prepare == nil {
+		t.Fatalf("ended spans = %v, want process_image and %s", spans, StagePrepare)
+	}
+
+	if prepare.Parent().SpanID() != image.SpanContext().SpanID() {
+		t.Error("prepare span is not a child of the image span")
+	}
+	if prepare.Status().Code != codes.Error {
+		t.Errorf("prepare status = %v, want %v", prepare.Status().Code, codes.Error)
+	}
+
+	attrs := map[string]string{}
+	for _, attr := range append(image.Attributes(), prepare.Attributes()...) {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	want := map[string]string{
+		"image.filename":       "missing.jpg",
+		"image.index":          "3",
+		logging.CorrelationKey: "abc123",
+		"error.class":          string(utils.ClassInvalidInput),
+	}
+	for key, value := range want {
+		if attrs[key] != value {
+			t.Errorf("attribute %s = %q, want %q", key, attrs[key], value)
+		}
+	}
+}
@@ -1,20 +1,37 @@
 package processor
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
-	"../utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"vision_api/internal/image"
+	"vision_api/internal/logging"
+	"vision_api/internal/utils"
+	"vision_api/pkg/vision"
 )
 
 // VisionProcessor handles image processing with Vision API integration
 type VisionProcessor struct {
-	options     *Options
+	options     *ProcessorOptions
 	tracker     ProgressTracker
 	tempManager *utils.TempFileManager
+	budget      *memoryBudget
+	gate        *gate
+	handlers    []Handler
+	logger      *slog.Logger
 	mu          sync.RWMutex
 }
 
@@ -34,72 +51,193 @@ func NewProcessor(opts ...OptionFunc) (*VisionProcessor, error) {
 		return nil, fmt.Errorf("failed to create temp manager: %w", err)
 	}
 
-	return &VisionProcessor{
+	p := &VisionProcessor{
 		options:     options,
 		tempManager: tempManager,
-	}, nil
+		budget:      newMemoryBudget(options.MaxInflightBytes, options.MaxInflightPixels),
+		logger:      options.Logger.With(logging.ComponentKey, "processor"),
+	}
+	p.gate = newGate(p.annotateWorkers())
+	return p, nil
 }
 
 // Process implements the ImageProcessor interface
 func (p *VisionProcessor) Process(ctx context.Context, input ProcessInput) (ProcessOutput, error) {
-	startTime := time.Now()
-
-	// Validate input
-	if err := p.validateInput(input); err != nil {
-		return ProcessOutput{}, err
+	job := &pipelineJob{
+		batchJob:  batchJob{input: input, correlationID: logging.NewCorrelationID()},
+		startTime: time.Now(),
 	}
 
-	// Process image
-	output, err := p.processImage(ctx, input)
+	p.prepareStage(ctx, job)
+	p.gatedAnnotateStage(ctx, job)
+	p.persistStage(ctx, job)
 
-	// Record metrics
-	p.recordMetrics(time.Since(startTime), err == nil)
+	return job.output, job.err
+}
 
-	return output, err
+// batchJob is a batch input tagged with its position in the batch
+type batchJob struct {
+	index         int
+	input         ProcessInput
+	cost          imageCost
+	correlationID string
 }
 
-// ProcessBatch implements batch processing
+// ProcessBatch implements batch processing. Every input yields exactly one
+// output carrying its ID; inputs that never reached the API because ctx
+// was canceled, the spend guard was exhausted or the processor was drained
+// are reported with utils.ErrNotProcessed. Those the scheduler never
+// admitted come after all other outputs.
 func (p *VisionProcessor) ProcessBatch(ctx context.Context, inputs []ProcessInput) ([]ProcessOutput, error) {
 	if len(inputs) == 0 {
 		return nil, nil
 	}
 
-	// Create buffered channels for processing
-	jobs := make(chan ProcessInput, len(inputs))
-	results := make(chan ProcessOutput, len(inputs))
-	errors := make(chan error, 1)
+	// Work on a copy so buffered readers and assigned IDs don't leak back
+	// to the caller
+	inputs = append([]ProcessInput(nil), inputs...)
+	for i := range inputs {
+		if inputs[i].ID == "" {
+			inputs[i].ID = strconv.Itoa(i)
+		}
+	}
 
-	// Start worker pool
-	var wg sync.WaitGroup
-	for i := 0; i < p.options.PoolSize; i++ {
-		wg.Add(1)
-		go p.worker(ctx, &wg, jobs, results)
+	// Every image gets a correlation ID, including duplicates that are
+	// never annotated themselves
+	correlationIDs := make([]string, len(inputs))
+	for i := range inputs {
+		correlationIDs[i] = logging.NewCorrelationID()
 	}
 
-	// Feed jobs to workers
-	go func() {
-		defer close(jobs)
-		for _, input := range inputs {
-			select {
-			case <-ctx.Done():
-				return
-			case jobs <- input:
+	// Collapse duplicates so only one representative per cluster is annotated
+	clusters := make(map[int]*duplicateCluster)
+	duplicates := make(map[int]bool)
+	if p.options.Deduplicate {
+		found, err := p.findDuplicates(ctx, inputs)
+		if err != nil {
+			return nil, fmt.Errorf("deduplication failed: %w", err)
+		}
+		if len(found) > 0 {
+			p.logger.InfoContext(ctx, "duplicates found", "clusters", len(found))
+		}
+		for _, cluster := range found {
+			clusters[cluster.Representative] = cluster
+			for _, index := range cluster.Duplicates {
+				duplicates[index] = true
 			}
 		}
-	}()
+	}
 
-	// Collect results
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	errors := make(chan error, 1)
 
-	// Gather all results
+	// Feed jobs to the pipeline; the scheduler holds back inputs until the
+	// memory budget allows them in
+	pending := make([]batchJob, 0, len(inputs)-len(duplicates))
+	for i, input := range inputs {
+		if !duplicates[i] {
+			pending = append(pending, batchJob{index: i, input: input, correlationID: correlationIDs[i]})
+		}
+	}
+	results := p.runPipeline(ctx, pending)
+
+	// Gather all results, fanning representative results out to duplicates
 	outputs := make([]ProcessOutput, 0, len(inputs))
-	for result := range results {
-		outputs = append(outputs, result)
-		if p.tracker != nil {
-			p.tracker.Update(int64(len(outputs)), int64(len(inputs)))
+	if p.options.PreserveOrder {
+		outputs = outputs[:len(inputs)]
+	}
+	finished := make([]bool, len(inputs))
+	completed := 0
+	var succeeded, failed, skipped int64
+	var letters []deadLetterUpdate
+	p.mu.RLock()
+	tracker := p.tracker
+	p.mu.RUnlock()
+	collect := func(index int, output ProcessOutput) {
+		input := inputs[index]
+		finished[index] = true
+		completed++
+		switch {
+		case output.Error == nil:
+			succeeded++
+		case utils.ClassifyError(output.Error) == utils.ClassSkipped:
+			skipped++
+		default:
+			failed++
+		}
+		if tracker != nil {
+			tracker.Update(succeeded, failed, skipped)
+		}
+		if p.options.PreserveOrder {
+			outputs[index] = output
+		} else {
+			outputs = append(outputs, output)
+		}
+		// Dead letters are written once the batch is done so disk writes
+		// don't hold up the results of the pipeline
+		letters = append(letters, deadLetterUpdate{input: input, output: output})
+	}
+	for job := range results {
+		output := job.output
+		output.Error = job.err
+		p.observeCacheLookup(false)
+		if cluster, ok := clusters[job.index]; ok {
+			markRepresentative(&output, cluster)
+			collect(job.index, output)
+			for _, index := range cluster.Duplicates {
+				p.observeCacheLookup(true)
+				p.observeDuplicate()
+				duplicate := fanOutDuplicate(output, inputs[job.index], inputs[index], cluster)
+				duplicate.CorrelationID = correlationIDs[index]
+				if duplicate.Error == nil && p.options.OutputDir != "" {
+					// Duplicates get their own output file pointing at the original
+					if err := p.saveResults(logging.WithCorrelationID(ctx, duplicate.CorrelationID), duplicate); err != nil {
+						duplicate.Error = utils.NewProcessError("save", inputs[index].Filename, err, "failed to save results")
+					}
+				}
+				collect(index, duplicate)
+			}
+		} else {
+			collect(job.index, output)
+		}
+	}
+
+	// Report inputs the scheduler never admitted because ctx was canceled,
+	// a spend cap was reached or the processor was drained. Those stopped
+	// by a spend cap get dead letters so they can be resumed later.
+	if pending := len(inputs) - completed; pending > 0 {
+		notProcessed := utils.ErrNotProcessed
+		if err := ctx.Err(); err != nil {
+			notProcessed = fmt.Errorf("%w: %w", utils.ErrNotProcessed, err)
+		} else if p.spendExhausted() {
+			notProcessed = fmt.Errorf("%w: %w", utils.ErrNotProcessed, utils.ErrBudgetExceeded)
+		} else if p.Draining() {
+			notProcessed = fmt.Errorf("%w: %w", utils.ErrNotProcessed, errDraining)
+		}
+		p.logger.WarnContext(ctx, "batch stopped before all inputs started",
+			"pending", pending,
+			"reason", utils.ClassifyError(notProcessed),
+		)
+		for i, input := range inputs {
+			if finished[i] {
+				continue
+			}
+			collect(i, ProcessOutput{
+				ID:            input.ID,
+				Filename:      input.Filename,
+				CorrelationID: correlationIDs[i],
+				Error:         notProcessed,
+			})
+		}
+	}
+
+	for _, letter := range letters {
+		if err := p.updateDeadLetter(letter.input, letter.output); err != nil {
+			p.logger.WarnContext(logging.WithCorrelationID(ctx, letter.output.CorrelationID),
+				"failed to update dead letter", "filename", letter.input.Filename, "error", err)
+			select {
+			case errors <- fmt.Errorf("dead letter for %s: %w", letter.input.Filename, err):
+			default:
+			}
 		}
 	}
 
@@ -118,126 +256,509 @@ func (p *VisionProcessor) ProcessBatch(ctx context.Context, inputs []ProcessInpu
 	}
 }
 
-// worker processes jobs from the jobs channel
-func (p *VisionProcessor) worker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan ProcessInput, results chan<- ProcessOutput) {
-	defer wg.Done()
+// prepareStage validates the input and writes a resized copy of the image
+// to a temp file for annotation
+func (p *VisionProcessor) prepareStage(ctx context.Context, job *pipelineJob) {
+	defer p.observeStage(StagePrepare, time.Now())
+
+	// The image span covers the whole pipeline and is ended by persistStage
+	ctx, job.span = tracer.Start(ctx, "process_image", trace.WithAttributes(imageAttributes(job.batchJob)...))
+	ctx = logging.WithCorrelationID(ctx, job.correlationID)
+	ctx, span := tracer.Start(ctx, StagePrepare)
+	defer func() { endSpan(span, job.err) }()
+
+	job.output.ID = job.input.ID
+	job.output.Filename = job.input.Filename
+	job.output.CorrelationID = job.correlationID
+	if err := ctx.Err(); err != nil {
+		// Jobs canceled before they were prepared never started
+		job.err = fmt.Errorf("%w: %w", utils.ErrNotProcessed, err)
+		return
+	}
 
-	for job := range jobs {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			output, err := p.Process(ctx, job)
-			if err != nil {
-				output.Error = err
-			}
-			results <- output
-		}
+	startTime := time.Now()
+	if err := p.validateInput(job.input); err != nil {
+		job.output.Attempts = []Attempt{newAttempt(1, startTime, err)}
+		job.err = err
+		return
 	}
-}
 
-// processImage handles the core image processing logic
-func (p *VisionProcessor) processImage(ctx context.Context, input ProcessInput) (ProcessOutput, error) {
-	// Prepare image
-	processedImage, err := p.prepareImage(ctx, input)
+	prepared, source, err := p.prepareImage(ctx, job.input)
+	job.output.Quality = source.quality
+	// Skip reasons and format warnings are kept whatever happens to the image
+	if source.skipReason != "" || source.formatWarning != "" {
+		job.output.Metadata = make(map[string]interface{})
+	}
+	if source.skipReason != "" {
+		job.output.Metadata["skip_reason"] = source.skipReason
+	}
+	if source.formatWarning != "" {
+		job.output.Metadata["format_warning"] = source.formatWarning
+		p.logger.WarnContext(ctx, "image content overrides extension",
+			"filename", job.input.Filename,
+			"warning", source.formatWarning,
+		)
+	}
+	if err == nil {
+		prepared, err = p.runHandlers(ctx, prepared)
+	}
+	for i := 0; err == nil && i < len(source.tiles); i++ {
+		source.tiles[i].file, err = p.runHandlers(ctx, source.tiles[i].file)
+	}
+	for i := 0; err == nil && i < len(source.frames); i++ {
+		source.frames[i].file, err = p.runHandlers(ctx, source.frames[i].file)
+	}
 	if err != nil {
-		return ProcessOutput{}, fmt.Errorf("image preparation failed: %w", err)
+		err = utils.NewProcessError("prepare", job.input.Filename, err, "image preparation failed")
+		job.output.Attempts = []Attempt{newAttempt(1, startTime, err)}
+		job.err = err
+		return
 	}
+	job.prepared = prepared
+	job.source = source
+	job.output.EXIF = source.metadata.EXIF
+	p.logger.DebugContext(ctx, "image prepared",
+		"filename", job.input.Filename,
+		"bytes", prepared.Size,
+		"mime_type", prepared.MimeType,
+		"tiles", len(source.tiles),
+		"frames", source.frameCount,
+	)
+	job.span.SetAttributes(
+		attribute.Int64("image.prepared_bytes", prepared.Size),
+		attribute.String("image.mime_type", prepared.MimeType),
+		attribute.Int("image.tiles", len(source.tiles)),
+		attribute.Int("image.frames", source.frameCount),
+	)
+}
 
-	// Detect labels
-	labels, err := p.detectLabels(ctx, processedImage)
+// annotateStage sends a prepared image to the Vision API, retrying
+// transient failures
+func (p *VisionProcessor) annotateStage(ctx context.Context, job *pipelineJob) {
+	if job.err != nil {
+		return
+	}
+	defer p.observeStage(StageAnnotate, time.Now())
+
+	ctx, span := tracer.Start(jobContext(ctx, job), StageAnnotate)
+	defer func() { endSpan(span, job.err) }()
+
+	// Tiled images are annotated whole and tile by tile, and animations
+	// whole as the first frame and then frame by frame
+	imageContext := p.imageContext(job.source.metadata)
+	response, attempts, err := p.annotateOnce(ctx, job.input, job.prepared, imageContext)
+	if err == nil && len(job.source.tiles) > 0 {
+		var tileAttempts []Attempt
+		response, tileAttempts, err = p.annotateTiles(ctx, job, response, imageContext)
+		attempts = append(attempts, tileAttempts...)
+	}
+	var frames []FrameResult
+	if err == nil && len(job.source.frames) > 0 {
+		var frameAttempts []Attempt
+		response, frames, frameAttempts, err = p.annotateFrames(ctx, job, response, imageContext)
+		attempts = append(attempts, frameAttempts...)
+	}
+	job.output.Attempts = attempts
+	span.SetAttributes(attribute.Int("annotate.attempts", len(attempts)))
 	if err != nil {
-		return ProcessOutput{}, fmt.Errorf("label detection failed: %w", err)
+		job.err = err
+		return
 	}
 
-	// Create output
-	output := ProcessOutput{
-		Filename: input.Filename,
-		Labels:   labels,
-		Metadata: map[string]interface{}{
-			"processedAt": time.Now(),
-			"size":        processedImage.Size,
-			"format":      processedImage.Format,
-		},
+	job.output.Labels = convertLabels(response.Labels)
+	job.output.Objects = convertObjects(response.Objects, sourceOrientation(job.source.metadata))
+	job.output.Frames = frames
+	if job.output.Metadata == nil {
+		job.output.Metadata = make(map[string]interface{})
+	}
+	job.output.Metadata["processedAt"] = time.Now()
+	job.output.Metadata["size"] = job.prepared.Size
+	job.output.Metadata["format"] = job.prepared.MimeType
+	if compression := job.source.compression; compression != nil {
+		job.output.Metadata["quality"] = compression.Quality
+		job.output.Metadata["scale"] = compression.Scale
+	}
+	if len(job.source.tiles) > 0 {
+		job.output.Metadata["tiles"] = len(job.source.tiles)
+	}
+	if len(frames) > 0 {
+		job.output.Metadata["frames"] = job.source.frameCount
 	}
+}
+
+// persistStage saves the results of a successful job and records metrics
+func (p *VisionProcessor) persistStage(ctx context.Context, job *pipelineJob) {
+	defer p.observeStage(StagePersist, time.Now())
+	defer func() {
+		if job.span != nil {
+			endSpan(job.span, job.err)
+		}
+	}()
 
 	// Save results if output directory is configured
-	if p.options.OutputDir != "" {
-		if err := p.saveResults(output); err != nil {
-			return output, fmt.Errorf("failed to save results: %w", err)
+	if job.err == nil && p.options.OutputDir != "" {
+		if err := p.saveResults(jobContext(ctx, job), job.output); err != nil {
+			job.err = utils.NewProcessError("save", job.input.Filename, err, "failed to save results")
 		}
 	}
 
-	return output, nil
+	ctx = jobContext(ctx, job)
+
+	// Images held back by a drain were never sent and aren't counted
+	if errors.Is(job.err, utils.ErrNotProcessed) {
+		p.logger.DebugContext(ctx, "image not processed", "filename", job.input.Filename, "error", job.err)
+		return
+	}
+
+	// Record metrics
+	duration := time.Since(job.startTime)
+	p.recordMetrics(duration, job.err)
+
+	if errors.Is(job.err, utils.ErrSkipped) {
+		p.logger.InfoContext(ctx, "image skipped",
+			"filename", job.input.Filename,
+			"reason", job.output.Metadata["skip_reason"],
+		)
+		return
+	}
+	if job.err != nil {
+		p.logger.WarnContext(ctx, "image failed",
+			"filename", job.input.Filename,
+			"duration", duration,
+			"error_class", utils.ClassifyError(job.err),
+			"error", job.err,
+		)
+		return
+	}
+	p.logger.InfoContext(ctx, "image processed",
+		"filename", job.input.Filename,
+		"duration", duration,
+		"labels", len(job.output.Labels),
+	)
+}
+
+// sourceInfo describes the source of a prepared image, how it was encoded
+// for upload and the tiles or sampled frames annotated in addition to it
+type sourceInfo struct {
+	metadata    *image.Metadata
+	compression *image.Compression
+	tiles       []preparedTile
+	frames      []preparedFrame
+	frameCount  int
+
+	// quality holds the screening scores, and skipReason the issue that
+	// kept the image from being annotated
+	quality    *image.QualityScores
+	skipReason string
+
+	// formatWarning reports a content format that overrode the extension
+	formatWarning string
 }
 
 // prepareImage prepares an image for processing
-func (p *VisionProcessor) prepareImage(ctx context.Context, input ProcessInput) (*utils.FileInfo, error) {
-	// Create temp file for processing
-	tempFile, err := p.tempManager.CreateTemp(fmt.Sprintf("vision-%s-", input.Filename))
+func (p *VisionProcessor) prepareImage(ctx context.Context, input ProcessInput) (*utils.FileInfo, sourceInfo, error) {
+	var source sourceInfo
+
+	reader, err := openInput(input)
 	if err != nil {
-		return nil, err
+		return nil, source, err
 	}
-	defer tempFile.Close()
+	defer reader.Close()
 
-	// Process image using handler
-	if err := p.options.ImageHandler.Process(ctx, input.Reader, tempFile); err != nil {
-		return nil, err
+	data, err := io.ReadAll(io.LimitReader(reader, p.options.MaxFileSize+1))
+	if err != nil {
+		return nil, source, fmt.Errorf("failed to read input: %w", err)
+	}
+	if int64(len(data)) > p.options.MaxFileSize {
+		return nil, source, fmt.Errorf("%w: exceeds limit of %d bytes", utils.ErrImageTooLarge, p.options.MaxFileSize)
+	}
+	if source.formatWarning, err = p.checkContent(input, data); err != nil {
+		return nil, source, err
+	}
+
+	// Metadata is read from the source, since re-encoding drops EXIF data
+	if source.metadata, err = p.options.ImageHandler.GetMetadata(ctx, bytes.NewReader(data)); err != nil {
+		return nil, source, err
+	}
+	if thresholds := p.options.QualityScreening; thresholds != nil {
+		if source.quality, err = p.options.ImageHandler.AnalyzeQuality(ctx, bytes.NewReader(data)); err != nil {
+			return nil, source, err
+		}
+		if source.skipReason = thresholds.Check(source.quality); source.skipReason != "" {
+			return nil, source, fmt.Errorf("%w: %s", utils.ErrSkipped, source.skipReason)
+		}
+	}
+	if p.samplesFrames(source.metadata) {
+		if source.frames, source.frameCount, err = p.prepareFrames(ctx, input, data); err != nil {
+			return nil, source, err
+		}
+	}
+	if source.frameCount <= 1 && p.tiled(source.metadata) {
+		if source.tiles, err = p.prepareTiles(ctx, input, data); err != nil {
+			return nil, source, err
+		}
+	}
+
+	// Fit the image within the handler's size and byte limits
+	processed, err := p.options.ImageHandler.Process(ctx, bytes.NewReader(data), image.ProcessOptions{})
+	if err != nil {
+		return nil, source, err
+	}
+	if result, ok := processed.(*image.Processed); ok {
+		source.compression = &result.Compression
+	}
+	info, err := p.writeTemp(fmt.Sprintf("vision-%s-", input.Filename), processed)
+	return info, source, err
+}
+
+// imageContext returns the annotation context of an image, which is its
+// EXIF GPS location when location context is enabled
+func (p *VisionProcessor) imageContext(metadata *image.Metadata) *vision.ImageContext {
+	if !p.options.LocationContext || metadata == nil || metadata.EXIF == nil || metadata.EXIF.GPS == nil {
+		return nil
+	}
+
+	gps := metadata.EXIF.GPS
+	return &vision.ImageContext{
+		Location: &vision.LocationInfo{Latitude: gps.Latitude, Longitude: gps.Longitude},
+	}
+}
+
+// annotateOnce annotates an image and records the call for the dead-letter
+// output. The vision client already retries rate limiting, timeouts and
+// server errors, so failed calls are not repeated here.
+func (p *VisionProcessor) annotateOnce(ctx context.Context, input ProcessInput, fileInfo *utils.FileInfo, imageContext *vision.ImageContext) (*vision.AnnotateResponse, []Attempt, error) {
+	startTime := time.Now()
+	response, err := p.annotate(ctx, fileInfo, imageContext)
+	if err != nil {
+		err = utils.NewProcessError("annotate", input.Filename, err, "label detection failed")
+	}
+	attempt := newAttempt(1, startTime, err)
+	if response != nil {
+		attempt.Retries = response.Metadata.RetryCount
+	}
+	if err != nil {
+		return nil, []Attempt{attempt}, err
+	}
+
+	return response, []Attempt{attempt}, nil
+}
+
+// annotate runs the configured vision features against an image. API
+// failures are mapped to the error class of their status code.
+func (p *VisionProcessor) annotate(ctx context.Context, fileInfo *utils.FileInfo, imageContext *vision.ImageContext) (*vision.AnnotateResponse, error) {
+	response, err := p.options.VisionClient.Annotate(ctx, vision.AnnotateRequest{
+		ImagePath: fileInfo.Path,
+		Context:   imageContext,
+	})
+	if err != nil {
+		return response, fmt.Errorf("vision API error: %w", apiError(err))
 	}
 
-	// Get file info
-	return utils.GetFileInfo(tempFile.Name())
+	return response, nil
+}
+
+// apiError tags an API failure with the sentinel error of its status code
+func apiError(err error) error {
+	var status *vision.StatusError
+	if !errors.As(err, &status) {
+		return err
+	}
+
+	switch {
+	case status.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %w", utils.ErrRateLimitExceeded, err)
+	case status.StatusCode == http.StatusRequestTimeout, status.StatusCode == http.StatusGatewayTimeout:
+		return fmt.Errorf("%w: %w", utils.ErrTimeout, err)
+	case status.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %w", utils.ErrUnavailable, err)
+	case status.StatusCode == http.StatusBadRequest,
+		status.StatusCode == http.StatusNotFound,
+		status.StatusCode == http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %w", utils.ErrRejected, err)
+	default:
+		return err
+	}
 }
 
-// detectLabels detects labels in an image
-func (p *VisionProcessor) detectLabels(ctx context.Context, fileInfo *utils.FileInfo) ([]Label, error) {
-	labels, err := p.options.VisionClient.DetectLabels(ctx, fileInfo.Path)
+// newAttempt records an attempt that started at startTime and just finished
+func newAttempt(number int, startTime time.Time, err error) Attempt {
+	attempt := Attempt{
+		Number:     number,
+		StartedAt:  startTime,
+		FinishedAt: time.Now(),
+	}
 	if err != nil {
-		return nil, fmt.Errorf("vision API error: %w", err)
+		attempt.Error = err.Error()
+		attempt.ErrorClass = string(utils.ClassifyError(err))
+	}
+	return attempt
+}
+
+// convertLabels converts vision API labels to processor labels
+func convertLabels(labels []vision.Label) []Label {
+	converted := make([]Label, len(labels))
+	for i, label := range labels {
+		converted[i] = Label{
+			Description: label.Description,
+			Score:       label.Score,
+		}
+	}
+	return converted
+}
+
+// convertObjects converts vision API object annotations to processor
+// objects. The API saw the upright image, so vertices are mapped back to
+// the stored pixels of a source with the given EXIF orientation.
+func convertObjects(objects []vision.ObjectAnnotation, orientation int) []ObjectAnnotation {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	converted := make([]ObjectAnnotation, len(objects))
+	for i, object := range objects {
+		vertices := make([]Vertex, len(object.BoundingBox.NormalizedVertices))
+		for j, v := range object.BoundingBox.NormalizedVertices {
+			x, y := image.SourcePoint(orientation, v.X, v.Y)
+			vertices[j] = Vertex{X: x, Y: y}
+		}
+		converted[i] = ObjectAnnotation{
+			Name:     object.Name,
+			Score:    object.Score,
+			Vertices: vertices,
+		}
 	}
+	return converted
+}
 
-	return labels, nil
+// sourceOrientation returns the EXIF orientation of a source image
+func sourceOrientation(metadata *image.Metadata) int {
+	if metadata == nil || metadata.EXIF == nil || metadata.EXIF.Orientation == 0 {
+		return image.OrientationNormal
+	}
+	return metadata.EXIF.Orientation
 }
 
 // saveResults saves processing results
-func (p *VisionProcessor) saveResults(output ProcessOutput) error {
+func (p *VisionProcessor) saveResults(ctx context.Context, output ProcessOutput) (err error) {
 	outputPath := filepath.Join(p.options.OutputDir, output.Filename+".json")
+
+	_, span := tracer.Start(ctx, "save_results", trace.WithAttributes(attribute.String("output.path", outputPath)))
+	defer func() { endSpan(span, err) }()
+
 	return utils.SaveJSON(outputPath, output)
 }
 
 // validateInput validates the process input
 func (p *VisionProcessor) validateInput(input ProcessInput) error {
-	if input.Reader == nil {
-		return fmt.Errorf("input reader is required")
+	if input.Reader == nil && inputPath(input) == "" {
+		return fmt.Errorf("%w: input reader or path is required", utils.ErrInvalidInput)
 	}
 
+	return p.checkInput(input)
+}
+
+// checkInput applies the filename, format and size filters to an input
+func (p *VisionProcessor) checkInput(input ProcessInput) error {
 	if input.Filename == "" {
-		return fmt.Errorf("filename is required")
+		return fmt.Errorf("%w: filename is required", utils.ErrInvalidInput)
+	}
+
+	format := image.FormatFromExtension(input.Filename)
+	if format == "" {
+		return fmt.Errorf("%w: filename must have an extension", utils.ErrInvalidInput)
+	}
+	if !p.allowsFormat(format) {
+		return fmt.Errorf("%w: %s", utils.ErrUnsupportedFormat, format)
 	}
 
-	ext := filepath.Ext(input.Filename)
-	if ext == "" {
-		return fmt.Errorf("filename must have an extension")
+	if size := inputSize(input); size > p.options.MaxFileSize {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", utils.ErrImageTooLarge, size, p.options.MaxFileSize)
 	}
 
-	format := ext[1:] // Remove dot
-	valid := false
-	for _, allowedFormat := range p.options.AllowedFormats {
-		if format == allowedFormat {
-			valid = true
-			break
+	return nil
+}
+
+// inputSize returns the size of an input in bytes, or -1 if it can't be
+// determined without reading it
+func inputSize(input ProcessInput) int64 {
+	if path := inputPath(input); path != "" {
+		if stat, err := os.Stat(path); err == nil {
+			return stat.Size()
 		}
+		return -1
 	}
-	if !valid {
-		return fmt.Errorf("unsupported format: %s", format)
+
+	if seeker, ok := input.Reader.(io.Seeker); ok {
+		current, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		seeker.Seek(current, io.SeekStart)
+		if err != nil {
+			return -1
+		}
+		return end - current
 	}
 
-	return nil
+	return -1
+}
+
+// openInput returns the image data of an input, opening its source path
+// or page when it has no reader
+func openInput(input ProcessInput) (io.ReadCloser, error) {
+	if input.Reader != nil {
+		return io.NopCloser(input.Reader), nil
+	}
+	if page, ok := inputPage(input); ok {
+		return image.OpenPage(inputPath(input), page)
+	}
+
+	file, err := os.Open(inputPath(input))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input: %w", err)
+	}
+	return file, nil
 }
 
-// recordMetrics records processing metrics
-func (p *VisionProcessor) recordMetrics(duration time.Duration, success bool) {
-	// Implement metrics recording if needed
+// recordMetrics records processing metrics. Skipped images, e.g. those
+// that failed quality screening, are counted apart from failures.
+func (p *VisionProcessor) recordMetrics(duration time.Duration, err error) {
+	if p.options.Metrics == nil {
+		return
+	}
+	status := "success"
+	switch {
+	case errors.Is(err, utils.ErrSkipped):
+		status = "skipped"
+	case err != nil:
+		status = "failed"
+	}
+	p.options.Metrics.ObserveStage("total", duration)
+	p.options.Metrics.ObserveImage(status)
+}
+
+// observeStage records the time spent in a pipeline stage since startTime
+func (p *VisionProcessor) observeStage(stage string, startTime time.Time) {
+	if p.options.Metrics != nil {
+		p.options.Metrics.ObserveStage(stage, time.Since(startTime))
+	}
+}
+
+// observeCacheLookup records whether an image of a batch was served from
+// the result of its duplicate
+func (p *VisionProcessor) observeCacheLookup(hit bool) {
+	if p.options.Metrics != nil {
+		p.options.Metrics.ObserveCacheLookup(hit)
+	}
+}
+
+// observeDuplicate records an image served from the result of its duplicate
+func (p *VisionProcessor) observeDuplicate() {
+	if p.options.Metrics != nil {
+		p.options.Metrics.ObserveDuplicate()
+	}
 }
 
 // SetProgressTracker sets the progress tracking mechanism
@@ -247,6 +768,48 @@ func (p *VisionProcessor) SetProgressTracker(tracker ProgressTracker) {
 	p.tracker = tracker
 }
 
+// AddHandler adds a processing handler to the pipeline. Handlers run in
+// the order they were added on the prepared image bytes.
+func (p *VisionProcessor) AddHandler(handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers = append(p.handlers, handler)
+}
+
+// runHandlers passes the prepared image through the registered handlers
+// and writes the result back to the prepared file
+func (p *VisionProcessor) runHandlers(ctx context.Context, prepared *utils.FileInfo) (*utils.FileInfo, error) {
+	p.mu.RLock()
+	handlers := append([]Handler(nil), p.handlers...)
+	p.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		return prepared, nil
+	}
+
+	data, err := os.ReadFile(prepared.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, handler := range handlers {
+		handlerCtx, span := tracer.Start(ctx, "handler "+handler.GetName(), trace.WithAttributes(
+			attribute.String("handler.name", handler.GetName()),
+			attribute.Int("image.bytes", len(data)),
+		))
+		data, err = handler.Handle(handlerCtx, data)
+		endSpan(span, err)
+		if err != nil {
+			return nil, fmt.Errorf("handler %s: %w", handler.GetName(), err)
+		}
+	}
+
+	if err := os.WriteFile(prepared.Path, data, 0644); err != nil {
+		return nil, err
+	}
+	return utils.GetFileInfo(prepared.Path)
+}
+
 // Cleanup performs cleanup operations
 func (p *VisionProcessor) Cleanup() error {
 	return p.tempManager.Cleanup()
@@ -17,6 +17,15 @@ type Status struct {
 	Failed    int64
 	Skipped   int64
 	StartTime time.Time
+	Stages    []StageStatus
+	Paused    bool
+}
+
+// StageStatus represents the load of a single pipeline stage
+type StageStatus struct {
+	Name   string
+	Queued int
+	Active int
 }
 
 // Tracker handles progress tracking and display
@@ -30,6 +39,8 @@ type Tracker struct {
 	mu        sync.Mutex
 	ticker    *time.Ticker
 	done      chan struct{}
+	stages    []StageStatus
+	paused    atomic.Bool
 }
 
 // NewTracker creates a new progress tracker
@@ -38,21 +49,22 @@ func NewTracker(total int64, writer io.Writer) *Tracker {
 		writer = os.Stdout
 	}
 
-	return &Tracker{
+	t := &Tracker{
 		startTime: time.Now(),
 		writer:    writer,
 		done:      make(chan struct{}),
 		ticker:    time.NewTicker(200 * time.Millisecond),
 	}
+	t.total.Store(total)
+	return t
 }
 
 // Start begins progress tracking
 func (t *Tracker) Start() {
-	t.total.Store(t.total.Load())
 	go t.updateDisplay()
 }
 
-// Update updates the current progress
+// Update records how many inputs succeeded, failed and were skipped
 func (t *Tracker) Update(current, failed, skipped int64) {
 	t.current.Store(current)
 	t.failed.Store(failed)
@@ -74,6 +86,26 @@ func (t *Tracker) IncrementSkipped() {
 	t.skipped.Add(1)
 }
 
+// UpdateStage records the queue depth and active workers of a pipeline stage
+func (t *Tracker) UpdateStage(stage string, queued, active int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.stages {
+		if t.stages[i].Name == stage {
+			t.stages[i].Queued = queued
+			t.stages[i].Active = active
+			return
+		}
+	}
+	t.stages = append(t.stages, StageStatus{Name: stage, Queued: queued, Active: active})
+}
+
+// SetPaused records whether processing is paused
+func (t *Tracker) SetPaused(paused bool) {
+	t.paused.Store(paused)
+}
+
 // Finish stops progress tracking
 func (t *Tracker) Finish() {
 	t.ticker.Stop()
@@ -118,9 +150,24 @@ func (t *Tracker) displayProgress() {
 		strings.Repeat("=", completed),
 		strings.Repeat(" ", width-completed))
 
+	state := ""
+	if t.paused.Load() {
+		state = " | PAUSED"
+	}
+
 	// Clear line and print progress
-	fmt.Fprintf(t.writer, "\r\033[K%s %.1f%% | %d/%d | Failed: %d | Skipped: %d | %.1f/s",
-		bar, percentage, current, total, failed, skipped, speed)
+	fmt.Fprintf(t.writer, "\r\033[K%s %.1f%% | %d/%d | Failed: %d | Skipped: %d | %.1f/s%s%s",
+		bar, percentage, current, total, failed, skipped, speed, t.formatStages(), state)
+}
+
+// formatStages formats stage queue depths as " | prepare 3+2 | annotate 12+8"
+// where the first number is queued and the second active jobs
+func (t *Tracker) formatStages() string {
+	var b strings.Builder
+	for _, stage := range t.stages {
+		fmt.Fprintf(&b, " | %s %d+%d", stage.Name, stage.Queued, stage.Active)
+	}
+	return b.String()
 }
 
 func (t *Tracker) displayFinalStatus() {
@@ -152,11 +199,17 @@ func (t *Tracker) displayFinalStatus() {
 
 // GetStatus returns the current status
 func (t *Tracker) GetStatus() Status {
+	t.mu.Lock()
+	stages := append([]StageStatus(nil), t.stages...)
+	t.mu.Unlock()
+
 	return Status{
 		Current:   t.current.Load(),
 		Total:     t.total.Load(),
 		Failed:    t.failed.Load(),
 		Skipped:   t.skipped.Load(),
 		StartTime: t.startTime,
+		Stages:    stages,
+		Paused:    t.paused.Load(),
 	}
 }
\ No newline at end of file
@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Options contains configuration for trace export
+type Options struct {
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string
+
+	// OTLPEndpoint is the host:port of an OTLP/HTTP collector; empty disables OTLP export
+	OTLPEndpoint string
+
+	// Insecure disables TLS for the OTLP exporter
+	Insecure bool
+
+	// FilePath is a file spans are appended to as JSON lines; empty disables it
+	FilePath string
+
+	// SampleRatio is the fraction of traces that are recorded
+	SampleRatio float64
+}
+
+// OptionFunc is a function that configures Options
+type OptionFunc func(*Options)
+
+// defaultOptions returns the default tracing options
+func defaultOptions() *Options {
+	return &Options{
+		ServiceName: "vision-processor",
+		SampleRatio: 1,
+	}
+}
+
+// WithServiceName sets the reported service name
+func WithServiceName(name string) OptionFunc {
+	return func(o *Options) {
+		if name != "" {
+			o.ServiceName = name
+		}
+	}
+}
+
+// WithOTLPEndpoint sets the OTLP/HTTP collector endpoint
+func WithOTLPEndpoint(endpoint string, insecure bool) OptionFunc {
+	return func(o *Options) {
+		o.OTLPEndpoint = endpoint
+		o.Insecure = insecure
+	}
+}
+
+// WithFile sets the file spans are written to for offline analysis
+func WithFile(path string) OptionFunc {
+	return func(o *Options) {
+		o.FilePath = path
+	}
+}
+
+// WithSampleRatio sets the fraction of traces that are recorded
+func WithSampleRatio(ratio float64) OptionFunc {
+	return func(o *Options) {
+		if ratio >= 0 && ratio <= 1 {
+			o.SampleRatio = ratio
+		}
+	}
+}
+
+// Setup installs a global tracer provider exporting to the configured
+// destinations and the W3C trace-context propagator. The returned function
+// flushes pending spans and releases the exporters.
+func Setup(ctx context.Context, opts ...OptionFunc) (func(context.Context) error, error) {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.OTLPEndpoint == "" && options.FilePath == "" {
+		return nil, fmt.Errorf("no trace exporter configured")
+	}
+
+	var closers []func(context.Context) error
+	shutdown := func(ctx context.Context) error {
+		var errs []error
+		for i := len(closers) - 1; i >= 0; i-- {
+			errs = append(errs, closers[i](ctx))
+		}
+		return errors.Join(errs...)
+	}
+
+	providerOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", options.ServiceName),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+	}
+
+	if options.OTLPEndpoint != "" {
+		clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(options.OTLPEndpoint)}
+		if options.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exporter))
+	}
+
+	if options.FilePath != "" {
+		file, err := os.OpenFile(options.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		closers = append(closers, func(context.Context) error { return file.Close() })
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			shutdown(ctx)
+			return nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(providerOpts...)
+	closers = append(closers, provider.Shutdown)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return shutdown, nil
+}
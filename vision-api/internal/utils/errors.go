@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"fmt"
 )
@@ -23,6 +24,55 @@ var (
 
 	// ErrTimeout indicates an operation timed out
 	ErrTimeout = errors.New("operation timed out")
+
+	// ErrUnavailable indicates the API failed with a server error
+	ErrUnavailable = errors.New("service unavailable")
+
+	// ErrRejected indicates the API rejected an image as invalid, so
+	// resubmitting it unchanged can't succeed
+	ErrRejected = errors.New("rejected by the API")
+
+	// ErrNotProcessed indicates an input was never started, e.g. because
+	// the batch was canceled first
+	ErrNotProcessed = errors.New("input not processed")
+
+	// ErrBudgetExceeded indicates a spend cap stopped new work
+	ErrBudgetExceeded = errors.New("budget exceeded")
+
+	// ErrSkipped indicates an image was deliberately not annotated, e.g.
+	// because it failed quality screening
+	ErrSkipped = errors.New("image skipped")
+
+	// ErrDecodeLimit indicates an image would take too many pixels, frames
+	// or too much time to decode, e.g. a decompression bomb
+	ErrDecodeLimit = errors.New("image exceeds decode limits")
+
+	// ErrMalformedImage indicates an image crashed its codec or has a
+	// corrupt structure
+	ErrMalformedImage = errors.New("malformed image")
+)
+
+// ErrorClass is a coarse category of processing failure used for reporting
+type ErrorClass string
+
+const (
+	ClassInvalidInput      ErrorClass = "invalid_input"
+	ClassUnsupportedFormat ErrorClass = "unsupported_format"
+	ClassImageTooLarge     ErrorClass = "image_too_large"
+	ClassRateLimited       ErrorClass = "rate_limited"
+	ClassTimeout           ErrorClass = "timeout"
+	ClassUnavailable       ErrorClass = "unavailable"
+	ClassCanceled          ErrorClass = "canceled"
+	ClassPending           ErrorClass = "pending"
+	ClassBudgetExceeded    ErrorClass = "budget_exceeded"
+	ClassSkipped           ErrorClass = "skipped"
+	ClassDecodeLimit       ErrorClass = "decode_limit_exceeded"
+	ClassMalformedImage    ErrorClass = "malformed_image"
+	ClassPreparation       ErrorClass = "preparation_failed"
+	ClassAnnotation        ErrorClass = "annotation_failed"
+	ClassRejected          ErrorClass = "annotation_rejected"
+	ClassStorage           ErrorClass = "storage_failed"
+	ClassUnknown           ErrorClass = "unknown"
 )
 
 // ProcessError represents a detailed processing error
@@ -71,6 +121,71 @@ func IsInvalidInput(err error) bool {
 	return errors.Is(err, ErrInvalidInput)
 }
 
+// ClassifyError maps an error to its error class. Sentinel errors take
+// precedence over the operation recorded in a ProcessError.
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrBudgetExceeded):
+		return ClassBudgetExceeded
+	case errors.Is(err, ErrNotProcessed):
+		return ClassPending
+	case errors.Is(err, ErrSkipped):
+		return ClassSkipped
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case errors.Is(err, context.DeadlineExceeded), IsTimeout(err):
+		return ClassTimeout
+	case IsRateLimitError(err):
+		return ClassRateLimited
+	case errors.Is(err, ErrUnavailable):
+		return ClassUnavailable
+	case errors.Is(err, ErrRejected):
+		return ClassRejected
+	case errors.Is(err, ErrUnsupportedFormat):
+		return ClassUnsupportedFormat
+	case errors.Is(err, ErrImageTooLarge):
+		return ClassImageTooLarge
+	case errors.Is(err, ErrDecodeLimit):
+		return ClassDecodeLimit
+	case errors.Is(err, ErrMalformedImage):
+		return ClassMalformedImage
+	case IsInvalidInput(err):
+		return ClassInvalidInput
+	}
+
+	var processErr *ProcessError
+	if errors.As(err, &processErr) {
+		switch processErr.Op {
+		case "prepare":
+			return ClassPreparation
+		case "annotate":
+			return ClassAnnotation
+		case "save":
+			return ClassStorage
+		}
+	}
+
+	return ClassUnknown
+}
+
+// IsRetryable reports whether an error class may succeed on a later attempt
+func (c ErrorClass) IsRetryable() bool {
+	switch c {
+	case ClassRateLimited, ClassTimeout, ClassUnavailable, ClassStorage:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsPermanent reports whether an error class can't be fixed by
+// resubmitting the same image
+func (c ErrorClass) IsPermanent() bool {
+	return c == ClassRejected
+}
+
 // WrapError wraps an error with additional context
 func WrapError(err error, message string) error {
 	return fmt.Errorf("%s: %w", message, err)
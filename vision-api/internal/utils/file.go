@@ -3,8 +3,10 @@ package utils
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -88,14 +90,20 @@ func GetFileInfo(path string) (*FileInfo, error) {
 		return nil, fmt.Errorf("failed to get file stats: %w", err)
 	}
 
-	// Calculate file hash
+	// Calculate file hash, keeping the start of the file to sniff its type
 	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
+	header := &limitedBuffer{limit: 512}
+	if _, err := io.Copy(io.MultiWriter(hash, header), file); err != nil {
 		return nil, fmt.Errorf("failed to calculate file hash: %w", err)
 	}
 
+	// The content decides the MIME type; temp files and renamed images
+	// have misleading extensions
 	ext := strings.ToLower(filepath.Ext(path))
-	mimeType := getMimeType(ext)
+	mimeType := http.DetectContentType(header.data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = getMimeType(ext)
+	}
 
 	return &FileInfo{
 		Path:      path,
@@ -154,6 +162,54 @@ func CopyFile(src, dst string) error {
 	return nil
 }
 
+// SaveJSON writes v as indented JSON, replacing the file atomically
+func SaveJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadJSON reads a JSON file into v
+func LoadJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	return nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it
+type limitedBuffer struct {
+	data  []byte
+	limit int
+}
+
+// Write implements io.Writer
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.data); room > 0 {
+		b.data = append(b.data, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
 // getMimeType returns the MIME type for common image extensions
 func getMimeType(ext string) string {
 	switch ext {
@@ -167,6 +223,8 @@ func getMimeType(ext string) string {
 		return "image/bmp"
 	case ".webp":
 		return "image/webp"
+	case ".tif", ".tiff":
+		return "image/tiff"
 	default:
 		return "application/octet-stream"
 	}
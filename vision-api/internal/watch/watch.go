@@ -0,0 +1,267 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"vision_api/internal/logging"
+)
+
+// Options contains configuration for the directory watcher
+type Options struct {
+	// Debounce is how long a file must go without writes, and keep the same
+	// size, before it is reported
+	Debounce time.Duration
+
+	// Match selects the files to report; nil reports every file
+	Match func(path string) bool
+
+	// Logger receives watcher logs
+	Logger *slog.Logger
+}
+
+// OptionFunc is a function that configures Options
+type OptionFunc func(*Options)
+
+// defaultOptions returns the default watcher options
+func defaultOptions() *Options {
+	return &Options{
+		Debounce: 2 * time.Second,
+		Logger:   slog.Default(),
+	}
+}
+
+// WithDebounce sets how long a file must be quiet before it is reported
+func WithDebounce(d time.Duration) OptionFunc {
+	return func(o *Options) {
+		if d > 0 {
+			o.Debounce = d
+		}
+	}
+}
+
+// WithMatch sets the filter for reported files
+func WithMatch(match func(path string) bool) OptionFunc {
+	return func(o *Options) {
+		o.Match = match
+	}
+}
+
+// WithLogger sets the logger for watcher logs
+func WithLogger(logger *slog.Logger) OptionFunc {
+	return func(o *Options) {
+		if logger != nil {
+			o.Logger = logger
+		}
+	}
+}
+
+// Watcher reports files created or rewritten below a directory tree once
+// they are completely written. Directories created while watching are
+// watched too.
+type Watcher struct {
+	options *Options
+	fs      *fsnotify.Watcher
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	pending map[string]*pendingFile
+	ready   chan string
+	done    chan struct{}
+}
+
+// pendingFile is a file waiting for its writes to settle
+type pendingFile struct {
+	timer *time.Timer
+	size  int64
+}
+
+// New creates a watcher for the tree below root
+func New(root string, opts ...OptionFunc) (*Watcher, error) {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	w := &Watcher{
+		options: options,
+		fs:      fs,
+		logger:  options.Logger.With(logging.ComponentKey, "watch"),
+		pending: make(map[string]*pendingFile),
+		ready:   make(chan string, 64),
+		done:    make(chan struct{}),
+	}
+
+	if err := w.addTree(root, false); err != nil {
+		fs.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Ready returns the channel of completely written files
+func (w *Watcher) Ready() <-chan string {
+	return w.ready
+}
+
+// Run handles file events until ctx is done
+func (w *Watcher) Run(ctx context.Context) error {
+	defer w.stopTimers()
+	defer close(w.done)
+	defer w.fs.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return nil
+			}
+			w.handle(event)
+
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return nil
+			}
+			w.logger.Warn("watch error", "error", err)
+		}
+	}
+}
+
+// handle reacts to a single file system event
+func (w *Watcher) handle(event fsnotify.Event) {
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			// Files may land in a new directory before it is watched
+			if err := w.addTree(event.Name, true); err != nil {
+				w.logger.Warn("failed to watch directory", "path", event.Name, "error", err)
+			}
+			return
+		}
+		w.schedule(event.Name)
+
+	case event.Has(fsnotify.Write):
+		w.schedule(event.Name)
+
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		w.cancel(event.Name)
+	}
+}
+
+// addTree watches dir and every directory below it. With schedule set the
+// files already in the tree are reported too.
+func (w *Watcher) addTree(dir string, schedule bool) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := w.fs.Add(path); err != nil {
+				return fmt.Errorf("failed to watch %s: %w", path, err)
+			}
+			return nil
+		}
+		if schedule {
+			w.schedule(path)
+		}
+		return nil
+	})
+}
+
+// schedule (re)starts the quiet period of a file
+func (w *Watcher) schedule(path string) {
+	if w.options.Match != nil && !w.options.Match(path) {
+		return
+	}
+
+	size := int64(-1)
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if file, ok := w.pending[path]; ok {
+		file.size = size
+		file.timer.Reset(w.options.Debounce)
+		return
+	}
+
+	file := &pendingFile{size: size}
+	file.timer = time.AfterFunc(w.options.Debounce, func() { w.settle(path) })
+	w.pending[path] = file
+}
+
+// settle reports a file once its size stayed the same for a quiet period.
+// Writers that don't emit events while copying are caught by the size check.
+func (w *Watcher) settle(path string) {
+	info, err := os.Stat(path)
+
+	w.mu.Lock()
+	file, ok := w.pending[path]
+	if !ok {
+		w.mu.Unlock()
+		return
+	}
+	if err != nil {
+		delete(w.pending, path)
+		w.mu.Unlock()
+		return
+	}
+	if info.Size() != file.size {
+		file.size = info.Size()
+		file.timer.Reset(w.options.Debounce)
+		w.mu.Unlock()
+		return
+	}
+	delete(w.pending, path)
+	w.mu.Unlock()
+
+	w.logger.Debug("file ready", "path", path, "bytes", info.Size())
+	select {
+	case w.ready <- path:
+	case <-w.done:
+	}
+}
+
+// cancel forgets a file that was removed or renamed before it settled
+func (w *Watcher) cancel(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if file, ok := w.pending[path]; ok {
+		file.timer.Stop()
+		delete(w.pending, path)
+	}
+}
+
+// stopTimers stops the quiet periods still running
+func (w *Watcher) stopTimers() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for path, file := range w.pending {
+		file.timer.Stop()
+		delete(w.pending, path)
+	}
+}
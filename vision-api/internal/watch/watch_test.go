@@ -0,0 +1,120 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const debounce = 100 * time.Millisecond
+
+// startWatcher runs a watcher on dir until the test ends
+func startWatcher(t *testing.T, dir string, opts ...OptionFunc) *Watcher {
+	t.Helper()
+	w, err := New(dir, append([]OptionFunc{WithDebounce(debounce)}, opts...)...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return w
+}
+
+// nextReady waits for the next reported file, or returns "" after wait
+func nextReady(w *Watcher, wait time.Duration) string {
+	select {
+	case path := <-w.Ready():
+		return path
+	case <-time.After(wait):
+		return ""
+	}
+}
+
+func TestDebouncesWrites(t *testing.T) {
+	dir := t.TempDir()
+	w := startWatcher(t, dir)
+
+	path := filepath.Join(dir, "photo.jpg")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	// Keep writing for longer than the quiet period in total
+	var lastWrite time.Time
+	for i := 0; i < 5; i++ {
+		if i > 0 {
+			time.Sleep(debounce / 2)
+		}
+		if _, err := file.WriteString("chunk"); err != nil {
+			t.Fatal(err)
+		}
+		lastWrite = time.Now()
+	}
+
+	got := nextReady(w, 2*time.Second)
+	if got != path {
+		t.Fatalf("ready = %q, want %q", got, path)
+	}
+	if time.Since(lastWrite) < debounce {
+		t.Errorf("reported %v after the last write, before the quiet period of %v", time.Since(lastWrite), debounce)
+	}
+	if extra := nextReady(w, 3*debounce); extra != "" {
+		t.Errorf("file reported twice: %q", extra)
+	}
+}
+
+func TestNewDirectoriesAndFilters(t *testing.T) {
+	dir := t.TempDir()
+	w := startWatcher(t, dir, WithMatch(func(path string) bool {
+		return strings.HasSuffix(path, ".jpg")
+	}))
+
+	// A directory moved in with files already inside is scanned
+	staging := t.TempDir()
+	if err := os.WriteFile(filepath.Join(staging, "a.jpg"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "album")
+	if err := os.Rename(staging, sub); err != nil {
+		t.Skipf("cannot move directories between temp dirs: %v", err)
+	}
+
+	if got, want := nextReady(w, 2*time.Second), filepath.Join(sub, "a.jpg"); got != want {
+		t.Fatalf("ready = %q, want %q", got, want)
+	}
+
+	// Files in the new directory are watched, and removed files dropped
+	gone := filepath.Join(sub, "gone.jpg")
+	if err := os.WriteFile(gone, []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(sub, "b.jpg")
+	if err := os.WriteFile(kept, []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := nextReady(w, 2*time.Second); got != kept {
+		t.Errorf("ready = %q, want %q", got, kept)
+	}
+	if extra := nextReady(w, 3*debounce); extra != "" {
+		t.Errorf("unexpected file reported: %q", extra)
+	}
+}
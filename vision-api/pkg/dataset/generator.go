@@ -5,28 +5,80 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
+
+	"vision_api/internal/logging"
 )
 
 // Generator handles dataset generation from processing results
 type Generator struct {
 	options *Options
+	logger  *slog.Logger
 	mu      sync.RWMutex
 }
 
 // Record represents a single dataset record
 type Record struct {
-	ID           string                 `json:"id"`
-	ImagePath    string                 `json:"image_path"`
-	Labels       []string               `json:"labels"`
-	Confidence   float64                `json:"confidence"`
-	ProcessedAt  time.Time              `json:"processed_at"`
-	Status       string                 `json:"status"`
-	Metadata     map[string]interface{} `json:"metadata,omitempty"`
-	ErrorMessage string                 `json:"error_message,omitempty"`
+	ID            string                 `json:"id"`
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	ImagePath     string                 `json:"image_path"`
+	Page          int                    `json:"page,omitempty"` // page of a multi-page file, starting at 1
+	Labels        []string               `json:"labels"`
+	Confidence    float64                `json:"confidence"`
+	ProcessedAt   time.Time              `json:"processed_at"`
+	Status        string                 `json:"status"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	ErrorMessage  string                 `json:"error_message,omitempty"`
+	ClusterID     string                 `json:"cluster_id,omitempty"`
+	DuplicateOf   string                 `json:"duplicate_of,omitempty"`
+
+	// Camera and capture details from the image's EXIF data, if any
+	CapturedAt   *time.Time `json:"captured_at,omitempty"`
+	CameraMake   string     `json:"camera_make,omitempty"`
+	CameraModel  string     `json:"camera_model,omitempty"`
+	ExposureTime float64    `json:"exposure_time,omitempty"`
+	FNumber      float64    `json:"f_number,omitempty"`
+	ISO          int        `json:"iso,omitempty"`
+	FocalLength  float64    `json:"focal_length,omitempty"`
+	Orientation  int        `json:"orientation,omitempty"`
+	Latitude     *float64   `json:"latitude,omitempty"`
+	Longitude    *float64   `json:"longitude,omitempty"`
+
+	// FrameCount and FrameLabels describe the sampled frames of animated
+	// images, whose Labels are the union of the frame labels
+	FrameCount  int           `json:"frame_count,omitempty"`
+	FrameLabels []FrameLabels `json:"frame_labels,omitempty"`
+
+	// SkipReason is the quality issue of images skipped by screening, and
+	// Quality their screening scores
+	SkipReason string   `json:"skip_reason,omitempty"`
+	Quality    *Quality `json:"quality,omitempty"`
+
+	// Warning reports problems that didn't stop processing, such as an
+	// extension that doesn't match the image content
+	Warning string `json:"warning,omitempty"`
+}
+
+// Quality holds the quality screening scores of an image. Luminance is on
+// a 0-255 scale.
+type Quality struct {
+	Sharpness       float64 `json:"sharpness"`
+	MeanLuminance   float64 `json:"mean_luminance"`
+	DarkLuminance   float64 `json:"dark_luminance"`
+	BrightLuminance float64 `json:"bright_luminance"`
+	Entropy         float64 `json:"entropy"`
+	SolidColor      bool    `json:"solid_color"`
+}
+
+// FrameLabels are the labels of one sampled frame of an animation
+type FrameLabels struct {
+	Frame  int      `json:"frame"`
+	Labels []string `json:"labels"`
 }
 
 // Stats contains dataset generation statistics
@@ -54,6 +106,7 @@ func NewGenerator(opts ...OptionFunc) (*Generator, error) {
 
 	return &Generator{
 		options: options,
+		logger:  options.Logger.With(logging.ComponentKey, "dataset"),
 	}, nil
 }
 
@@ -63,21 +116,65 @@ func (g *Generator) GenerateDataset(ctx context.Context, records []Record) error
 		return err
 	}
 
+	var err error
 	switch g.options.Format {
 	case FormatJSON:
-		return g.generateJSON(ctx, records)
+		err = g.generateJSON(ctx, records)
 	case FormatCSV:
-		return g.generateCSV(ctx, records)
+		err = g.generateCSV(ctx, records)
 	case FormatJSONL:
-		return g.generateJSONL(ctx, records)
+		err = g.generateJSONL(ctx, records)
 	default:
 		return fmt.Errorf("unsupported format: %s", g.options.Format)
 	}
+	if err != nil {
+		return err
+	}
+
+	g.logger.InfoContext(ctx, "dataset written",
+		"path", g.outputPath(string(g.options.Format)),
+		"records", len(records),
+	)
+	return nil
+}
+
+// AppendRecords appends records to the dataset file, creating it if needed.
+// Only JSONL supports appending, since every record is a line of its own.
+func (g *Generator) AppendRecords(ctx context.Context, records []Record) error {
+	if g.options.Format != FormatJSONL {
+		return fmt.Errorf("appending is not supported for format: %s", g.options.Format)
+	}
+	if err := g.validateOutputDir(); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	outputPath := g.outputPath("jsonl")
+	file, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+	}
+
+	g.logger.DebugContext(ctx, "records appended", "path", outputPath, "records", len(records))
+	return nil
 }
 
 // generateJSON generates a JSON dataset file
 func (g *Generator) generateJSON(ctx context.Context, records []Record) error {
-	outputPath := filepath.Join(g.options.OutputDir, "dataset.json")
+	outputPath := g.outputPath("json")
 	file, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
@@ -106,7 +203,7 @@ func (g *Generator) generateJSON(ctx context.Context, records []Record) error {
 
 // generateCSV generates a CSV dataset file
 func (g *Generator) generateCSV(ctx context.Context, records []Record) error {
-	outputPath := filepath.Join(g.options.OutputDir, "dataset.csv")
+	outputPath := g.outputPath("csv")
 	file, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
@@ -117,7 +214,10 @@ func (g *Generator) generateCSV(ctx context.Context, records []Record) error {
 	defer writer.Flush()
 
 	// Write header
-	header := []string{"id", "image_path", "labels", "confidence", "processed_at", "status", "error_message"}
+	header := []string{"id", "image_path", "page", "labels", "confidence", "processed_at", "status", "error_message", "correlation_id",
+		"captured_at", "camera_make", "camera_model", "exposure_time", "f_number", "iso", "focal_length", "orientation", "latitude", "longitude",
+		"frame_count", "frame_labels", "skip_reason",
+		"sharpness", "mean_luminance", "dark_luminance", "bright_luminance", "entropy", "solid_color", "warning"}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
@@ -128,16 +228,41 @@ func (g *Generator) generateCSV(ctx context.Context, records []Record) error {
 		if err != nil {
 			return fmt.Errorf("failed to marshal labels: %w", err)
 		}
+		frameLabels := ""
+		if len(record.FrameLabels) > 0 {
+			data, err := json.Marshal(record.FrameLabels)
+			if err != nil {
+				return fmt.Errorf("failed to marshal frame labels: %w", err)
+			}
+			frameLabels = string(data)
+		}
 
 		row := []string{
 			record.ID,
 			record.ImagePath,
+			formatInt(record.Page),
 			string(labelsJSON),
 			fmt.Sprintf("%.4f", record.Confidence),
 			record.ProcessedAt.Format(time.RFC3339),
 			record.Status,
 			record.ErrorMessage,
+			record.CorrelationID,
+			formatTime(record.CapturedAt),
+			record.CameraMake,
+			record.CameraModel,
+			formatFloat(record.ExposureTime),
+			formatFloat(record.FNumber),
+			formatInt(record.ISO),
+			formatFloat(record.FocalLength),
+			formatInt(record.Orientation),
+			formatCoordinate(record.Latitude),
+			formatCoordinate(record.Longitude),
+			formatInt(record.FrameCount),
+			frameLabels,
+			record.SkipReason,
 		}
+		row = append(row, qualityColumns(record.Quality)...)
+		row = append(row, record.Warning)
 
 		if err := writer.Write(row); err != nil {
 			return fmt.Errorf("failed to write CSV record: %w", err)
@@ -147,9 +272,57 @@ func (g *Generator) generateCSV(ctx context.Context, records []Record) error {
 	return nil
 }
 
+// qualityColumns formats the quality scores of a record, or blanks if it
+// wasn't screened
+func qualityColumns(q *Quality) []string {
+	if q == nil {
+		return make([]string, 6)
+	}
+	return []string{
+		strconv.FormatFloat(q.Sharpness, 'f', 2, 64),
+		strconv.FormatFloat(q.MeanLuminance, 'f', 2, 64),
+		strconv.FormatFloat(q.DarkLuminance, 'f', 0, 64),
+		strconv.FormatFloat(q.BrightLuminance, 'f', 0, 64),
+		strconv.FormatFloat(q.Entropy, 'f', 3, 64),
+		strconv.FormatBool(q.SolidColor),
+	}
+}
+
+// formatTime formats an optional CSV timestamp
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// formatFloat formats an optional CSV number; zero is unset
+func formatFloat(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatInt formats an optional CSV integer; zero is unset
+func formatInt(v int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(v)
+}
+
+// formatCoordinate formats an optional CSV coordinate
+func formatCoordinate(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', 6, 64)
+}
+
 // generateJSONL generates a JSONL dataset file
 func (g *Generator) generateJSONL(ctx context.Context, records []Record) error {
-	outputPath := filepath.Join(g.options.OutputDir, "dataset.jsonl")
+	outputPath := g.outputPath("jsonl")
 	file, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
@@ -206,6 +379,11 @@ func (g *Generator) calculateStats(records []Record) Stats {
 	return stats
 }
 
+// outputPath returns the path of the dataset file with the given extension
+func (g *Generator) outputPath(ext string) string {
+	return filepath.Join(g.options.OutputDir, g.options.Name+"."+ext)
+}
+
 // validateOutputDir ensures the output directory exists and is writable
 func (g *Generator) validateOutputDir() error {
 	if g.options.OutputDir == "" {
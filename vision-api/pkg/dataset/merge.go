@@ -0,0 +1,105 @@
+package dataset
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReadRecords reads the records of a JSON or JSONL dataset file
+func ReadRecords(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+
+	switch strings.TrimPrefix(filepath.Ext(path), ".") {
+	case string(FormatJSON):
+		var dataset struct {
+			Records []Record `json:"records"`
+		}
+		if err := json.NewDecoder(file).Decode(&dataset); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		return dataset.Records, nil
+
+	case string(FormatJSONL):
+		var records []Record
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+			var record Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return nil, fmt.Errorf("failed to decode %s line %d: %w", path, line, err)
+			}
+			records = append(records, record)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return records, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported dataset file: %s", path)
+	}
+}
+
+// recordKey identifies the image, or the page of a multi-page file, that a
+// record belongs to
+type recordKey struct {
+	path string
+	page int
+}
+
+// Merge combines the records of dataset parts into one dataset ordered by
+// image path and page and returns its recomputed stats. Records of an image
+// that appears in several parts are kept once, preferring a successful one.
+func (g *Generator) Merge(ctx context.Context, parts []string) (Stats, error) {
+	byPath := make(map[recordKey]Record)
+	for _, part := range parts {
+		records, err := ReadRecords(part)
+		if err != nil {
+			return Stats{}, err
+		}
+		for _, record := range records {
+			key := recordKey{path: record.ImagePath, page: record.Page}
+			if key.path == "" {
+				key.path = record.ID
+			}
+			if existing, ok := byPath[key]; ok && existing.Status == string(StatusSuccess) {
+				continue
+			}
+			byPath[key] = record
+		}
+	}
+
+	keys := make([]recordKey, 0, len(byPath))
+	for key := range byPath {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		return keys[i].page < keys[j].page
+	})
+
+	records := make([]Record, len(keys))
+	for i, key := range keys {
+		records[i] = byPath[key]
+	}
+
+	if err := g.GenerateDataset(ctx, records); err != nil {
+		return Stats{}, err
+	}
+	return g.calculateStats(records), nil
+}
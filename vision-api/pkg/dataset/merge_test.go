@@ -0,0 +1,99 @@
+package dataset
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePart writes records as the JSONL dataset part name in dir
+func writePart(t *testing.T, dir, name string, records ...Record) string {
+	t.Helper()
+	g, err := NewGenerator(
+		WithOutputDir(dir),
+		WithFormat(FormatJSONL),
+		WithName(name),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.GenerateDataset(context.Background(), records); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, name+".jsonl")
+}
+
+func TestMergePrefersSuccess(t *testing.T) {
+	dir := t.TempDir()
+	success, failed := string(StatusSuccess), string(StatusFailed)
+
+	// b.jpg failed on one machine and succeeded on another; c.jpg was
+	// retried successfully in a later part
+	parts := []string{
+		writePart(t, dir, "dataset-part-0-of-2",
+			Record{ID: "1", ImagePath: "/in/b.jpg", Status: failed, ErrorMessage: "timeout"},
+			Record{ID: "2", ImagePath: "/in/a.jpg", Status: success, Labels: []string{"cat"}},
+			Record{ID: "3", ImagePath: "/in/c.jpg", Status: failed},
+		),
+		writePart(t, dir, "dataset-part-1-of-2",
+			Record{ID: "4", ImagePath: "/in/b.jpg", Status: success, Labels: []string{"dog", "cat"}},
+			Record{ID: "5", ImagePath: "/in/scan.tif", Page: 2, Status: success},
+			Record{ID: "6", ImagePath: "/in/scan.tif", Page: 1, Status: success},
+		),
+		writePart(t, dir, "dataset-retry",
+			Record{ID: "7", ImagePath: "/in/c.jpg", Status: success},
+			Record{ID: "8", ImagePath: "/in/a.jpg", Status: failed},
+		),
+	}
+
+	g, err := NewGenerator(
+		WithOutputDir(dir),
+		WithFormat(FormatJSONL),
+		WithName("dataset"),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := g.Merge(context.Background(), parts)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if stats.TotalRecords != 5 || stats.SuccessfulCount != 5 || stats.UniqueLabels != 2 {
+		t.Errorf("stats = %+v, want 5 successful records with 2 unique labels", stats)
+	}
+
+	merged, err := ReadRecords(filepath.Join(dir, "dataset.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadRecords() error = %v", err)
+	}
+	var ids []string
+	for _, record := range merged {
+		ids = append(ids, record.ID)
+	}
+	// Ordered by path and page; the successful record of each image wins
+	want := []string{"2", "4", "7", "6", "5"}
+	if len(ids) != len(want) {
+		t.Fatalf("merged IDs = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("merged IDs = %v, want %v", ids, want)
+			break
+		}
+	}
+}
+
+func TestReadRecordsRejectsCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.csv")
+	if err := os.WriteFile(path, []byte("id,image_path\n1,/in/a.jpg\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadRecords(path); err == nil {
+		t.Error("ReadRecords() accepted a CSV dataset")
+	}
+}
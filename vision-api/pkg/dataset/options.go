@@ -0,0 +1,95 @@
+package dataset
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Options contains configuration for the dataset generator
+type Options struct {
+	// OutputDir is the directory the dataset is written to
+	OutputDir string
+
+	// Format is the output format of the dataset
+	Format Format
+
+	// Name is the base name of the dataset file, without extension
+	Name string
+
+	// PrettyPrint enables indented JSON output
+	PrettyPrint bool
+
+	// Logger receives generator logs
+	Logger *slog.Logger
+}
+
+// OptionFunc is a function that configures Options
+type OptionFunc func(*Options)
+
+// defaultOptions returns the default generator options
+func defaultOptions() *Options {
+	return &Options{
+		Format: FormatJSONL,
+		Name:   "dataset",
+		Logger: slog.Default(),
+	}
+}
+
+// WithLogger sets the logger for generator logs
+func WithLogger(logger *slog.Logger) OptionFunc {
+	return func(o *Options) {
+		if logger != nil {
+			o.Logger = logger
+		}
+	}
+}
+
+// WithOutputDir sets the output directory
+func WithOutputDir(dir string) OptionFunc {
+	return func(o *Options) {
+		o.OutputDir = dir
+	}
+}
+
+// WithFormat sets the output format
+func WithFormat(format Format) OptionFunc {
+	return func(o *Options) {
+		o.Format = format
+	}
+}
+
+// WithName sets the base name of the dataset file
+func WithName(name string) OptionFunc {
+	return func(o *Options) {
+		if name != "" {
+			o.Name = name
+		}
+	}
+}
+
+// WithPrettyPrint enables indented JSON output
+func WithPrettyPrint(pretty bool) OptionFunc {
+	return func(o *Options) {
+		o.PrettyPrint = pretty
+	}
+}
+
+// validateOptions checks if the options are valid
+func validateOptions(o *Options) error {
+	if o.OutputDir == "" {
+		return fmt.Errorf("output directory is required")
+	}
+
+	switch o.Format {
+	case FormatJSON, FormatJSONL, FormatCSV:
+	default:
+		return fmt.Errorf("unsupported format: %s", o.Format)
+	}
+
+	if strings.ContainsAny(o.Name, `/\`) {
+		return fmt.Errorf("dataset name must not contain path separators: %s", o.Name)
+	}
+
+	return nil
+}
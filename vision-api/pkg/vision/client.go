@@ -3,17 +3,36 @@ package vision
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
 	"os/exec"
+	"strings"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"vision_api/internal/logging"
 )
 
+// tracer creates the client spans; it is a no-op until a tracer provider
+// is installed
+var tracer = otel.Tracer("vision_api/pkg/vision")
+
 // Client handles communication with the Google Cloud Vision API
 type Client struct {
 	mu          sync.Mutex
 	options     *Options
 	rateLimiter *RateLimiter
+	slots       chan struct{}
+	logger      *slog.Logger
 }
 
 // Label represents an image label from the Vision API
@@ -25,13 +44,79 @@ type Label struct {
 
 // Response represents the Vision API response
 type Response struct {
-	Labels []Label `json:"labelAnnotations"`
-	Error  *struct {
+	Labels  []Label     `json:"labelAnnotations"`
+	Objects []rawObject `json:"localizedObjectAnnotations"`
+	Error   *struct {
 		Code    int    `json:"code"`
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
 }
 
+// rawObject mirrors the wire format of a localized object annotation
+type rawObject struct {
+	Name         string  `json:"name"`
+	Score        float64 `json:"score"`
+	BoundingPoly struct {
+		NormalizedVertices []Vertex `json:"normalizedVertices"`
+	} `json:"boundingPoly"`
+}
+
+// featureCommands maps features to their gcloud subcommands
+var featureCommands = map[FeatureType]string{
+	LabelDetection:     "detect-labels",
+	ObjectLocalization: "detect-objects",
+	ImageProperties:    "detect-image-properties",
+}
+
+// Observer receives notifications about API calls, e.g. for metrics
+type Observer interface {
+	// ObserveCall is called after every API call. statusCode is 200 on
+	// success, the API error code on API errors and 0 if no response was
+	// received. bytes is the size of the uploaded image.
+	ObserveCall(feature FeatureType, statusCode int, duration time.Duration, bytes int64)
+
+	// ObserveRetry is called before a failed call is retried
+	ObserveRetry(feature FeatureType)
+
+	// ObserveRateLimitWait is called with the time spent waiting for the rate limiter
+	ObserveRateLimitWait(wait time.Duration)
+}
+
+// MultiObserver returns an observer that notifies every non-nil observer
+func MultiObserver(observers ...Observer) Observer {
+	var multi multiObserver
+	for _, observer := range observers {
+		if observer != nil {
+			multi = append(multi, observer)
+		}
+	}
+	return multi
+}
+
+// multiObserver fans notifications out to several observers
+type multiObserver []Observer
+
+// ObserveCall implements Observer
+func (m multiObserver) ObserveCall(feature FeatureType, statusCode int, duration time.Duration, bytes int64) {
+	for _, observer := range m {
+		observer.ObserveCall(feature, statusCode, duration, bytes)
+	}
+}
+
+// ObserveRetry implements Observer
+func (m multiObserver) ObserveRetry(feature FeatureType) {
+	for _, observer := range m {
+		observer.ObserveRetry(feature)
+	}
+}
+
+// ObserveRateLimitWait implements Observer
+func (m multiObserver) ObserveRateLimitWait(wait time.Duration) {
+	for _, observer := range m {
+		observer.ObserveRateLimitWait(wait)
+	}
+}
+
 // RateLimiter handles API rate limiting
 type RateLimiter struct {
 	mu        sync.Mutex
@@ -53,6 +138,8 @@ func NewClient(opts ...OptionFunc) (*Client, error) {
 
 	return &Client{
 		options: options,
+		logger:  options.Logger.With(logging.ComponentKey, "vision"),
+		slots:   make(chan struct{}, options.MaxConcurrent),
 		rateLimiter: &RateLimiter{
 			rateLimit: options.RateLimit,
 			window:    time.Minute,
@@ -61,97 +148,344 @@ func NewClient(opts ...OptionFunc) (*Client, error) {
 	}, nil
 }
 
+// SetRateLimit changes the maximum number of requests per minute of a
+// running client
+func (c *Client) SetRateLimit(limit int) error {
+	if limit < 1 {
+		return fmt.Errorf("rate limit must be at least 1")
+	}
+	c.rateLimiter.SetLimit(limit)
+	c.logger.Info("rate limit changed", "requests_per_minute", limit)
+	return nil
+}
+
+// RateLimit returns the maximum number of requests per minute
+func (c *Client) RateLimit() int {
+	return c.rateLimiter.Limit()
+}
+
 // DetectLabels detects labels in the given image
 func (c *Client) DetectLabels(ctx context.Context, imagePath string) ([]Label, error) {
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		return nil, fmt.Errorf("rate limit wait: %w", err)
+	response, err := c.Annotate(ctx, AnnotateRequest{
+		ImagePath: imagePath,
+		Features:  []FeatureType{LabelDetection},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response.Labels, nil
+}
+
+// Annotate runs every requested feature against an image and merges the
+// results. If the request lists no features, the client defaults are used.
+// The gcloud subcommands take no image context, so req.Context is not sent.
+func (c *Client) Annotate(ctx context.Context, req AnnotateRequest) (*AnnotateResponse, error) {
+	features := req.Features
+	if len(features) == 0 {
+		features = c.options.Features
+	}
+
+	result := &AnnotateResponse{
+		Metadata: RequestMetadata{
+			StartTime: time.Now(),
+			Status:    StatusInProgress,
+		},
+	}
+
+	for _, feature := range features {
+		response, retries, err := c.detect(ctx, req.ImagePath, feature, req.Context)
+		result.Metadata.RetryCount += retries
+		if err != nil {
+			result.Metadata.EndTime = time.Now()
+			result.Metadata.Duration = result.Metadata.EndTime.Sub(result.Metadata.StartTime)
+			result.Metadata.Status = StatusFailed
+			return result, fmt.Errorf("%s: %w", feature, err)
+		}
+
+		result.Labels = append(result.Labels, response.Labels...)
+		for _, object := range response.Objects {
+			result.Objects = append(result.Objects, ObjectAnnotation{
+				Name:  object.Name,
+				Score: object.Score,
+				BoundingBox: BoundingPoly{
+					NormalizedVertices: object.BoundingPoly.NormalizedVertices,
+				},
+			})
+		}
+	}
+
+	result.Metadata.EndTime = time.Now()
+	result.Metadata.Duration = result.Metadata.EndTime.Sub(result.Metadata.StartTime)
+	result.Metadata.Status = StatusCompleted
+	return result, nil
+}
+
+// detect runs a single feature with retries and returns the parsed response
+// along with the number of retries that were needed. Every attempt waits
+// for the rate limiter.
+func (c *Client) detect(ctx context.Context, imagePath string, feature FeatureType, imageContext *ImageContext) (_ *Response, _ int, err error) {
+	command, ok := featureCommands[feature]
+	if !ok {
+		return nil, 0, fmt.Errorf("unsupported feature: %s", feature)
+	}
+
+	ctx, span := tracer.Start(ctx, "vision.detect", trace.WithAttributes(
+		attribute.String("vision.feature", string(feature)),
+		attribute.String("image.path", imagePath),
+	))
+	defer func() { endSpan(span, err) }()
+
+	var size int64
+	if stat, err := os.Stat(imagePath); err == nil {
+		size = stat.Size()
+		span.SetAttributes(attribute.Int64("image.bytes", size))
 	}
 
-	var response Response
 	for attempt := 0; attempt <= c.options.MaxRetries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, attempt, err
+		}
+		if err := c.waitRateLimit(ctx, feature, attempt); err != nil {
+			return nil, attempt, fmt.Errorf("rate limit wait: %w", err)
+		}
+
+		response, retry, err := c.call(ctx, command, imagePath, feature, imageContext, attempt, size)
+		if err == nil || !retry {
+			return response, attempt, err
+		}
+
+		if attempt == c.options.MaxRetries {
+			return nil, attempt, fmt.Errorf("max retries exceeded: %w", err)
+		}
+
+		if observer := c.options.Observer; observer != nil {
+			observer.ObserveRetry(feature)
+		}
+
+		// Calculate backoff delay
+		delay := c.options.InitialBackoff * (1 << uint(attempt))
+		if delay > c.options.MaxBackoff {
+			delay = c.options.MaxBackoff
+		}
+		c.logger.WarnContext(ctx, "vision call failed, retrying",
+			"feature", feature,
+			"attempt", attempt,
+			"delay", delay,
+			"error", err,
+		)
+
+		_, backoff := tracer.Start(ctx, "vision.backoff", trace.WithAttributes(
+			attribute.Int("vision.attempt", attempt),
+			attribute.Int64("vision.backoff_ms", delay.Milliseconds()),
+		))
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-			output, err := c.executeCommand(ctx, imagePath)
-			if err == nil {
-				if err := json.Unmarshal(output, &response); err != nil {
-					return nil, fmt.Errorf("failed to parse API response: %w", err)
-				}
+			endSpan(backoff, ctx.Err())
+			return nil, attempt, ctx.Err()
+		case <-time.After(delay):
+			backoff.End()
+		}
+	}
+
+	return nil, c.options.MaxRetries, fmt.Errorf("failed to detect %s", feature)
+}
+
+// waitRateLimit waits until the rate limiter admits the next attempt
+func (c *Client) waitRateLimit(ctx context.Context, feature FeatureType, attempt int) error {
+	waitStart := time.Now()
+	_, span := tracer.Start(ctx, "vision.rate_limit_wait", trace.WithAttributes(
+		attribute.Int("vision.attempt", attempt),
+	))
+	err := c.rateLimiter.Wait(ctx)
+	endSpan(span, err)
+	wait := time.Since(waitStart)
+	if observer := c.options.Observer; observer != nil {
+		observer.ObserveRateLimitWait(wait)
+	}
+	if wait > time.Second {
+		c.logger.DebugContext(ctx, "waited for rate limiter", "feature", feature, "attempt", attempt, "wait", wait)
+	}
+	return err
+}
 
-				if response.Error != nil {
-					return nil, fmt.Errorf("API error: %s", response.Error.Message)
-				}
+// call performs a single API call in its own span, holding one of the
+// MaxConcurrent call slots and bounded by the request timeout. retry
+// reports whether a failed call may be retried, which is only the case
+// for rate limiting, timeouts and server errors.
+func (c *Client) call(ctx context.Context, command, imagePath string, feature FeatureType, imageContext *ImageContext, attempt int, size int64) (response *Response, retry bool, err error) {
+	ctx, span := tracer.Start(ctx, "vision.call", trace.WithAttributes(
+		attribute.String("vision.feature", string(feature)),
+		attribute.Int("vision.attempt", attempt),
+	))
+	statusCode := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("vision.status_code", statusCode))
+		endSpan(span, err)
+	}()
 
-				return response.Labels, nil
-			}
+	if c.options.Debug {
+		c.logger.DebugContext(ctx, "vision request",
+			"feature", feature,
+			"attempt", attempt,
+			"image_path", imagePath,
+			"body", requestBody(feature, size),
+		)
+	}
 
-			if attempt == c.options.MaxRetries {
-				return nil, fmt.Errorf("max retries exceeded: %w", err)
-			}
+	select {
+	case c.slots <- struct{}{}:
+		defer func() { <-c.slots }()
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
 
-			// Calculate backoff delay
-			delay := c.options.InitialBackoff * (1 << uint(attempt))
-			if delay > c.options.MaxBackoff {
-				delay = c.options.MaxBackoff
-			}
+	callCtx, cancel := context.WithTimeout(ctx, c.options.Timeout)
+	defer cancel()
 
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(delay):
-				continue
-			}
+	callStart := time.Now()
+	var output []byte
+	if imageContext != nil {
+		output, err = c.executeREST(callCtx, feature, imagePath, imageContext)
+	} else {
+		output, err = c.executeCommand(callCtx, command, imagePath)
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		// The attempt ran out of time rather than the caller, so it is
+		// retried like a server-side timeout
+		err = &StatusError{
+			StatusCode: http.StatusGatewayTimeout,
+			Message:    fmt.Sprintf("no response within %s", c.options.Timeout),
+		}
+	}
+	if c.options.Debug && output != nil {
+		c.logger.DebugContext(ctx, "vision response",
+			"feature", feature,
+			"attempt", attempt,
+			"duration", time.Since(callStart),
+			"body", redactPayload(output),
+		)
+	}
+	if err != nil {
+		// Only failures with a temporary API status are retried
+		var status *StatusError
+		if errors.As(err, &status) {
+			statusCode = status.StatusCode
+			retry = status.Temporary()
 		}
+		c.observeCall(feature, statusCode, callStart, size)
+		return nil, retry, err
 	}
 
-	return nil, fmt.Errorf("failed to detect labels")
+	response = &Response{}
+	if err := json.Unmarshal(output, response); err != nil {
+		c.observeCall(feature, statusCode, callStart, size)
+		return nil, false, fmt.Errorf("failed to parse API response: %w", err)
+	}
+
+	if response.Error != nil {
+		status := &StatusError{StatusCode: response.Error.Code, Message: response.Error.Message}
+		statusCode = status.StatusCode
+		c.observeCall(feature, statusCode, callStart, size)
+		return nil, status.Temporary(), status
+	}
+
+	statusCode = 200
+	c.observeCall(feature, statusCode, callStart, size)
+	return response, false, nil
+}
+
+// observeCall reports a finished API call to the observer, if any
+func (c *Client) observeCall(feature FeatureType, statusCode int, start time.Time, size int64) {
+	if c.options.Observer != nil {
+		c.options.Observer.ObserveCall(feature, statusCode, time.Since(start), size)
+	}
 }
 
 // executeCommand executes the gcloud command
-func (c *Client) executeCommand(ctx context.Context, imagePath string) ([]byte, error) {
-	cmd := exec.CommandContext(ctx, "gcloud", "ml", "vision", "detect-labels", imagePath)
+func (c *Client) executeCommand(ctx context.Context, command, imagePath string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "gcloud", "ml", "vision", command, imagePath)
+	cmd.Env = append(os.Environ(), traceEnv(ctx)...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
+		if status := commandStatus(output); status != nil {
+			return nil, status
+		}
 		return nil, fmt.Errorf("command execution failed: %w: %s", err, string(output))
 	}
 	return output, nil
 }
 
+// traceEnv returns the W3C trace context of ctx as environment variables
+// (TRACEPARENT, TRACESTATE) so the request made by the gcloud subprocess
+// can be correlated with the calling span
+func traceEnv(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	env := make([]string, 0, len(carrier))
+	for key, value := range carrier {
+		env = append(env, strings.ToUpper(key)+"="+value)
+	}
+	return env
+}
+
+// endSpan records err on the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
 // Wait implements rate limiting
 func (r *RateLimiter) Wait(ctx context.Context) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	now := time.Now()
-	cutoff := now.Add(-r.window)
+	for {
+		now := time.Now()
+		cutoff := now.Add(-r.window)
 
-	// Remove expired timestamps
-	i := 0
-	for ; i < len(r.requests) && r.requests[i].Before(cutoff); i++ {
-	}
-	if i > 0 {
-		r.requests = r.requests[i:]
-	}
+		// Remove expired timestamps
+		i := 0
+		for ; i < len(r.requests) && r.requests[i].Before(cutoff); i++ {
+		}
+		if i > 0 {
+			r.requests = r.requests[i:]
+		}
 
-	// Check if we need to wait
-	if len(r.requests) >= r.rateLimit {
-		waitTime := r.requests[0].Add(r.window).Sub(now)
-		if waitTime > 0 {
-			r.mu.Unlock()
-			select {
-			case <-ctx.Done():
-				r.mu.Lock()
-				return ctx.Err()
-			case <-time.After(waitTime):
-				r.mu.Lock()
-			}
+		// Check again after waiting, since the limit may have been lowered
+		if len(r.requests) < r.rateLimit {
+			r.requests = append(r.requests, now)
+			return nil
+		}
+
+		waitTime := r.requests[len(r.requests)-r.rateLimit].Add(r.window).Sub(now)
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			r.mu.Lock()
+			return ctx.Err()
+		case <-time.After(waitTime):
+			r.mu.Lock()
 		}
 	}
+}
 
-	// Record request
-	r.requests = append(r.requests, now)
-	return nil
+// SetLimit changes the maximum number of requests per window. Requests
+// already waiting pick up the new limit when they wake up.
+func (r *RateLimiter) SetLimit(limit int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rateLimit = limit
+}
+
+// Limit returns the maximum number of requests per window
+func (r *RateLimiter) Limit() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rateLimit
 }
 
 // GetCurrentRate returns the current request rate
@@ -0,0 +1,106 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedContext installs the W3C propagator for the test and returns a
+// context carrying a sampled remote span with trace state
+func tracedContext(t *testing.T) context.Context {
+	t.Helper()
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	state, err := trace.ParseTraceState("vendor=value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		TraceState: state,
+		Remote:     true,
+	}))
+}
+
+const wantTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestTraceEnv(t *testing.T) {
+	env := traceEnv(tracedContext(t))
+
+	if !slices.Contains(env, "TRACEPARENT="+wantTraceparent) {
+		t.Errorf("traceEnv() = %q, want TRACEPARENT=%s", env, wantTraceparent)
+	}
+	if !slices.Contains(env, "TRACESTATE=vendor=value") {
+		t.Errorf("traceEnv() = %q, want TRACESTATE=vendor=value", env)
+	}
+}
+
+func TestTraceEnvWithoutSpan(t *testing.T) {
+	tracedContext(t)
+	if env := traceEnv(context.Background()); len(env) != 0 {
+		t.Errorf("traceEnv() = %q, want no variables", env)
+	}
+}
+
+func TestNewAnnotateRequest(t *testing.T) {
+	req, err := newAnnotateRequest(tracedContext(t), annotateEndpoint, "token", []byte("{}"))
+	if err != nil {
+		t.Fatalf("newAnnotateRequest() error = %v", err)
+	}
+
+	if got := req.Header.Get("traceparent"); got != wantTraceparent {
+		t.Errorf("traceparent = %q, want %q", got, wantTraceparent)
+	}
+	if got := req.Header.Get("tracestate"); got != "vendor=value" {
+		t.Errorf("tracestate = %q, want vendor=value", got)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q, want Bearer token", got)
+	}
+	if req.Method != http.MethodPost {
+		t.Errorf("Method = %s, want POST", req.Method)
+	}
+}
+
+func TestNewRESTContext(t *testing.T) {
+	wire := newRESTContext(&ImageContext{
+		Location: &LocationInfo{Latitude: 52.52, Longitude: 13.405},
+	})
+
+	data, err := json.Marshal(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"latLongRect":{"minLatLng":{"latitude":52.52,"longitude":13.405},"maxLatLng":{"latitude":52.52,"longitude":13.405}}}`
+	if string(data) != want {
+		t.Errorf("wire context = %s, want %s", data, want)
+	}
+}
+
+func TestRPCStatus(t *testing.T) {
+	for code, want := range map[int]int{
+		3:  http.StatusBadRequest,          // INVALID_ARGUMENT
+		4:  http.StatusGatewayTimeout,      // DEADLINE_EXCEEDED
+		8:  http.StatusTooManyRequests,     // RESOURCE_EXHAUSTED
+		14: http.StatusServiceUnavailable,  // UNAVAILABLE
+		1:  http.StatusInternalServerError, // CANCELLED has no mapping
+		99: http.StatusInternalServerError,
+	} {
+		if got := rpcStatus(code, "message").StatusCode; got != want {
+			t.Errorf("rpcStatus(%d) = %d, want %d", code, got, want)
+		}
+	}
+}
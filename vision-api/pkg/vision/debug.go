@@ -0,0 +1,76 @@
+package vision
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// maxLoggedBody caps the size of bodies written to debug logs
+const maxLoggedBody = 16 * 1024
+
+// requestBody renders the annotate request sent for an image with the
+// image content replaced by its size
+func requestBody(feature FeatureType, size int64) string {
+	body := map[string]interface{}{
+		"requests": []interface{}{
+			map[string]interface{}{
+				"image":    map[string]interface{}{"content": redacted(size)},
+				"features": []interface{}{map[string]interface{}{"type": feature}},
+			},
+		},
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// redactPayload returns a response body for logging with any embedded
+// image content replaced by its size. Bodies that are not JSON are
+// returned as is, truncated to maxLoggedBody.
+func redactPayload(body []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return truncate(string(body))
+	}
+
+	data, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return ""
+	}
+	return truncate(string(data))
+}
+
+// redactValue walks a decoded JSON value replacing image content fields
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if content, ok := field.(string); ok && key == "content" {
+				v[key] = redacted(int64(len(content)))
+				continue
+			}
+			v[key] = redactValue(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+// redacted is the placeholder for an image payload of the given size
+func redacted(size int64) string {
+	return fmt.Sprintf("[redacted %d bytes]", size)
+}
+
+// truncate shortens s to maxLoggedBody
+func truncate(s string) string {
+	if len(s) <= maxLoggedBody {
+		return s
+	}
+	return s[:maxLoggedBody] + "...(truncated)"
+}
@@ -0,0 +1,174 @@
+package vision
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Options contains configuration for the Vision API client
+type Options struct {
+	// RateLimit is the maximum number of requests per minute
+	RateLimit int
+
+	// MaxRetries is the maximum number of retries per request
+	MaxRetries int
+
+	// Timeout is the maximum duration of a single attempt; attempts that
+	// time out are retried
+	Timeout time.Duration
+
+	// MaxConcurrent is the maximum number of calls in flight at once
+	MaxConcurrent int
+
+	// Debug logs request and response bodies at debug level, with image
+	// payloads redacted
+	Debug bool
+
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum delay between retries
+	MaxBackoff time.Duration
+
+	// Features is the default set of features requested per image
+	Features []FeatureType
+
+	// Observer receives notifications about API calls; nil disables them
+	Observer Observer
+
+	// Logger receives client logs
+	Logger *slog.Logger
+}
+
+// OptionFunc is a function that configures Options
+type OptionFunc func(*Options)
+
+// defaultOptions returns the default client options
+func defaultOptions() *Options {
+	return &Options{
+		RateLimit:      1800,
+		MaxRetries:     3,
+		Timeout:        30 * time.Second,
+		MaxConcurrent:  8,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Features:       []FeatureType{LabelDetection},
+		Logger:         slog.Default(),
+	}
+}
+
+// WithRateLimit sets the maximum number of requests per minute
+func WithRateLimit(limit int) OptionFunc {
+	return func(o *Options) {
+		if limit > 0 {
+			o.RateLimit = limit
+		}
+	}
+}
+
+// WithMaxRetries sets the maximum number of retries per request
+func WithMaxRetries(retries int) OptionFunc {
+	return func(o *Options) {
+		if retries >= 0 {
+			o.MaxRetries = retries
+		}
+	}
+}
+
+// WithTimeout sets the request timeout
+func WithTimeout(timeout time.Duration) OptionFunc {
+	return func(o *Options) {
+		if timeout > 0 {
+			o.Timeout = timeout
+		}
+	}
+}
+
+// WithMaxConcurrent sets the maximum number of concurrent requests
+func WithMaxConcurrent(max int) OptionFunc {
+	return func(o *Options) {
+		if max > 0 {
+			o.MaxConcurrent = max
+		}
+	}
+}
+
+// WithDebug enables logging of request and response bodies
+func WithDebug(debug bool) OptionFunc {
+	return func(o *Options) {
+		o.Debug = debug
+	}
+}
+
+// WithBackoff sets the initial and maximum retry delays
+func WithBackoff(initial, max time.Duration) OptionFunc {
+	return func(o *Options) {
+		if initial > 0 {
+			o.InitialBackoff = initial
+		}
+		if max > 0 {
+			o.MaxBackoff = max
+		}
+	}
+}
+
+// WithFeatures sets the default features requested per image
+func WithFeatures(features ...FeatureType) OptionFunc {
+	return func(o *Options) {
+		if len(features) > 0 {
+			o.Features = features
+		}
+	}
+}
+
+// WithObserver sets the observer notified about API calls
+func WithObserver(observer Observer) OptionFunc {
+	return func(o *Options) {
+		o.Observer = observer
+	}
+}
+
+// WithLogger sets the logger for client logs
+func WithLogger(logger *slog.Logger) OptionFunc {
+	return func(o *Options) {
+		if logger != nil {
+			o.Logger = logger
+		}
+	}
+}
+
+// validateOptions checks if the options are valid
+func validateOptions(o *Options) error {
+	if o.RateLimit < 1 {
+		return fmt.Errorf("rate limit must be at least 1")
+	}
+
+	if o.MaxRetries < 0 {
+		return fmt.Errorf("max retries cannot be negative")
+	}
+
+	if o.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+
+	if o.MaxConcurrent < 1 {
+		return fmt.Errorf("max concurrent requests must be at least 1")
+	}
+
+	if o.MaxBackoff < o.InitialBackoff {
+		return fmt.Errorf("maximum backoff must be greater than or equal to initial backoff")
+	}
+
+	if len(o.Features) == 0 {
+		return fmt.Errorf("at least one feature is required")
+	}
+
+	for _, feature := range o.Features {
+		if !feature.IsValid() {
+			return fmt.Errorf("unsupported feature: %s", feature)
+		}
+	}
+
+	return nil
+}
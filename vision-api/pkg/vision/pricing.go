@@ -0,0 +1,144 @@
+package vision
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PriceTier is a band of monthly billable units billed at one price.
+// Tiers of a feature are ordered by UpTo; the last tier may leave UpTo at
+// zero to cover all remaining units.
+type PriceTier struct {
+	// UpTo is the cumulative number of monthly units this tier ends at
+	UpTo int64
+
+	// PricePer1000 is the price per 1000 units; zero for a free tier
+	PricePer1000 float64
+}
+
+// PriceTable holds the price tiers per feature. Every feature requested
+// for an image is one billable unit.
+type PriceTable map[FeatureType][]PriceTier
+
+// DefaultPriceTable returns the public list prices in USD at the time of
+// writing. They change over time, so configure the table for real budgets.
+func DefaultPriceTable() PriceTable {
+	return PriceTable{
+		LabelDetection: {
+			{UpTo: 1000, PricePer1000: 0},
+			{UpTo: 5_000_000, PricePer1000: 1.50},
+			{UpTo: 20_000_000, PricePer1000: 1.00},
+		},
+		ObjectLocalization: {
+			{UpTo: 1000, PricePer1000: 0},
+			{UpTo: 5_000_000, PricePer1000: 2.25},
+			{UpTo: 20_000_000, PricePer1000: 1.50},
+		},
+		ImageProperties: {
+			{UpTo: 1000, PricePer1000: 0},
+			{UpTo: 5_000_000, PricePer1000: 1.50},
+			{UpTo: 20_000_000, PricePer1000: 0.60},
+		},
+	}
+}
+
+// TierCost is the share of an estimate billed within one tier
+type TierCost struct {
+	Tier  PriceTier
+	Units int64
+	Cost  float64
+}
+
+// FeatureCost is the estimated cost of the units of one feature
+type FeatureCost struct {
+	Feature FeatureType
+	Units   int64
+	Cost    float64
+	Tiers   []TierCost
+}
+
+// Estimate prices the given units per feature. usedUnits are units already
+// consumed this billing month, which move the estimate into later tiers.
+// It fails if a feature has no prices or the units exceed the last tier.
+func (t PriceTable) Estimate(units map[FeatureType]int64, usedUnits map[FeatureType]int64) ([]FeatureCost, float64, error) {
+	features := make([]FeatureType, 0, len(units))
+	for feature := range units {
+		features = append(features, feature)
+	}
+	sort.Slice(features, func(i, j int) bool { return features[i] < features[j] })
+
+	var costs []FeatureCost
+	var total float64
+	for _, feature := range features {
+		cost, err := t.estimateFeature(feature, units[feature], usedUnits[feature])
+		if err != nil {
+			return nil, 0, err
+		}
+		costs = append(costs, cost)
+		total += cost.Cost
+	}
+
+	return costs, total, nil
+}
+
+// estimateFeature distributes units across the tiers of a feature
+func (t PriceTable) estimateFeature(feature FeatureType, units, used int64) (FeatureCost, error) {
+	tiers, ok := t[feature]
+	if !ok || len(tiers) == 0 {
+		return FeatureCost{}, fmt.Errorf("no prices configured for %s", feature)
+	}
+
+	cost := FeatureCost{Feature: feature, Units: units}
+	start := used
+	remaining := units
+	for _, tier := range tiers {
+		if remaining == 0 {
+			break
+		}
+
+		upper := tier.UpTo
+		if upper > 0 && start >= upper {
+			continue
+		}
+
+		billed := remaining
+		if upper > 0 && start+billed > upper {
+			billed = upper - start
+		}
+
+		tierCost := float64(billed) / 1000 * tier.PricePer1000
+		cost.Tiers = append(cost.Tiers, TierCost{Tier: tier, Units: billed, Cost: tierCost})
+		cost.Cost += tierCost
+
+		start += billed
+		remaining -= billed
+	}
+
+	if remaining > 0 {
+		return cost, fmt.Errorf("%d units of %s exceed the last price tier", remaining, feature)
+	}
+	return cost, nil
+}
+
+// Validate checks that the tiers of every feature are ordered and priced
+func (t PriceTable) Validate() error {
+	for feature, tiers := range t {
+		if !feature.IsValid() {
+			return fmt.Errorf("unsupported feature in price table: %s", feature)
+		}
+		var last int64
+		for i, tier := range tiers {
+			if tier.PricePer1000 < 0 {
+				return fmt.Errorf("%s: price cannot be negative", feature)
+			}
+			if tier.UpTo == 0 && i != len(tiers)-1 {
+				return fmt.Errorf("%s: only the last tier may be unbounded", feature)
+			}
+			if tier.UpTo != 0 && tier.UpTo <= last {
+				return fmt.Errorf("%s: tiers must be in increasing order", feature)
+			}
+			last = tier.UpTo
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package vision
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateTiers(t *testing.T) {
+	table := PriceTable{
+		LabelDetection: {
+			{UpTo: 1000, PricePer1000: 0},
+			{UpTo: 5000, PricePer1000: 1.50},
+			{UpTo: 0, PricePer1000: 1.00},
+		},
+		ObjectLocalization: {
+			{UpTo: 1000, PricePer1000: 0},
+			{UpTo: 2000, PricePer1000: 2.00},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		feature   FeatureType
+		units     int64
+		used      int64
+		wantCost  float64
+		wantTiers []int64 // units billed per tier used
+		wantErr   bool
+	}{
+		{name: "within free tier", feature: LabelDetection, units: 1000, wantCost: 0, wantTiers: []int64{1000}},
+		{name: "across the free tier", feature: LabelDetection, units: 3000, wantCost: 3, wantTiers: []int64{1000, 2000}},
+		{name: "into the unbounded tier", feature: LabelDetection, units: 7000, wantCost: 8, wantTiers: []int64{1000, 4000, 2000}},
+		{name: "used units skip the free tier", feature: LabelDetection, units: 2000, used: 1000, wantCost: 3, wantTiers: []int64{2000}},
+		{name: "used units start mid tier", feature: LabelDetection, units: 2000, used: 4000, wantCost: 2.5, wantTiers: []int64{1000, 1000}},
+		{name: "used units past bounded tiers", feature: LabelDetection, units: 1000, used: 10000, wantCost: 1, wantTiers: []int64{1000}},
+		{name: "no units", feature: LabelDetection, units: 0, wantCost: 0},
+		{name: "exactly the last tier", feature: ObjectLocalization, units: 2000, wantCost: 2, wantTiers: []int64{1000, 1000}},
+		{name: "beyond the last tier", feature: ObjectLocalization, units: 2001, wantErr: true},
+		{name: "used units exhaust the tiers", feature: ObjectLocalization, units: 1, used: 2000, wantErr: true},
+		{name: "unpriced feature", feature: ImageProperties, units: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			costs, total, err := table.Estimate(
+				map[FeatureType]int64{tt.feature: tt.units},
+				map[FeatureType]int64{tt.feature: tt.used},
+			)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Estimate() = %v, want error", costs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Estimate() error = %v", err)
+			}
+			if math.Abs(total-tt.wantCost) > 1e-9 || math.Abs(costs[0].Cost-tt.wantCost) > 1e-9 {
+				t.Errorf("Estimate() cost = %g (total %g), want %g", costs[0].Cost, total, tt.wantCost)
+			}
+			var tiers []int64
+			for _, tier := range costs[0].Tiers {
+				tiers = append(tiers, tier.Units)
+			}
+			if len(tiers) != len(tt.wantTiers) {
+				t.Fatalf("Estimate() tier units = %v, want %v", tiers, tt.wantTiers)
+			}
+			for i := range tiers {
+				if tiers[i] != tt.wantTiers[i] {
+					t.Errorf("Estimate() tier units = %v, want %v", tiers, tt.wantTiers)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestPriceTableValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   PriceTable
+		wantErr bool
+	}{
+		{name: "default table", table: DefaultPriceTable()},
+		{name: "unbounded last tier", table: PriceTable{LabelDetection: {{UpTo: 10}, {UpTo: 0, PricePer1000: 1}}}},
+		{name: "unsupported feature", table: PriceTable{"FACE_DETECTION": {{UpTo: 10}}}, wantErr: true},
+		{name: "negative price", table: PriceTable{LabelDetection: {{UpTo: 10, PricePer1000: -1}}}, wantErr: true},
+		{name: "unbounded tier before the last", table: PriceTable{LabelDetection: {{UpTo: 0}, {UpTo: 10}}}, wantErr: true},
+		{name: "decreasing tiers", table: PriceTable{LabelDetection: {{UpTo: 10}, {UpTo: 5}}}, wantErr: true},
+		{name: "repeated bound", table: PriceTable{LabelDetection: {{UpTo: 10}, {UpTo: 10}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.table.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
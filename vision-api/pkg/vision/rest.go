@@ -0,0 +1,183 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// annotateEndpoint is the REST endpoint used for requests with an image
+// context, which the gcloud subcommands cannot send
+var annotateEndpoint = "https://vision.googleapis.com/v1/images:annotate"
+
+// restRequest is the body of an images:annotate call for a single image
+type restRequest struct {
+	Requests []restImageRequest `json:"requests"`
+}
+
+type restImageRequest struct {
+	Image        restImage         `json:"image"`
+	Features     []restFeature     `json:"features"`
+	ImageContext *restImageContext `json:"imageContext,omitempty"`
+}
+
+type restImage struct {
+	Content string `json:"content"`
+}
+
+type restFeature struct {
+	Type FeatureType `json:"type"`
+}
+
+// restImageContext is the wire format of ImageContext. A location is sent
+// as a lat/long rectangle of a single point.
+type restImageContext struct {
+	LanguageHints []string         `json:"languageHints,omitempty"`
+	LatLongRect   *restLatLongRect `json:"latLongRect,omitempty"`
+	CropHints     *restCropHints   `json:"cropHintsParams,omitempty"`
+	WebDetection  *restWebParams   `json:"webDetectionParams,omitempty"`
+}
+
+type restCropHints struct {
+	AspectRatios []float64 `json:"aspectRatios"`
+}
+
+type restWebParams struct {
+	IncludeGeoResults bool `json:"includeGeoResults"`
+}
+
+type restLatLongRect struct {
+	Min restLatLng `json:"minLatLng"`
+	Max restLatLng `json:"maxLatLng"`
+}
+
+type restLatLng struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// restResponse is the body returned by images:annotate. Errors of single
+// images carry a canonical code, errors of the call an HTTP status.
+type restResponse struct {
+	Responses []json.RawMessage `json:"responses"`
+	Error     *restStatus       `json:"error,omitempty"`
+}
+
+type restStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// newRESTContext converts an image context to its wire format
+func newRESTContext(imageContext *ImageContext) *restImageContext {
+	wire := &restImageContext{LanguageHints: imageContext.LanguageHints}
+	if location := imageContext.Location; location != nil {
+		point := restLatLng{Latitude: location.Latitude, Longitude: location.Longitude}
+		wire.LatLongRect = &restLatLongRect{Min: point, Max: point}
+	}
+	if hints := imageContext.CropHints; hints != nil {
+		wire.CropHints = &restCropHints{AspectRatios: hints.AspectRatios}
+	}
+	if web := imageContext.WebDetection; web != nil {
+		wire.WebDetection = &restWebParams{IncludeGeoResults: web.IncludeGeoResults}
+	}
+	return wire
+}
+
+// executeREST annotates an image with a single feature through the REST
+// API, authenticated with the access token of the active gcloud account.
+// It returns the response of the image in the format the gcloud
+// subcommands print.
+func (c *Client) executeREST(ctx context.Context, feature FeatureType, imagePath string, imageContext *ImageContext) ([]byte, error) {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	body, err := json.Marshal(restRequest{Requests: []restImageRequest{{
+		Image:        restImage{Content: base64.StdEncoding.EncodeToString(data)},
+		Features:     []restFeature{{Type: feature}},
+		ImageContext: newRESTContext(imageContext),
+	}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := newAnnotateRequest(ctx, annotateEndpoint, token, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	output, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result restResponse
+	if err := json.Unmarshal(output, &result); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("failed to parse API response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := http.StatusText(resp.StatusCode)
+		if result.Error != nil {
+			message = result.Error.Message
+		}
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: message}
+	}
+	if len(result.Responses) != 1 {
+		return nil, fmt.Errorf("expected 1 response, got %d", len(result.Responses))
+	}
+
+	var image struct {
+		Error *restStatus `json:"error"`
+	}
+	if err := json.Unmarshal(result.Responses[0], &image); err != nil {
+		return nil, fmt.Errorf("failed to parse API response: %w", err)
+	}
+	if image.Error != nil {
+		return nil, rpcStatus(image.Error.Code, image.Error.Message)
+	}
+	return result.Responses[0], nil
+}
+
+// newAnnotateRequest builds the images:annotate request, carrying the W3C
+// trace context of ctx in its headers
+func newAnnotateRequest(ctx context.Context, endpoint, token string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, nil
+}
+
+// accessToken returns an access token of the active gcloud account
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
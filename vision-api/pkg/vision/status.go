@@ -0,0 +1,95 @@
+package vision
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// StatusError is a failed API call with the HTTP status code the API
+// answered with
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the call may succeed when repeated, which is
+// the case for rate limiting, timeouts and server errors
+func (e *StatusError) Temporary() bool {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests,
+		e.StatusCode == http.StatusRequestTimeout,
+		e.StatusCode >= http.StatusInternalServerError:
+		return true
+	default:
+		return false
+	}
+}
+
+// canonicalStatus matches the canonical error code gcloud prints after the
+// failed command, e.g. "(gcloud.ml.vision.detect-labels) PERMISSION_DENIED:"
+var canonicalStatus = regexp.MustCompile(`\(gcloud\.[\w.-]+\) ([A-Z_]+):`)
+
+// canonicalCodes maps canonical error codes to their HTTP status codes
+var canonicalCodes = map[string]int{
+	"INVALID_ARGUMENT":    http.StatusBadRequest,
+	"FAILED_PRECONDITION": http.StatusBadRequest,
+	"OUT_OF_RANGE":        http.StatusBadRequest,
+	"UNAUTHENTICATED":     http.StatusUnauthorized,
+	"PERMISSION_DENIED":   http.StatusForbidden,
+	"NOT_FOUND":           http.StatusNotFound,
+	"ALREADY_EXISTS":      http.StatusConflict,
+	"ABORTED":             http.StatusConflict,
+	"RESOURCE_EXHAUSTED":  http.StatusTooManyRequests,
+	"INTERNAL":            http.StatusInternalServerError,
+	"UNKNOWN":             http.StatusInternalServerError,
+	"DATA_LOSS":           http.StatusInternalServerError,
+	"UNIMPLEMENTED":       http.StatusNotImplemented,
+	"UNAVAILABLE":         http.StatusServiceUnavailable,
+	"DEADLINE_EXCEEDED":   http.StatusGatewayTimeout,
+}
+
+// rpcCodes lists the canonical error codes by their numeric value
+var rpcCodes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// rpcStatus returns the API status of a numeric canonical error code, as
+// found in the per-image errors of REST responses. Unknown codes are
+// treated as server errors.
+func rpcStatus(code int, message string) *StatusError {
+	status := &StatusError{StatusCode: http.StatusInternalServerError, Message: message}
+	if code >= 0 && code < len(rpcCodes) {
+		if statusCode, ok := canonicalCodes[rpcCodes[code]]; ok {
+			status.StatusCode = statusCode
+		}
+	}
+	return status
+}
+
+// commandStatus extracts the API status from the output of a failed gcloud
+// command. It returns nil if the output carries no known status.
+func commandStatus(output []byte) *StatusError {
+	loc := canonicalStatus.FindSubmatchIndex(output)
+	if loc == nil {
+		return nil
+	}
+	code, ok := canonicalCodes[string(output[loc[2]:loc[3]])]
+	if !ok {
+		return nil
+	}
+	message, _, _ := strings.Cut(string(output[loc[1]:]), "\n")
+	return &StatusError{
+		StatusCode: code,
+		Message:    strings.TrimSpace(message),
+	}
+}
@@ -1,6 +1,10 @@
 package vision
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // APIVersion represents the Vision API version
 type APIVersion string
@@ -22,6 +26,21 @@ const (
 	ImageProperties FeatureType = "IMAGE_PROPERTIES"
 )
 
+// IsValid reports whether the feature is supported by the client
+func (f FeatureType) IsValid() bool {
+	_, ok := featureCommands[f]
+	return ok
+}
+
+// ParseFeature parses a feature name such as "label_detection" or "OBJECT_LOCALIZATION"
+func ParseFeature(name string) (FeatureType, error) {
+	feature := FeatureType(strings.ToUpper(strings.TrimSpace(name)))
+	if !feature.IsValid() {
+		return "", fmt.Errorf("unsupported feature: %s", name)
+	}
+	return feature, nil
+}
+
 // RequestStatus represents the status of an API request
 type RequestStatus string
 